@@ -14,6 +14,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/SevenTV/ServerGo/src/configure"
 	"github.com/SevenTV/ServerGo/src/discord"
@@ -23,6 +24,7 @@ import (
 	"github.com/SevenTV/ServerGo/src/redis"
 	_ "github.com/SevenTV/ServerGo/src/redis"
 	"github.com/SevenTV/ServerGo/src/server"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 
 	"github.com/SevenTV/ServerGo/src/server/api/tasks"
 )
@@ -134,15 +136,14 @@ func GetAllRoles(ctx context.Context) ([]datastructure.Role, error) {
 	return roles, nil
 }
 
-// SyncBans: Ensure active bans exist on the redis instance
+// SyncBans: Ensure the redis instance holds exactly the active bans
 func SyncBans(ctx context.Context) ([]*datastructure.Ban, error) {
+	if err := migrateLegacyBans(ctx); err != nil {
+		return nil, err
+	}
+
 	bans := []*datastructure.Ban{}
-	cur, err := mongo.Collection(mongo.CollectionNameBans).Find(ctx, bson.M{
-		"$or": bson.A{
-			bson.M{"expire_at": nil},
-			bson.M{"expire_at": bson.M{"$gt": time.Now()}},
-		},
-	})
+	cur, err := mongo.Collection(mongo.CollectionNameBans).Find(ctx, actions.ActiveBanFilter(), options.Find().SetSort(bson.M{"_id": -1}))
 	if err != nil {
 		return nil, err
 	}
@@ -151,19 +152,87 @@ func SyncBans(ctx context.Context) ([]*datastructure.Ban, error) {
 		return nil, err
 	}
 
-	// Sync with redis
+	byUser := map[string][]*datastructure.Ban{}
 	for _, b := range bans {
-		if redis.Client.HExists(ctx, "user:bans", b.UserID.Hex()).Val() {
+		if b.UserID == nil {
 			continue
 		}
-		if err := redis.Client.HSet(ctx, "user:bans", b.UserID.Hex(), b.Reason).Err(); err != nil {
-			log.WithError(err).Warn("SyncBans")
+		byUser[b.UserID.Hex()] = append(byUser[b.UserID.Hex()], b)
+	}
+
+	// Full bans and scoped bans are held in separate hashes
+	reasons := map[string]interface{}{}
+	scopes := map[string]interface{}{}
+	for id, userBans := range byUser {
+		reason, full, scope := actions.ReduceBans(userBans)
+		if full {
+			reasons[id] = reason
+		}
+		if scope != 0 {
+			scopes[id] = scope
+		}
+	}
+
+	for key, current := range map[string]map[string]interface{}{
+		"user:bans":        reasons,
+		"user:bans:scoped": scopes,
+	} {
+		// Remove stale entries
+		existing, err := redis.Client.HKeys(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range existing {
+			if _, ok := current[id]; ok {
+				continue
+			}
+			if err := redis.Client.HDel(ctx, key, id).Err(); err != nil {
+				log.WithError(err).Warn("SyncBans")
+			}
+		}
+
+		// Sync with redis
+		for id, v := range current {
+			if err := redis.Client.HSet(ctx, key, id, v).Err(); err != nil {
+				log.WithError(err).Warn("SyncBans")
+			}
 		}
 	}
 
 	return bans, nil
 }
 
+// Bans created before the "active" field existed were lifted by zeroing their expiry,
+// which made them indistinguishable from permanent bans. The redis hash settles those.
+func migrateLegacyBans(ctx context.Context) error {
+	legacy := []*datastructure.Ban{}
+	cur, err := mongo.Collection(mongo.CollectionNameBans).Find(ctx, bson.M{"active": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	if err := cur.All(ctx, &legacy); err != nil {
+		return err
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	ops := make([]mongo.WriteModel, len(legacy))
+	for i, b := range legacy {
+		active := b.ExpireAt.After(time.Now())
+		if b.ExpireAt.IsZero() && b.UserID != nil {
+			active = redis.Client.HExists(ctx, "user:bans", b.UserID.Hex()).Val()
+		}
+
+		ops[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": b.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"active": active}})
+	}
+
+	_, err = mongo.Collection(mongo.CollectionNameBans).BulkWrite(ctx, ops)
+	return err
+}
+
 func panicHandler(output string) {
 	fmt.Printf("PANIC OCCURED:\n\n%s\n", output)
 	// Try to send a message to discord
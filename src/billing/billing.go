@@ -0,0 +1,59 @@
+package billing
+
+import (
+	"fmt"
+	"time"
+)
+
+// A payment provider, selling subscriptions and notifying their changes through webhooks
+type Provider interface {
+	// The name the provider is registered under, and which its webhook is served at
+	Name() string
+	// Verify that a webhook request was sent by the provider, and read the events it carries
+	ParseWebhook(header func(key string) string, body []byte) ([]*Event, error)
+}
+
+var (
+	ErrInvalidSignature = fmt.Errorf("webhook signature is invalid")
+	ErrMalformedWebhook = fmt.Errorf("webhook payload is malformed")
+)
+
+// A change of a subscription at its payment provider
+type Event struct {
+	Type EventType `json:"type"`
+	// The ID of the subscription at the provider
+	SubscriptionID string `json:"subscription_id"`
+	// The ID of the subscribed user, passed to the provider at checkout
+	UserID string `json:"user_id"`
+	// The ID of the plan at the provider
+	PlanID string `json:"plan_id"`
+	// The billing period paid for, set by activations and renewals
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	// When the provider emitted the event
+	At time.Time `json:"at"`
+}
+
+// What happened to a subscription
+type EventType string
+
+var (
+	EventActivated     = EventType("ACTIVATED")      // The first payment succeeded
+	EventRenewed       = EventType("RENEWED")        // A new billing period was paid for
+	EventPaymentFailed = EventType("PAYMENT_FAILED") // A renewal could not be charged
+	EventCanceled      = EventType("CANCELED")       // The subscription will not renew
+	EventResumed       = EventType("RESUMED")        // A cancelation was undone before the period ended
+	EventEnded         = EventType("ENDED")          // The subscription ended immediately, such as when it is refunded
+)
+
+var providers = map[string]Provider{}
+
+// Register a payment provider, making its webhook available
+func Register(p Provider) {
+	providers[p.Name()] = p
+}
+
+// Get a registered payment provider by name, or nil
+func GetProvider(name string) Provider {
+	return providers[name]
+}
@@ -0,0 +1,36 @@
+package billing
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+
+	"github.com/SevenTV/ServerGo/src/configure"
+)
+
+// A payment provider which charges nothing, for development and testing.
+// Its webhook takes a JSON list of events, authorized by the configured secret
+type FakeProvider struct {
+	Secret string
+}
+
+func (*FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) ParseWebhook(header func(key string) string, body []byte) ([]*Event, error) {
+	if p.Secret == "" || subtle.ConstantTimeCompare([]byte(header("X-Fake-Signature")), []byte(p.Secret)) != 1 {
+		return nil, ErrInvalidSignature
+	}
+
+	events := []*Event{}
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, ErrMalformedWebhook
+	}
+	return events, nil
+}
+
+func init() {
+	if secret := configure.Config.GetString("billing.fake.secret"); secret != "" {
+		Register(&FakeProvider{Secret: secret})
+	}
+}
@@ -187,3 +187,21 @@ func SendServiceRestored(serviceName string) {
 		},
 	})
 }
+
+func SendAuditChainBroken(problems []string) {
+	description := strings.Join(problems, "\n")
+
+	_ = SendWebhook("alerts", &dgo.WebhookParams{
+		Content: fmt.Sprintf("🟥 **[AUDIT LOG INTEGRITY]** %d problem(s) found while verifying the audit chain", len(problems)),
+		Embeds: []*dgo.MessageEmbed{
+			{
+				Color:       16728642,
+				Description: description[:int(math.Min(2000, float64(len(description))))],
+				Fields: []*dgo.MessageEmbedField{
+					{Name: "Node", Value: configure.NodeName, Inline: true},
+					{Name: "Pod", Value: configure.PodName, Inline: true},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,157 @@
+package emoteresolution
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/utils"
+)
+
+var ErrUnknownSource = fmt.Errorf("unknown emote source")
+
+// Where an emote in a channel comes from: its provider, and whether it belongs to the channel or is available everywhere
+type Source string
+
+var (
+	Source7TVChannel    = Source("7TV:CHANNEL")
+	Source7TVGlobal     = Source("7TV:GLOBAL")
+	SourceBTTVChannel   = Source("BTTV:CHANNEL")
+	SourceBTTVGlobal    = Source("BTTV:GLOBAL")
+	SourceFFZChannel    = Source("FFZ:CHANNEL")
+	SourceFFZGlobal     = Source("FFZ:GLOBAL")
+	SourceTwitchChannel = Source("TWITCH:CHANNEL") // The channel's subscriber, follower and bits emotes
+	SourceTwitchGlobal  = Source("TWITCH:GLOBAL")
+)
+
+// The precedence of emote sources unless configured otherwise, the first winning a name conflict.
+// Twitch renders its own emotes, so they come first, and a channel's emotes come before global ones
+var DefaultPrecedence = []Source{
+	SourceTwitchChannel, SourceTwitchGlobal,
+	Source7TVChannel, SourceBTTVChannel, SourceFFZChannel,
+	Source7TVGlobal, SourceBTTVGlobal, SourceFFZGlobal,
+}
+
+// The sources whose emotes a 7TV emote displaces, by override flag
+var overrides = map[int32][]Source{
+	datastructure.EmoteVisibilityOverrideBTTV:             {SourceBTTVChannel, SourceBTTVGlobal},
+	datastructure.EmoteVisibilityOverrideFFZ:              {SourceFFZChannel, SourceFFZGlobal},
+	datastructure.EmoteVisibilityOverrideTwitchGlobal:     {SourceTwitchGlobal},
+	datastructure.EmoteVisibilityOverrideTwitchSubscriber: {SourceTwitchChannel},
+}
+
+func (s Source) Provider() string {
+	return strings.SplitN(string(s), ":", 2)[0]
+}
+
+// ParsePrecedence: Read a list of emote sources, the first winning a name conflict. Sources left out are not resolved
+func ParsePrecedence(values []string) ([]Source, error) {
+	result := make([]Source, 0, len(values))
+	for _, v := range values {
+		s := Source(strings.ToUpper(strings.TrimSpace(v)))
+		known := false
+		for _, d := range DefaultPrecedence {
+			known = known || d == s
+		}
+		for _, r := range result {
+			known = known && r != s
+		}
+		if !known {
+			return nil, ErrUnknownSource
+		}
+		result = append(result, s)
+	}
+	if len(result) == 0 {
+		return nil, ErrUnknownSource
+	}
+
+	return result, nil
+}
+
+// An emote in effect in a channel
+type ResolvedEmote struct {
+	Source   Source
+	Emote    *datastructure.Emote // Named as in the channel
+	Shadowed []ShadowedEmote      // The emotes of the same name it wins over, by precedence
+}
+
+type ShadowedEmote struct {
+	Source     Source
+	Emote      *datastructure.Emote
+	Overridden bool // Whether an override flag displaced the emote, rather than precedence
+}
+
+// Resolve: Get the emotes in effect from the emotes of each source, given in the order of the precedence, sorted by name
+//
+// Emotes sharing a name are resolved in favour of the earliest source of the precedence,
+// except that a 7TV emote holding an override flag displaces the emotes of the overridden provider.
+// Each emote in effect lists those it shadows
+func Resolve(precedence []Source, emotes [][]*datastructure.Emote) []*ResolvedEmote {
+	type candidate struct {
+		source Source
+		emote  *datastructure.Emote
+	}
+	names := []string{}
+	candidates := map[string][]candidate{}
+	for i, source := range precedence {
+		if i >= len(emotes) {
+			break
+		}
+		for _, e := range emotes[i] {
+			if e == nil {
+				continue
+			}
+			if _, ok := candidates[e.Name]; !ok {
+				names = append(names, e.Name)
+			}
+			candidates[e.Name] = append(candidates[e.Name], candidate{source, e})
+		}
+	}
+	sort.Strings(names)
+
+	result := make([]*ResolvedEmote, 0, len(names))
+	for _, name := range names {
+		cands := candidates[name]
+
+		// Emotes of an overridden provider give way to the 7TV emote overriding them
+		overridden := make([]bool, len(cands))
+		for _, c := range cands {
+			if c.source.Provider() != "7TV" {
+				continue
+			}
+			for flag, sources := range overrides {
+				if !utils.BitField.HasBits(int64(c.emote.Visibility), int64(flag)) {
+					continue
+				}
+				for j, o := range cands {
+					for _, s := range sources {
+						overridden[j] = overridden[j] || o.source == s
+					}
+				}
+			}
+		}
+
+		winner := 0
+		for j := range cands {
+			if !overridden[j] {
+				winner = j
+				break
+			}
+		}
+
+		resolved := &ResolvedEmote{
+			Source:   cands[winner].source,
+			Emote:    cands[winner].emote,
+			Shadowed: []ShadowedEmote{},
+		}
+		for j, c := range cands {
+			if j != winner {
+				resolved.Shadowed = append(resolved.Shadowed, ShadowedEmote{Source: c.source, Emote: c.emote, Overridden: overridden[j]})
+			}
+		}
+		result = append(result, resolved)
+	}
+
+	return result
+}
@@ -0,0 +1,188 @@
+package emoteresolution
+
+import (
+	"testing"
+
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+)
+
+func emote(name string, visibility int32) *datastructure.Emote {
+	return &datastructure.Emote{Name: name, Visibility: visibility}
+}
+
+// The winner's source and the shadowed emotes' sources and override states, in order
+type outcome struct {
+	source     Source
+	shadowed   []Source
+	overridden []bool
+}
+
+func checkOutcome(t *testing.T, got *ResolvedEmote, want outcome) {
+	t.Helper()
+	if got.Source != want.source {
+		t.Errorf("%v: won by %v, want %v", got.Emote.Name, got.Source, want.source)
+	}
+	if len(got.Shadowed) != len(want.shadowed) {
+		t.Fatalf("%v: shadows %d emotes, want %d", got.Emote.Name, len(got.Shadowed), len(want.shadowed))
+	}
+	for i, s := range got.Shadowed {
+		if s.Source != want.shadowed[i] || s.Overridden != want.overridden[i] {
+			t.Errorf("%v: shadowed #%d is %v (overridden: %v), want %v (overridden: %v)",
+				got.Emote.Name, i, s.Source, s.Overridden, want.shadowed[i], want.overridden[i])
+		}
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	sources := map[Source][]*datastructure.Emote{
+		SourceTwitchChannel: {emote("Kappa", 0)},
+		Source7TVChannel:    {emote("Kappa", 0), emote("peepoHappy", 0)},
+		SourceBTTVChannel:   {emote("Kappa", 0), emote("peepoHappy", 0), emote("catJAM", 0)},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		precedence []Source
+		want       map[string]outcome
+	}{
+		{
+			name:       "default",
+			precedence: DefaultPrecedence,
+			want: map[string]outcome{
+				"Kappa":      {SourceTwitchChannel, []Source{Source7TVChannel, SourceBTTVChannel}, []bool{false, false}},
+				"catJAM":     {SourceBTTVChannel, []Source{}, []bool{}},
+				"peepoHappy": {Source7TVChannel, []Source{SourceBTTVChannel}, []bool{false}},
+			},
+		},
+		{
+			name:       "reversed",
+			precedence: []Source{SourceBTTVChannel, Source7TVChannel, SourceTwitchChannel},
+			want: map[string]outcome{
+				"Kappa":      {SourceBTTVChannel, []Source{Source7TVChannel, SourceTwitchChannel}, []bool{false, false}},
+				"catJAM":     {SourceBTTVChannel, []Source{}, []bool{}},
+				"peepoHappy": {SourceBTTVChannel, []Source{Source7TVChannel}, []bool{false}},
+			},
+		},
+		{
+			name:       "sources left out",
+			precedence: []Source{Source7TVChannel},
+			want: map[string]outcome{
+				"Kappa":      {Source7TVChannel, []Source{}, []bool{}},
+				"peepoHappy": {Source7TVChannel, []Source{}, []bool{}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			emotes := make([][]*datastructure.Emote, len(tc.precedence))
+			for i, s := range tc.precedence {
+				emotes[i] = sources[s]
+			}
+
+			result := Resolve(tc.precedence, emotes)
+			if len(result) != len(tc.want) {
+				t.Fatalf("resolved %d emotes, want %d", len(result), len(tc.want))
+			}
+			for i, r := range result {
+				if i > 0 && result[i-1].Emote.Name >= r.Emote.Name {
+					t.Errorf("%v is listed after %v", r.Emote.Name, result[i-1].Emote.Name)
+				}
+				checkOutcome(t, r, tc.want[r.Emote.Name])
+			}
+		})
+	}
+}
+
+func TestResolveOverrides(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		flag       int32
+		overridden []Source
+	}{
+		{"BTTV", datastructure.EmoteVisibilityOverrideBTTV, []Source{SourceBTTVChannel, SourceBTTVGlobal}},
+		{"FFZ", datastructure.EmoteVisibilityOverrideFFZ, []Source{SourceFFZChannel, SourceFFZGlobal}},
+		{"Twitch global", datastructure.EmoteVisibilityOverrideTwitchGlobal, []Source{SourceTwitchGlobal}},
+		{"Twitch subscriber", datastructure.EmoteVisibilityOverrideTwitchSubscriber, []Source{SourceTwitchChannel}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Every other source precedes the 7TV emote, so only the flag lets it win
+			precedence := []Source{}
+			emotes := [][]*datastructure.Emote{}
+			for _, s := range DefaultPrecedence {
+				if s.Provider() != "7TV" {
+					precedence = append(precedence, s)
+					emotes = append(emotes, []*datastructure.Emote{emote("Kappa", 0)})
+				}
+			}
+			precedence = append(precedence, Source7TVChannel)
+			emotes = append(emotes, []*datastructure.Emote{emote("Kappa", tc.flag)})
+
+			result := Resolve(precedence, emotes)
+			if len(result) != 1 {
+				t.Fatalf("resolved %d emotes, want 1", len(result))
+			}
+
+			// The earliest source which isn't overridden wins, the overridden ones are marked as such
+			want := outcome{}
+			for _, s := range precedence[:len(precedence)-1] {
+				isOverridden := false
+				for _, o := range tc.overridden {
+					isOverridden = isOverridden || o == s
+				}
+				if want.source == "" && !isOverridden {
+					want.source = s
+					continue
+				}
+				want.shadowed = append(want.shadowed, s)
+				want.overridden = append(want.overridden, isOverridden)
+			}
+			want.shadowed = append(want.shadowed, Source7TVChannel)
+			want.overridden = append(want.overridden, false)
+			checkOutcome(t, result[0], want)
+
+			// With the overridden sources alone, the 7TV emote wins
+			alone := []Source{}
+			aloneEmotes := [][]*datastructure.Emote{}
+			for _, s := range tc.overridden {
+				alone = append(alone, s)
+				aloneEmotes = append(aloneEmotes, []*datastructure.Emote{emote("Kappa", 0)})
+			}
+			alone = append(alone, Source7TVGlobal)
+			aloneEmotes = append(aloneEmotes, []*datastructure.Emote{emote("Kappa", tc.flag)})
+
+			overridden := make([]bool, len(tc.overridden))
+			for i := range overridden {
+				overridden[i] = true
+			}
+			checkOutcome(t, Resolve(alone, aloneEmotes)[0], outcome{Source7TVGlobal, tc.overridden, overridden})
+		})
+	}
+}
+
+func TestResolveOverrideFlagOutside7TV(t *testing.T) {
+	// Only 7TV emotes override others, the flag means nothing on another provider's emote
+	result := Resolve([]Source{SourceFFZChannel, SourceBTTVChannel}, [][]*datastructure.Emote{
+		{emote("Kappa", 0)},
+		{emote("Kappa", datastructure.EmoteVisibilityOverrideFFZ)},
+	})
+	checkOutcome(t, result[0], outcome{SourceFFZChannel, []Source{SourceBTTVChannel}, []bool{false}})
+}
+
+func TestParsePrecedence(t *testing.T) {
+	got, err := ParsePrecedence([]string{" 7tv:channel", "BTTV:GLOBAL "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != Source7TVChannel || got[1] != SourceBTTVGlobal {
+		t.Errorf("got %v", got)
+	}
+
+	for _, values := range [][]string{
+		{},
+		{"7TV:EVERYWHERE"},
+		{"7TV:CHANNEL", "7tv:channel"},
+	} {
+		if _, err := ParsePrecedence(values); err != ErrUnknownSource {
+			t.Errorf("%v: got %v, want %v", values, err, ErrUnknownSource)
+		}
+	}
+}
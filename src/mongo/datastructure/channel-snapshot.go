@@ -0,0 +1,34 @@
+package datastructure
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The version of the channel snapshot format. Documents of a later version can't be imported
+const ChannelSnapshotVersion int32 = 1
+
+// The state of a channel at a point in time: its emotes with their aliases, and its editors.
+// Snapshots are exported as documents, and kept as restore points before bulk changes
+type ChannelSnapshot struct {
+	ID        primitive.ObjectID      `json:"id" bson:"_id,omitempty"`
+	Version   int32                   `json:"version" bson:"version"`
+	ChannelID primitive.ObjectID      `json:"channel_id" bson:"channel_id"`
+	Reason    string                  `json:"reason,omitempty" bson:"reason,omitempty"` // Why a restore point was taken
+	CreatedBy *primitive.ObjectID     `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt time.Time               `json:"created_at" bson:"created_at"`
+	Emotes    []ChannelSnapshotEmote  `json:"emotes" bson:"emotes"`
+	Editors   []ChannelSnapshotEditor `json:"editors" bson:"editors"`
+}
+
+type ChannelSnapshotEmote struct {
+	ID    primitive.ObjectID `json:"id" bson:"id"`
+	Name  string             `json:"name" bson:"name"`                       // The emote's own name when the snapshot was taken
+	Alias string             `json:"alias,omitempty" bson:"alias,omitempty"` // The emote's name in the channel, if aliased
+}
+
+type ChannelSnapshotEditor struct {
+	ID          primitive.ObjectID `json:"id" bson:"id"`
+	Permissions int64              `json:"permissions" bson:"permissions"` // UserEditorPermission bitfield
+}
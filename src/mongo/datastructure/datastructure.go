@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/SevenTV/ServerGo/src/configure"
 	"github.com/SevenTV/ServerGo/src/mongo/cache"
 	"github.com/SevenTV/ServerGo/src/utils"
 	log "github.com/sirupsen/logrus"
@@ -24,6 +23,7 @@ type Emote struct {
 	Width            [4]int16             `json:"width" bson:"width"`   // The emote's width in pixels
 	Height           [4]int16             `json:"height" bson:"height"` // The emote's height in pixels
 	Animated         bool                 `json:"animated" bson:"animated"`
+	Attribution      *EmoteAttribution    `json:"attribution,omitempty" bson:"attribution,omitempty"` // The origin of an emote re-hosted from another provider
 
 	// ChannelCount is used during the popularity sort check, generated by a pipeline.
 	// It is not used anywhere else
@@ -120,7 +120,10 @@ type User struct {
 	ViewCount       int32               `json:"view_count" bson:"view_count"`
 	EmoteAlias      map[string]string   `json:"-" bson:"emote_alias"`           // Emote Alias - backend only
 	Badge           *primitive.ObjectID `json:"badge" bson:"badge"`             // User's badge, if any
-	EmoteSlots      int32               `json:"emote_slots" bson:"emote_slots"` // User's maximum channel emote slots
+	EmoteSlots      int32               `json:"emote_slots" bson:"emote_slots"` // Manual override of the user's base channel emote slots, or 0
+
+	EditorPermissions map[string]int64 `json:"-" bson:"editor_permissions"` // Editor ID -> UserEditorPermission bitfield
+	Lockdown          *UserLockdown    `json:"-" bson:"lockdown,omitempty"` // Set while the account is locked down as compromised
 
 	// Relational Data
 	Emotes            *[]*Emote       `json:"emotes" bson:"-"`
@@ -135,13 +138,52 @@ type User struct {
 	NotificationCount *int64          `json:"-" bson:"-"`
 }
 
-// Get the user's maximum emote slot count
-func (u *User) GetEmoteSlots() int32 {
-	if u.EmoteSlots == 0 {
-		return configure.Config.GetInt32("limits.meta.channel_emote_slots")
-	} else {
-		return u.EmoteSlots
+// A lockdown contains a compromised account: its sessions are invalidated and a scoped ban freezes its edits and uploads.
+// It can only be lifted once the owner has signed in again through Twitch
+type UserLockdown struct {
+	IssuedByID        primitive.ObjectID `json:"issued_by_id" bson:"issued_by_id"`
+	Reason            string             `json:"reason" bson:"reason"`
+	BanID             primitive.ObjectID `json:"ban_id" bson:"ban_id"` // The ban enforcing the lockdown
+	CreatedAt         time.Time          `json:"created_at" bson:"created_at"`
+	ReauthenticatedAt *time.Time         `json:"reauthenticated_at" bson:"reauthenticated_at"` // When the owner signed in again since
+}
+
+// Get the editor permissions held by a user in this channel
+// Editors without stored permissions predate granular permissions and receive the default set
+func (u *User) GetEditorPermissions(editorID primitive.ObjectID) (int64, bool) {
+	if !utils.ContainsObjectID(u.EditorIDs, editorID) {
+		return 0, false
+	}
+
+	if p, ok := u.EditorPermissions[editorID.Hex()]; ok {
+		return p, true
 	}
+	return UserEditorPermissionDefault, true
+}
+
+// Test whether an actor may perform a channel-scoped action on this user's channel
+func (u *User) CanEditChannel(actor *User, flag int64) bool {
+	if actor.ID == u.ID || actor.HasPermission(RolePermissionManageUsers) {
+		return true
+	}
+
+	p, ok := u.GetEditorPermissions(actor.ID)
+	return ok && utils.BitField.HasBits(p, flag)
+}
+
+// Test whether an actor may change or remove an editor of this user's channel, giving them the permissions passed.
+// Editors other than the owner must hold every permission the editor has now and is given
+func (u *User) CanManageEditor(actor *User, editorID primitive.ObjectID, permissions int64) bool {
+	if !u.CanEditChannel(actor, UserEditorPermissionManageEditors) {
+		return false
+	}
+	if actor.ID == u.ID || actor.HasPermission(RolePermissionManageUsers) {
+		return true
+	}
+
+	held, _ := u.GetEditorPermissions(actor.ID)
+	current, _ := u.GetEditorPermissions(editorID)
+	return utils.BitField.HasBits(held, current|permissions)
 }
 
 // Test whether a User has a permission flag
@@ -177,6 +219,12 @@ type Role struct {
 	Denied   int64               `json:"denied" bson:"denied"`
 	Default  bool                `json:"default,omitempty" bson:"default"`
 	Badge    *primitive.ObjectID `json:"badge,omitempty" bson:"badge,omitempty"`
+
+	// The amount of live emotes a user must own before their uploads skip moderator review.
+	// Falls back to the configured default if unset
+	EmoteReviewThreshold *int32 `json:"emote_review_threshold,omitempty" bson:"emote_review_threshold,omitempty"`
+	// The channel emote slots of the role's users, if more than the configured default
+	EmoteSlots int32 `json:"emote_slots,omitempty" bson:"emote_slots,omitempty"`
 }
 
 // Get a cached role by ID
@@ -212,10 +260,43 @@ const (
 	RolePermissionEditApplicationMeta                    // 2048 - (Elevated) Allows editing global app metadata, such as the active featured broadcast
 	RolePermissionManageEntitlements                     // 4096 - (Elevated) Allows granting and revoking entitlements to and from users
 	RolePermissionUseZeroWidthEmote                      // 8192 - Allows zero-width emotes to be enabled
+	RolePermissionManageFilters                          // 16384 - (Elevated) Allows managing the content filter rules
+	RolePermissionManageCosmetics                        // 32768 - (Elevated) Allows creating and editing badges and paints
 
 	RolePermissionAll int64 = (1 << iota) - 1
 )
 
+const (
+	UserEditorPermissionAddEmotes         int64 = 1 << iota // 1 - Allows adding emotes to the channel
+	UserEditorPermissionRemoveEmotes                        // 2 - Allows removing emotes from the channel
+	UserEditorPermissionAliasEmotes                         // 4 - Allows setting aliases on the channel's emotes
+	UserEditorPermissionUploadEmotes                        // 8 - Allows uploading emotes on behalf of the channel
+	UserEditorPermissionManageOwnedEmotes                   // 16 - Allows editing, deleting and restoring emotes owned by the channel
+	UserEditorPermissionManageEditors                       // 32 - Allows adding and removing other editors
+
+	UserEditorPermissionAll int64 = (1 << iota) - 1
+)
+
+// The permissions given to an editor when none are specified
+const UserEditorPermissionDefault = UserEditorPermissionAll &^ UserEditorPermissionManageEditors
+
+type EditorInvitation struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ChannelID   primitive.ObjectID `json:"channel_id" bson:"channel_id"`       // The channel the invitee would become an editor of
+	EditorID    primitive.ObjectID `json:"editor_id" bson:"editor_id"`         // The invited user
+	InvitedByID primitive.ObjectID `json:"invited_by_id" bson:"invited_by_id"` // The user who sent the invitation
+	Permissions int64              `json:"permissions" bson:"permissions"`     // The editor permissions granted upon acceptance
+	Status      int32              `json:"status" bson:"status"`
+	ExpireAt    time.Time          `json:"expire_at" bson:"expire_at"`
+}
+
+const (
+	EditorInvitationStatusPending int32 = iota
+	EditorInvitationStatusAccepted
+	EditorInvitationStatusDeclined
+	EditorInvitationStatusRevoked
+)
+
 const (
 	UserRankDefault   int32 = 0
 	UserRankModerator int32 = 1
@@ -227,16 +308,62 @@ type Ban struct {
 	UserID     *primitive.ObjectID `json:"user_id" bson:"user_id"`
 	Reason     string              `json:"reason" bson:"reason"`
 	IssuedByID *primitive.ObjectID `json:"issued_by_id" bson:"issued_by_id"`
-	ExpireAt   time.Time           `json:"expire_at" bson:"expire_at"`
+	ExpireAt   time.Time           `json:"expire_at" bson:"expire_at"` // Zero if the ban never expires
+	Active     bool                `json:"active" bson:"active"`       // False once the ban was lifted
+	Scope      int64               `json:"scope" bson:"scope"`         // The restricted actions, or zero for a full ban
+}
+
+// Whether the ban is currently in effect
+func (b *Ban) IsActive() bool {
+	return b.Active && (b.ExpireAt.IsZero() || b.ExpireAt.After(time.Now()))
+}
+
+// Whether the ban locks the user out of the platform entirely
+func (b *Ban) IsFull() bool {
+	return b.Scope == 0
+}
+
+const (
+	BanScopeUpload      int64 = 1 << iota // 1 - Block uploading emotes
+	BanScopeChannelEdit                   // 2 - Block editing any channel, including their own
+	BanScopeReport                        // 4 - Block filing reports
+
+	BanScopeAll int64 = (1 << iota) - 1
+)
+
+type BanAppeal struct {
+	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	BanID        primitive.ObjectID  `json:"ban_id" bson:"ban_id"`   // The appealed ban. A ban may only be appealed once
+	UserID       primitive.ObjectID  `json:"user_id" bson:"user_id"` // The banned user
+	Message      string              `json:"message" bson:"message"`
+	Status       int32               `json:"status" bson:"status"`
+	ReviewerID   *primitive.ObjectID `json:"reviewer_id" bson:"reviewer_id"`
+	ReviewerNote string              `json:"reviewer_note" bson:"reviewer_note"`
+	ReviewedAt   *time.Time          `json:"reviewed_at" bson:"reviewed_at"`
 }
 
+const (
+	BanAppealStatusPending int32 = iota
+	BanAppealStatusAccepted
+	BanAppealStatusRejected
+)
+
 type AuditLog struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Type      int32              `json:"type" bson:"type"`
-	Target    *Target            `json:"target" bson:"target"`
-	Changes   []*AuditLogChange  `json:"changes" bson:"changes"`
-	Reason    *string            `json:"reason" bson:"reason"`
-	CreatedBy primitive.ObjectID `json:"action_user_id" bson:"action_user"`
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Type      int32               `json:"type" bson:"type"`
+	Target    *Target             `json:"target" bson:"target"`
+	Changes   []*AuditLogChange   `json:"changes" bson:"changes"`
+	Reason    *string             `json:"reason" bson:"reason"`
+	CreatedBy primitive.ObjectID  `json:"action_user_id" bson:"action_user"`
+	RevertOf  *primitive.ObjectID `json:"revert_of,omitempty" bson:"revert_of,omitempty"` // The entry undone by this entry
+
+	// Chain fields, set when the entry is written. Entries written before chaining was introduced have none
+	Timestamp time.Time `json:"timestamp" bson:"timestamp,omitempty"`
+	Node      string    `json:"node,omitempty" bson:"node,omitempty"`
+	Pod       string    `json:"pod,omitempty" bson:"pod,omitempty"`
+	Seq       int64     `json:"seq,omitempty" bson:"seq,omitempty"`
+	PrevHash  string    `json:"prev_hash,omitempty" bson:"prev_hash,omitempty"`
+	Hash      string    `json:"hash,omitempty" bson:"hash,omitempty"` // SHA-256 of the previous hash and this entry without its hash
 }
 
 type Target struct {
@@ -253,9 +380,13 @@ type AuditLogChange struct {
 type Report struct {
 	ID         primitive.ObjectID  `json:"id" bson:"_id"`
 	ReporterID *primitive.ObjectID `json:"reporter_id" bson:"reporter_id"`
-	Reason     string              `json:"reason" bson:"target"`
+	Reason     string              `json:"reason" bson:"reason"`
 	Target     *Target             `json:"target" bson:"target"`
-	Cleared    bool                `json:"cleared" bson:"cleared"`
+	Cleared    bool                `json:"cleared" bson:"cleared"` // Whether the report was closed, either resolved or rejected
+	Status     int32               `json:"status" bson:"status"`
+	AssigneeID *primitive.ObjectID `json:"assignee_id" bson:"assignee_id"`
+	Notes      []*ReportNote       `json:"notes" bson:"notes"` // Internal notes, only visible to moderators
+	Resolution *ReportResolution   `json:"resolution" bson:"resolution"`
 
 	ETarget      *Emote       `json:"e_target" bson:"-"`
 	UTarget      *User        `json:"u_target" bson:"-"`
@@ -263,14 +394,75 @@ type Report struct {
 	AuditEntries *[]*AuditLog `json:"audit_entries" bson:"-"`
 }
 
+type FilterRule struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Kind        string             `json:"kind" bson:"kind"`
+	Pattern     string             `json:"pattern" bson:"pattern"`
+	Action      int32              `json:"action" bson:"action"`       // What happens to matching content. Unused by allowlist rules
+	Normalize   bool               `json:"normalize" bson:"normalize"` // Whether to undo leetspeak before matching
+	Enabled     bool               `json:"enabled" bson:"enabled"`
+	Note        string             `json:"note" bson:"note"`
+	CreatedByID primitive.ObjectID `json:"created_by_id" bson:"created_by_id"`
+}
+
+const (
+	FilterRuleKindWord      = "WORD"      // Matches a whole word, splitting on separators and case changes
+	FilterRuleKindSubstring = "SUBSTRING" // Matches anywhere, ignoring case and separators
+	FilterRuleKindRegex     = "REGEX"     // Matches a regular expression against the raw content, or the lowercase content with leetspeak undone if set to normalize
+	FilterRuleKindAllow     = "ALLOW"     // Exempts a term from every other rule
+)
+
+const (
+	FilterActionNone int32 = iota
+	FilterActionReview
+	FilterActionReject
+)
+
+type ReportNote struct {
+	AuthorID  primitive.ObjectID `json:"author_id" bson:"author_id"`
+	Content   string             `json:"content" bson:"content"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type ReportResolution struct {
+	Action       string             `json:"action" bson:"action"`
+	ResolvedByID primitive.ObjectID `json:"resolved_by_id" bson:"resolved_by_id"`
+	ResolvedAt   time.Time          `json:"resolved_at" bson:"resolved_at"`
+	Reason       string             `json:"reason" bson:"reason"`
+}
+
+const (
+	ReportStatusOpen int32 = iota
+	ReportStatusAssigned
+	ReportStatusResolved
+	ReportStatusRejected
+)
+
+const (
+	ReportResolutionActionNone        = "NONE"
+	ReportResolutionActionEmoteDelete = "EMOTE_DELETE"
+	ReportResolutionActionUserBan     = "USER_BAN"
+)
+
+// The current status of a report. Reports cleared before statuses existed count as resolved
+func (r *Report) GetStatus() int32 {
+	if r.Cleared && r.Status < ReportStatusResolved {
+		return ReportStatusResolved
+	}
+	return r.Status
+}
+
 const (
 	// Emotes (1-19)
 	AuditLogTypeEmoteCreate     = 1
 	AuditLogTypeEmoteDelete     = 2
 	AuditLogTypeEmoteDisable    = 3
 	AuditLogTypeEmoteEdit       = 4
-	AuditLogTypeEmoteUndoDelete = 4
 	AuditLogTypeEmoteMerge      = 5
+	AuditLogTypeEmoteApprove    = 6
+	AuditLogTypeEmoteReject     = 7
+	AuditLogTypeEmoteUndoDelete = 8
+	AuditLogTypeEmoteImport     = 9
 
 	// Auth (20-29)
 	AuditLogTypeAuthIn  = 20
@@ -287,28 +479,87 @@ const (
 	AuditLogTypeUserChannelEditorAdd    = 37
 	AuditLogTypeUserChannelEditorRemove = 38
 	AuditLogTypeUserChannelEmoteEdit    = 39
+	AuditLogTypeUserChannelEditorInvite = 40
+	AuditLogTypeUserBanAppeal           = 41
+	AuditLogTypeUserBanAppealReview     = 42
+	AuditLogTypeUserLockdown            = 43
+	AuditLogTypeUserLockdownReauth      = 44
+	AuditLogTypeUserLockdownLift        = 45
+	AuditLogTypeUserEntitlementGrant    = 46
+	AuditLogTypeUserEntitlementRevoke   = 47
+	AuditLogTypeUserBadgeSelect         = 48
+	AuditLogTypeUserPaintSelect         = 49
+	AuditLogTypeUserChannelEmoteBatch   = 50
+	AuditLogTypeUserChannelRestore      = 51
+	AuditLogTypeUserChannelEmoteImport  = 52
+	AuditLogTypeUserSubscription        = 53
 
 	// Admin (70-89)
-	AuditLogTypeAppMaintenanceMode = 70
-	AuditLogTypeAppRouteLock       = 71
-	AuditLogTypeAppLogsView        = 72
-	AuditLogTypeAppScale           = 73
-	AuditLogTypeAppNodeCreate      = 74
-	AuditLogTypeAppNodeDelete      = 75
-	AuditLogTypeAppNodeJoin        = 75
-	AuditLogTypeAppNodeUnref       = 76
+	AuditLogTypeAppMaintenanceMode  = 70
+	AuditLogTypeAppRouteLock        = 71
+	AuditLogTypeAppLogsView         = 72
+	AuditLogTypeAppScale            = 73
+	AuditLogTypeAppNodeCreate       = 74
+	AuditLogTypeAppNodeDelete       = 75
+	AuditLogTypeAppNodeJoin         = 75
+	AuditLogTypeAppNodeUnref        = 76
+	AuditLogTypeAppFilterRuleCreate = 77
+	AuditLogTypeAppFilterRuleEdit   = 78
+	AuditLogTypeAppFilterRuleDelete = 79
+	AuditLogTypeAppBadgeCreate      = 80
+	AuditLogTypeAppBadgeEdit        = 81
+	AuditLogTypeAppPaintCreate      = 82
+	AuditLogTypeAppPaintEdit        = 83
+	AuditLogTypeAppPlanCreate       = 84
+	AuditLogTypeAppPlanEdit         = 85
 
 	// Reports (90-99)
-	AuditLogTypeReport      = 90
-	AuditLogTypeReportClear = 91
+	AuditLogTypeReport       = 90
+	AuditLogTypeReportClear  = 91
+	AuditLogTypeReportAssign = 92
+	AuditLogTypeReportReopen = 93
 )
 
 type Badge struct {
 	ID      primitive.ObjectID   `json:"id" bson:"_id"`
-	Tooltip string               `json:"tooltip"`
-	Name    string               `json:"name"`
-	Users   []primitive.ObjectID `json:"users"`
-	Misc    bool                 `json:"misc,omitempty"`
+	Tooltip string               `json:"tooltip" bson:"tooltip"`
+	Name    string               `json:"name" bson:"name"`
+	Users   []primitive.ObjectID `json:"users" bson:"users,omitempty"` // Legacy holders, which are now granted badge entitlements instead
+	Misc    bool                 `json:"misc,omitempty" bson:"misc"`
+}
+
+// A paint colours the names of the users displaying it
+type Paint struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Name     string             `json:"name" bson:"name"`
+	Function PaintFunction      `json:"function" bson:"function"`
+	Color    *int32             `json:"color" bson:"color"`         // The colour of a solid paint, also shown by clients which can't render the fill
+	Stops    []PaintStop        `json:"stops" bson:"stops"`         // The colour stops of a gradient
+	Angle    int32              `json:"angle" bson:"angle"`         // The angle of a gradient, in degrees
+	Repeat   bool               `json:"repeat" bson:"repeat"`       // Whether a gradient repeats past its last stop
+	ImageURL string             `json:"image_url" bson:"image_url"` // The image filling an image paint
+	Shadows  []PaintShadow      `json:"shadows" bson:"shadows"`
+}
+
+// How a paint fills the text
+type PaintFunction string
+
+var (
+	PaintFunctionSolid          = PaintFunction("SOLID")
+	PaintFunctionLinearGradient = PaintFunction("LINEAR_GRADIENT")
+	PaintFunctionImage          = PaintFunction("IMAGE")
+)
+
+type PaintStop struct {
+	At    float64 `json:"at" bson:"at"` // Where the stop is along the gradient, from 0 to 1
+	Color int32   `json:"color" bson:"color"`
+}
+
+type PaintShadow struct {
+	OffsetX float64 `json:"x_offset" bson:"x_offset"`
+	OffsetY float64 `json:"y_offset" bson:"y_offset"`
+	Radius  float64 `json:"radius" bson:"radius"`
+	Color   int32   `json:"color" bson:"color"`
 }
 
 type Meta struct {
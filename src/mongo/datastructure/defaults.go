@@ -20,3 +20,21 @@ var DeletedUser *User = &User{
 }
 
 var deletedUserID, _ = primitive.ObjectIDFromHex("000000000000000000000001")
+
+// The user credited with actions the server performs on its own, such as lifting expired bans
+var SystemUser *User = &User{
+	ID:          systemUserID,
+	Login:       "*system",
+	DisplayName: "System",
+}
+
+var systemUserID, _ = primitive.ObjectIDFromHex("000000000000000000000002")
+
+// The owner of emotes re-hosted from other providers, which are credited to their author through their attribution
+var ImportedUser *User = &User{
+	ID:          importedUserID,
+	Login:       "*imported",
+	DisplayName: "Imported",
+}
+
+var importedUserID, _ = primitive.ObjectIDFromHex("000000000000000000000003")
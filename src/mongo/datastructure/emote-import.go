@@ -0,0 +1,65 @@
+package datastructure
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The origin of an emote re-hosted from another provider, crediting its author there
+type EmoteAttribution struct {
+	Provider          string    `json:"provider" bson:"provider"`
+	ProviderID        string    `json:"provider_id" bson:"provider_id"`
+	AuthorLogin       string    `json:"author_login" bson:"author_login"`
+	AuthorDisplayName string    `json:"author_display_name,omitempty" bson:"author_display_name,omitempty"`
+	ImportedAt        time.Time `json:"imported_at" bson:"imported_at"`
+}
+
+// A job matching a channel's emotes on other providers to 7TV emotes.
+// Once processed, its items form a plan which the channel's owner reviews and confirms
+type EmoteImport struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ChannelID primitive.ObjectID `json:"channel_id" bson:"channel_id"`
+	CreatedBy primitive.ObjectID `json:"created_by" bson:"created_by"`
+	Providers []string           `json:"providers" bson:"providers"`
+	Status    EmoteImportStatus  `json:"status" bson:"status"`
+	Items     []EmoteImportItem  `json:"items" bson:"items"`
+	Error     string             `json:"error,omitempty" bson:"error,omitempty"` // Why the job failed
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+type EmoteImportStatus string
+
+var (
+	EmoteImportStatusPending    = EmoteImportStatus("PENDING")    // Waiting to be processed
+	EmoteImportStatusProcessing = EmoteImportStatus("PROCESSING") // Being matched by a task
+	EmoteImportStatusReady      = EmoteImportStatus("READY")      // The plan awaits confirmation
+	EmoteImportStatusApplied    = EmoteImportStatus("APPLIED")    // The plan was confirmed and applied to the channel
+	EmoteImportStatusFailed     = EmoteImportStatus("FAILED")     // The provider's emotes could not be fetched
+)
+
+// An emote of another provider, and what the import proposes for it
+type EmoteImportItem struct {
+	Provider          string              `json:"provider" bson:"provider"`
+	ProviderID        string              `json:"provider_id" bson:"provider_id"`
+	Name              string              `json:"name" bson:"name"`
+	ImageURL          string              `json:"image_url" bson:"image_url"` // The emote's largest image on the provider's CDN
+	AuthorLogin       string              `json:"author_login,omitempty" bson:"author_login,omitempty"`
+	AuthorDisplayName string              `json:"author_display_name,omitempty" bson:"author_display_name,omitempty"`
+	Proposal          EmoteImportProposal `json:"proposal" bson:"proposal"`
+	EmoteID           *primitive.ObjectID `json:"emote_id,omitempty" bson:"emote_id,omitempty"`     // The 7TV emote the item was matched to or re-hosted as
+	Similarity        float64             `json:"similarity,omitempty" bson:"similarity,omitempty"` // How alike the matched emote looks, from 0 to 1
+	Accepted          bool                `json:"accepted" bson:"accepted"`
+}
+
+type EmoteImportProposal string
+
+var (
+	EmoteImportProposalMatched      = EmoteImportProposal("MATCHED")       // A live 7TV emote of the same name looks alike, and can be added
+	EmoteImportProposalAlreadyAdded = EmoteImportProposal("ALREADY_ADDED") // The channel has an emote of the same name which looks alike
+	EmoteImportProposalNameTaken    = EmoteImportProposal("NAME_TAKEN")    // The channel has a different emote of the same name
+	EmoteImportProposalUnmatched    = EmoteImportProposal("UNMATCHED")     // No 7TV emote looks alike. It can be re-hosted if its author is known
+	EmoteImportProposalUnavailable  = EmoteImportProposal("UNAVAILABLE")   // The provider's image could not be fetched
+	EmoteImportProposalRehosted     = EmoteImportProposal("REHOSTED")      // The emote was re-hosted as a new 7TV emote
+)
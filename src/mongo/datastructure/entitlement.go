@@ -1,6 +1,9 @@
 package datastructure
 
 import (
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/configure"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -18,6 +21,23 @@ type Entitlement struct {
 	UserID primitive.ObjectID `json:"user_id" bson:"user_id"`
 	// Wether this entitlement is currently inactive
 	Disabled bool `json:"disabled,omitempty" bson:"disabled,omitempty"`
+	// When the entitlement takes effect, or nil if it did from its creation
+	StartsAt *time.Time `json:"starts_at,omitempty" bson:"starts_at,omitempty"`
+	// Whether the entitlement was granted to start later, and its user is yet to be told
+	Pending bool `json:"pending,omitempty" bson:"pending,omitempty"`
+	// When the entitlement ends, or nil if it never does
+	EndsAt *time.Time `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	// Whether the entitlement has ended and its user was told
+	Expired bool `json:"expired,omitempty" bson:"expired,omitempty"`
+	// The subscription which granted the entitlement, and keeps its period in sync
+	SubscriptionID *primitive.ObjectID `json:"subscription_id,omitempty" bson:"subscription_id,omitempty"`
+}
+
+// Whether the entitlement is in effect at a point in time
+func (e *Entitlement) IsActive(at time.Time) bool {
+	return !e.Disabled && !e.Expired &&
+		(e.StartsAt == nil || !e.StartsAt.After(at)) &&
+		(e.EndsAt == nil || e.EndsAt.After(at))
 }
 
 // A string representing an Entitlement Kind
@@ -28,6 +48,7 @@ var (
 	EntitlementKindBadge        = EntitlementKind("BADGE")        // Badge Entitlement
 	EntitlementKindRole         = EntitlementKind("ROLE")         // Role Entitlement
 	EntitlementKindEmoteSet     = EntitlementKind("EMOTE_SET")    // Emote Set Entitlement
+	EntitlementKindPaint        = EntitlementKind("PAINT")        // Paint Entitlement
 )
 
 // (Data) Subscription binding in an Entitlement
@@ -35,6 +56,8 @@ type EntitledSubscription struct {
 	ID string `json:"id" bson:"-"`
 	// The ID of the subscription
 	ObjectReference primitive.ObjectID `json:"-" bson:"ref"`
+	// Channel emote slots added by the subscription's plan
+	EmoteSlots int32 `json:"emote_slots" bson:"emote_slots,omitempty"`
 }
 
 // (Data) Badge binding in an Entitlement
@@ -50,15 +73,32 @@ type EntitledRole struct {
 	ObjectReference primitive.ObjectID `json:"-" bson:"ref"`
 }
 
+// (Data) Paint binding in an Entitlement
+type EntitledPaint struct {
+	ID              string             `json:"id" bson:"-"`
+	ObjectReference primitive.ObjectID `json:"-" bson:"ref"`
+	Selected        bool               `json:"selected" bson:"selected"`
+}
+
 // (Data) Emote Set binding in an Entitlement
 type EntitledEmoteSet struct {
 	ID              string               `json:"id" bson:"-"`
 	ObjectReference primitive.ObjectID   `json:"-" bson:"ref"`
 	UnicodeTag      string               `json:"unicode_tag" bson:"unicode_tag"`
 	EmoteIDs        []primitive.ObjectID `json:"emote_ids" bson:"emotes"`
+	// The maximum count of emotes usable from the set, or 0 for the default
+	Slots int32 `json:"slots" bson:"slots,omitempty"`
 
 	// Relational
 
 	// A list of emotes for this emote set entitlement
 	Emotes []*Emote `json:"emotes" bson:"-"`
 }
+
+// Get the maximum count of emotes usable from the set
+func (e *EntitledEmoteSet) GetSlots() int32 {
+	if e.Slots == 0 {
+		return configure.Config.GetInt32("limits.meta.personal_emote_slots")
+	}
+	return e.Slots
+}
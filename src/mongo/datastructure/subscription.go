@@ -0,0 +1,85 @@
+package datastructure
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A plan users may subscribe to through a payment provider, granting them perks while the subscription lasts
+type SubscriptionPlan struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name     string             `json:"name" bson:"name"`
+	Interval BillingInterval    `json:"interval" bson:"interval"` // How often the subscription renews
+	Price    int32              `json:"price" bson:"price"`       // The price of a billing period, in the smallest unit of the currency
+	Currency string             `json:"currency" bson:"currency"` // An ISO 4217 currency code
+	Perks    SubscriptionPerks  `json:"perks" bson:"perks"`
+	// The ID of the plan at each payment provider
+	ProviderPlans map[string]string `json:"provider_plans" bson:"provider_plans"`
+	// Whether the plan is no longer offered. Existing subscriptions keep it until they end
+	Disabled bool `json:"disabled,omitempty" bson:"disabled,omitempty"`
+}
+
+// The perks granted through entitlements to the users subscribed to a plan
+type SubscriptionPerks struct {
+	RoleID     *primitive.ObjectID `json:"role_id" bson:"role_id,omitempty"`
+	BadgeID    *primitive.ObjectID `json:"badge_id" bson:"badge_id,omitempty"`
+	EmoteSlots int32               `json:"emote_slots" bson:"emote_slots,omitempty"` // Channel emote slots added to the user's own
+}
+
+// How often a subscription renews
+type BillingInterval string
+
+var (
+	BillingIntervalMonth = BillingInterval("MONTH")
+	BillingIntervalYear  = BillingInterval("YEAR")
+)
+
+// A user's subscription to a plan, kept up to date by the webhooks of its payment provider
+type Subscription struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	PlanID     primitive.ObjectID `json:"plan_id" bson:"plan_id"`
+	Provider   string             `json:"provider" bson:"provider"`
+	ProviderID string             `json:"provider_id" bson:"provider_id"` // The ID of the subscription at its provider
+	Status     SubscriptionStatus `json:"status" bson:"status"`
+	StartedAt  time.Time          `json:"started_at" bson:"started_at"`
+	// The billing period paid for last
+	PeriodStart time.Time `json:"period_start" bson:"period_start"`
+	PeriodEnd   time.Time `json:"period_end" bson:"period_end"`
+	// When a past due subscription ends, unless a payment succeeds
+	GraceEndsAt *time.Time `json:"grace_ends_at,omitempty" bson:"grace_ends_at,omitempty"`
+	CanceledAt  *time.Time `json:"canceled_at,omitempty" bson:"canceled_at,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty" bson:"ended_at,omitempty"`
+	// When the provider emitted the latest event applied to the subscription
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Get when the perks of the subscription end, unless it renews. Grace is how long a renewal may be late
+func (s *Subscription) EntitledUntil(grace time.Duration) time.Time {
+	switch s.Status {
+	case SubscriptionStatusActive:
+		return s.PeriodEnd.Add(grace)
+	case SubscriptionStatusCanceled:
+		return s.PeriodEnd
+	case SubscriptionStatusPastDue:
+		if s.GraceEndsAt != nil {
+			return *s.GraceEndsAt
+		}
+		return s.PeriodEnd.Add(grace)
+	}
+	if s.EndedAt != nil {
+		return *s.EndedAt
+	}
+	return s.PeriodEnd
+}
+
+// The state of a subscription
+type SubscriptionStatus string
+
+var (
+	SubscriptionStatusActive   = SubscriptionStatus("ACTIVE")   // Paid for, and renews at the end of the period
+	SubscriptionStatusCanceled = SubscriptionStatus("CANCELED") // Paid for, but ends with the period
+	SubscriptionStatusPastDue  = SubscriptionStatus("PAST_DUE") // A renewal failed, and the subscription ends after a grace period unless it is paid
+	SubscriptionStatusEnded    = SubscriptionStatus("ENDED")    // No longer grants its perks
+)
@@ -25,6 +25,8 @@ var Database *mongo.Database
 
 var ErrNoDocuments = mongo.ErrNoDocuments
 
+var IsDuplicateKeyError = mongo.IsDuplicateKeyError
+
 type Pipeline = mongo.Pipeline
 type WriteModel = mongo.WriteModel
 
@@ -32,6 +34,25 @@ func NewUpdateOneModel() *mongo.UpdateOneModel {
 	return mongo.NewUpdateOneModel()
 }
 
+// WithTransaction: Run fn in a transaction. fn may run more than once, as transient errors are retried
+func WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := Database.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// InTransaction: Whether the context belongs to a transaction
+func InTransaction(ctx context.Context) bool {
+	return mongo.SessionFromContext(ctx) != nil
+}
+
 func init() {
 	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*25)
 	defer cancel()
@@ -52,6 +73,18 @@ func init() {
 
 	Database = client.Database(configure.Config.GetString("mongo_db"))
 
+	// Audited changes are written in transactions, which a standalone server doesn't support
+	topology := struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}{}
+	if err := Database.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&topology); err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
+	if topology.SetName == "" && topology.Msg != "isdbgrid" {
+		log.Fatal("mongo, a replica set or sharded cluster is required for transactions, but mongo_uri points to a standalone server")
+	}
+
 	_, err = Collection(CollectionNameEmotes).Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.M{"name": 1}},
 		{Keys: bson.M{"owner": 1}},
@@ -87,9 +120,13 @@ func init() {
 	}
 
 	_, err = Collection(CollectionNameAudit).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"action_user": 1}},
 		{Keys: bson.M{"type": 1}},
 		{Keys: bson.M{"target.type": 1}},
 		{Keys: bson.M{"target.id": 1}},
+		// An entry can only be reverted once
+		{Keys: bson.M{"revert_of": 1}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"revert_of": bson.M{"$exists": true}})},
+		{Keys: bson.M{"seq": 1}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"seq": bson.M{"$exists": true}})},
 	})
 	if err != nil {
 		log.WithError(err).Fatal("mongo")
@@ -99,6 +136,7 @@ func init() {
 		{Keys: bson.M{"reporter_id": 1}},
 		{Keys: bson.M{"target.type": 1}},
 		{Keys: bson.M{"target.id": 1}},
+		{Keys: bson.M{"status": 1}},
 	})
 	if err != nil {
 		log.WithError(err).Fatal("mongo")
@@ -111,6 +149,13 @@ func init() {
 		log.WithError(err).Fatal("mongo")
 	}
 
+	_, err = Collection(CollectionNamePaints).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"name": 1}},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
+
 	_ = Database.CreateCollection(ctx, "notifications")
 	_, err = Collection(CollectionNameNotificationsRead).Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.M{"target": 1}},
@@ -123,7 +168,63 @@ func init() {
 	_, err = Collection(CollectionNameEntitlements).Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.M{"user_id": 1}},
 		{Keys: bson.M{"data.ref": 1}},
+		{Keys: bson.M{"ends_at": 1}, Options: options.Index().SetPartialFilterExpression(bson.M{"ends_at": bson.M{"$exists": true}})},
+		{Keys: bson.M{"subscription_id": 1}, Options: options.Index().SetPartialFilterExpression(bson.M{"subscription_id": bson.M{"$exists": true}})},
+		{Keys: bson.M{"starts_at": 1}, Options: options.Index().SetPartialFilterExpression(bson.M{"pending": true})},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
+
+	_, err = Collection(CollectionNameSubscriptions).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "provider", Value: 1}, {Key: "provider_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"user_id": 1}},
+		{Keys: bson.M{"plan_id": 1}},
+		{Keys: bson.M{"status": 1}},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
+
+	_, err = Collection(CollectionNameEditorInvitations).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"channel_id": 1}},
+		{Keys: bson.M{"editor_id": 1}},
+		{Keys: bson.M{"expire_at": 1}, Options: options.Index().SetExpireAfterSeconds(int32(time.Hour * 24 * 7 / time.Second))},
+		// A channel has at most one pending invitation per user
+		{
+			Keys:    bson.D{{Key: "channel_id", Value: 1}, {Key: "editor_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": datastructure.EditorInvitationStatusPending}),
+		},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
+
+	_, err = Collection(CollectionNameRestorePoints).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "channel_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.M{"created_at": 1}, Options: options.Index().SetExpireAfterSeconds(int32(time.Hour * 24 * 30 / time.Second))},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
+
+	_, err = Collection(CollectionNameEmoteImports).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "channel_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: 1}}},
+		{Keys: bson.M{"created_at": 1}, Options: options.Index().SetExpireAfterSeconds(int32(time.Hour * 24 * 7 / time.Second))},
 	})
+	if err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
+
+	_, err = Collection(CollectionNameBanAppeals).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"ban_id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"user_id": 1}},
+		{Keys: bson.M{"status": 1}},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("mongo")
+	}
 }
 
 func Collection(name CollectionName) *mongo.Collection {
@@ -143,6 +244,14 @@ var (
 	CollectionNameEntitlements      = CollectionName("entitlements")
 	CollectionNameNotifications     = CollectionName("notifications")
 	CollectionNameNotificationsRead = CollectionName("notifications_read")
+	CollectionNameEditorInvitations = CollectionName("editor_invitations")
+	CollectionNameBanAppeals        = CollectionName("ban_appeals")
+	CollectionNameFilterRules       = CollectionName("filter_rules")
+	CollectionNamePaints            = CollectionName("paints")
+	CollectionNamePlans             = CollectionName("subscription_plans")
+	CollectionNameSubscriptions     = CollectionName("subscriptions")
+	CollectionNameRestorePoints     = CollectionName("channel_restore_points")
+	CollectionNameEmoteImports      = CollectionName("emote_imports")
 )
 
 func HexIDSliceToObjectID(arr []string) []primitive.ObjectID {
@@ -75,6 +75,12 @@ type EventApiV1ChannelEmotes struct {
 	Emote   *EventApiV1ChannelEmotesEmote `json:"emote"`
 }
 
+type EventApiV1ChannelEmotesBatch struct {
+	Channel string                    `json:"channel"`
+	Actor   string                    `json:"actor"`
+	Changes []EventApiV1ChannelEmotes `json:"changes"`
+}
+
 type EventApiV1ChannelEmotesEmote struct {
 	Name       string                            `json:"name"`
 	Visibility int32                             `json:"visibility"`
@@ -2,6 +2,8 @@ package actions
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -44,3 +46,31 @@ type UserBuilder struct {
 }
 
 var Users users = users{}
+
+type bans struct{}
+
+var Bans bans = bans{}
+
+type badges struct{}
+
+var Badges badges = badges{}
+
+type paints struct{}
+
+var Paints paints = paints{}
+
+type lockdowns struct{}
+
+var Lockdowns lockdowns = lockdowns{}
+
+type filter struct {
+	mx        sync.Mutex
+	compiled  *compiledFilterRules
+	fetchedAt time.Time
+}
+
+var Filter = &filter{}
+
+type audit struct{}
+
+var Audit audit = audit{}
@@ -0,0 +1,488 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The audit log types which can be reverted, and the type recorded for their revert.
+// Removed editors aren't put back, as editors join a channel by accepting an invitation
+var revertibleAuditLogTypes = map[int32]int32{
+	datastructure.AuditLogTypeEmoteEdit:              datastructure.AuditLogTypeEmoteEdit,
+	datastructure.AuditLogTypeUserChannelEmoteAdd:    datastructure.AuditLogTypeUserChannelEmoteRemove,
+	datastructure.AuditLogTypeUserChannelEmoteRemove: datastructure.AuditLogTypeUserChannelEmoteAdd,
+	datastructure.AuditLogTypeUserChannelEmoteEdit:   datastructure.AuditLogTypeUserChannelEmoteEdit,
+	datastructure.AuditLogTypeUserChannelEmoteBatch:  datastructure.AuditLogTypeUserChannelEmoteBatch,
+	datastructure.AuditLogTypeUserChannelRestore:     datastructure.AuditLogTypeUserChannelRestore,
+	datastructure.AuditLogTypeUserChannelEmoteImport: datastructure.AuditLogTypeUserChannelEmoteBatch,
+	datastructure.AuditLogTypeUserChannelEditorAdd:   datastructure.AuditLogTypeUserChannelEditorRemove,
+}
+
+// RevertibleAuditLogTypes: The audit log types which can be reverted
+func RevertibleAuditLogTypes() []int32 {
+	types := make([]int32, 0, len(revertibleAuditLogTypes))
+	for t := range revertibleAuditLogTypes {
+		types = append(types, t)
+	}
+	return types
+}
+
+// A reason an entry can't be reverted
+type revertSkip struct {
+	reason string
+}
+
+func (e *revertSkip) Error() string {
+	return e.reason
+}
+
+// Revert: Undo the changes recorded by audit log entries
+//
+// Entries are reverted one at a time, most recent first, each recording a new entry which references it.
+// An entry is skipped when its target was changed again since, so later changes are never overwritten.
+// With DryRun nothing is written, and the results show what the revert would do
+func (a audit) Revert(ctx context.Context, opts RevertOptions) ([]*RevertResult, error) {
+	results := make([]*RevertResult, len(opts.Logs))
+	preview := map[string]bson.D{} // The documents as they would be after the reverts, when previewing
+
+	for i, l := range opts.Logs {
+		result := &RevertResult{Log: l}
+		results[i] = result
+
+		if skip, err := a.checkRevert(ctx, l, opts.CanRevert); err != nil {
+			return nil, err
+		} else if skip != "" {
+			result.Skipped = skip
+			continue
+		}
+
+		collection := mongo.CollectionName(l.Target.Type)
+		previewKey := l.Target.Type + ":" + l.Target.ID.Hex()
+		if opts.DryRun {
+			before, ok := preview[previewKey]
+			if !ok {
+				before = bson.D{}
+				if err := mongo.Collection(collection).FindOne(ctx, bson.M{"_id": l.Target.ID}).Decode(&before); err != nil {
+					if err == mongo.ErrNoDocuments {
+						result.Skipped = "The target no longer exists"
+						continue
+					}
+					return nil, err
+				}
+			}
+
+			after, err := revertDocument(before, l)
+			if err == nil {
+				err = checkRevertSlots(ctx, l, before, after)
+			}
+			if skip, ok := err.(*revertSkip); ok {
+				result.Skipped = skip.reason
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if result.Changes, err = Diff(before, after); err != nil {
+				return nil, err
+			}
+
+			preview[previewKey] = after
+			continue
+		}
+
+		before := bson.D{}
+		after := bson.D{}
+		reason := fmt.Sprintf("Revert of %v", l.ID.Hex())
+		if opts.Reason != "" {
+			reason += ": " + opts.Reason
+		}
+		changes, err := a.Mutate(ctx, AuditedMutation{
+			Actor:    opts.Actor,
+			Type:     revertibleAuditLogTypes[l.Type],
+			Target:   l.Target,
+			Reason:   &reason,
+			RevertOf: &l.ID,
+			Before:   &before,
+			After:    &after,
+			Apply: func(ctx context.Context) error {
+				// The document is read in the transaction, so it can't change between the check and the write
+				if err := mongo.Collection(collection).FindOne(ctx, bson.M{"_id": l.Target.ID}).Decode(&before); err != nil {
+					if err == mongo.ErrNoDocuments {
+						return &revertSkip{"The target no longer exists"}
+					}
+					return err
+				}
+
+				doc, err := revertDocument(before, l)
+				if err != nil {
+					return err
+				}
+				if err := checkRevertSlots(ctx, l, before, doc); err != nil {
+					return err
+				}
+				after = doc
+
+				update := revertUpdate(after, l)
+				if len(update) == 0 {
+					return nil
+				}
+				_, err = mongo.Collection(collection).UpdateOne(ctx, bson.M{"_id": l.Target.ID}, update)
+				return err
+			},
+			Events: func(changes []*datastructure.AuditLogChange) []AuditedEvent {
+				return revertEvents(l.Target, after, changes, opts.Actor)
+			},
+		})
+		if skip, ok := err.(*revertSkip); ok {
+			result.Skipped = skip.reason
+			continue
+		} else if errors.Is(err, ErrAuditAlreadyReverted) {
+			result.Skipped = "Already reverted"
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		result.Changes = changes
+		result.Reverted = len(changes) > 0
+		if !result.Reverted {
+			result.Skipped = "Nothing to revert"
+		}
+	}
+
+	return results, nil
+}
+
+// Find why an entry can't be reverted, if it can't
+func (audit) checkRevert(ctx context.Context, l *datastructure.AuditLog, canRevert func(l *datastructure.AuditLog) bool) (string, error) {
+	if l.Type == datastructure.AuditLogTypeUserChannelEditorRemove {
+		return "Removed editors must be invited again", nil
+	}
+	if _, ok := revertibleAuditLogTypes[l.Type]; !ok || l.Target == nil || l.Target.ID == nil {
+		return "This type of entry can't be reverted", nil
+	}
+	if canRevert != nil && !canRevert(l) {
+		return "Insufficient privilege", nil
+	}
+
+	// Emote statuses change by deleting or restoring the emote, which also cleans up channels and caches
+	if l.Target.Type == string(mongo.CollectionNameEmotes) {
+		for _, c := range l.Changes {
+			if c.Key == "status" {
+				return "Status changes can't be reverted, delete or restore the emote instead", nil
+			}
+		}
+	}
+
+	// Checked again by the unique index when the revert is written, in case of concurrent reverts
+	count, err := mongo.Collection(mongo.CollectionNameAudit).CountDocuments(ctx, bson.M{"revert_of": l.ID})
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "Already reverted", nil
+	}
+
+	// Emotes put back into a channel must still exist
+	switch l.Type {
+	case datastructure.AuditLogTypeUserChannelEmoteRemove, datastructure.AuditLogTypeUserChannelEmoteBatch, datastructure.AuditLogTypeUserChannelRestore:
+		ids := []primitive.ObjectID{}
+		for _, c := range l.Changes {
+			old, new := revertChangeValues(l, c)
+			if c.Key == "emotes" && old != nil && new == nil {
+				if id, ok := old.(primitive.ObjectID); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+		if len(ids) > 0 {
+			count, err := mongo.Collection(mongo.CollectionNameEmotes).CountDocuments(ctx, bson.M{
+				"_id":    bson.M{"$in": ids},
+				"status": bson.M{"$ne": datastructure.EmoteStatusDeleted},
+			})
+			if err != nil {
+				return "", err
+			}
+			if count < int64(len(ids)) {
+				return "The emote no longer exists", nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Check that a channel getting emotes back has the slots for them. Unlike edits,
+// reverts by moderators are held to the limit too, as they restore a past state rather than override it
+func checkRevertSlots(ctx context.Context, l *datastructure.AuditLog, before bson.D, after bson.D) error {
+	if l.Target.Type != string(mongo.CollectionNameUsers) {
+		return nil
+	}
+
+	a, b := &datastructure.User{}, &datastructure.User{}
+	for _, d := range []struct {
+		doc bson.D
+		dst *datastructure.User
+	}{{before, a}, {after, b}} {
+		raw, err := bson.Marshal(d.doc)
+		if err == nil {
+			err = bson.Unmarshal(raw, d.dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(b.EmoteIDs) <= len(a.EmoteIDs) {
+		return nil
+	}
+
+	slots, err := Users.EmoteSlots(ctx, b)
+	if err != nil {
+		return err
+	}
+	if slots.OverLimit() {
+		return &revertSkip{fmt.Sprintf("The channel has no emote slots left (%d)", slots.Total)}
+	}
+	return nil
+}
+
+// Compute a document with the changes of an entry undone
+func revertDocument(doc bson.D, l *datastructure.AuditLog) (bson.D, error) {
+	result := doc
+	for _, c := range l.Changes {
+		path := strings.Split(c.Key, ".")
+		current, exists := getDocumentPath(result, path)
+		old, new := revertChangeValues(l, c)
+		if old == nil && new == nil { // Legacy entries which recorded nothing usable
+			continue
+		}
+
+		oldArr, oldIsArr := old.(bson.A)
+		newArr, newIsArr := new.(bson.A)
+		currentArr, currentIsArr := current.(bson.A)
+		switch {
+		case oldIsArr && newIsArr && currentIsArr:
+			// A whole array was recorded. Undo its element changes, keeping changes made since
+			elements := []*datastructure.AuditLogChange{}
+			diffArrays(&elements, c.Key, oldArr, newArr)
+			added, removed := bson.A{}, bson.A{}
+			for _, ch := range elements {
+				if ch.NewValue != nil {
+					added = append(added, ch.NewValue)
+				} else {
+					removed = append(removed, ch.OldValue)
+				}
+			}
+			for _, v := range added {
+				currentArr = pullArrayValue(currentArr, v)
+			}
+			for _, v := range removed {
+				currentArr = addArrayValue(currentArr, v)
+			}
+			result = setDocumentPath(result, path, currentArr)
+		case currentIsArr && (old == nil) != (new == nil):
+			// An element was added or removed
+			if new != nil {
+				result = setDocumentPath(result, path, pullArrayValue(currentArr, new))
+			} else {
+				result = setDocumentPath(result, path, addArrayValue(currentArr, old))
+			}
+		default:
+			// A value was set. It must not have changed since
+			if (new == nil && exists && current != nil) || (new != nil && (!exists || !equalAuditValues(current, new))) {
+				return nil, &revertSkip{fmt.Sprintf("The value of %v was changed since", c.Key)}
+			}
+			if old == nil {
+				result = unsetDocumentPath(result, path)
+			} else {
+				result = setDocumentPath(result, path, old)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// The update which writes the fields of a reverted document touched by an entry
+func revertUpdate(doc bson.D, l *datastructure.AuditLog) bson.M {
+	set, unset := bson.M{}, bson.M{}
+	for _, c := range l.Changes {
+		key := strings.SplitN(c.Key, ".", 2)[0]
+		if v, ok := getDocumentPath(doc, []string{key}); ok {
+			set[key] = v
+		} else {
+			unset[key] = ""
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+// The old and new value of a change, correcting entries which recorded removals as additions
+func revertChangeValues(l *datastructure.AuditLog, c *datastructure.AuditLogChange) (interface{}, interface{}) {
+	switch l.Type {
+	case datastructure.AuditLogTypeUserChannelEmoteRemove:
+		if _, isArr := c.NewValue.(bson.A); c.OldValue == nil && c.NewValue != nil && !isArr && !strings.Contains(c.Key, ".") {
+			return c.NewValue, nil
+		}
+	}
+	return c.OldValue, c.NewValue
+}
+
+// Announce the channel emote changes made by a revert
+func revertEvents(target *datastructure.Target, doc bson.D, changes []*datastructure.AuditLogChange, actor *datastructure.User) []AuditedEvent {
+	if target.Type != string(mongo.CollectionNameUsers) {
+		return nil
+	}
+
+	channel := &datastructure.User{}
+	b, err := bson.Marshal(doc)
+	if err == nil {
+		err = bson.Unmarshal(b, channel)
+	}
+	if err != nil {
+		log.WithError(err).Error("bson")
+		return nil
+	}
+
+	events := []AuditedEvent{}
+	for _, c := range changes {
+		var id interface{}
+		action := "UPDATE"
+		switch {
+		case c.Key == "emotes" && c.NewValue != nil:
+			id, action = c.NewValue, "ADD"
+		case c.Key == "emotes" && c.OldValue != nil:
+			id, action = c.OldValue, "REMOVE"
+		case strings.HasPrefix(c.Key, "emote_alias."):
+			if oid, err := primitive.ObjectIDFromHex(strings.TrimPrefix(c.Key, "emote_alias.")); err == nil {
+				id = oid
+			}
+		}
+		emoteID, ok := id.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+
+		emote := &datastructure.Emote{}
+		if err := mongo.Collection(mongo.CollectionNameEmotes).FindOne(context.Background(), bson.M{"_id": emoteID}).Decode(emote); err != nil {
+			log.WithError(err).Error("mongo")
+			continue
+		}
+		name := emote.Name
+		if v, ok := channel.EmoteAlias[emoteID.Hex()]; ok {
+			name = v
+		}
+		events = append(events, Emotes.ChannelEvents(channel, emote, name, action, actor)...)
+	}
+	return events
+}
+
+// Get the value at a dotted path of a document
+func getDocumentPath(doc bson.D, path []string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return e.Value, true
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			return getDocumentPath(sub, path[1:])
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// Copy a document with the value at a dotted path replaced, creating embedded documents as needed
+func setDocumentPath(doc bson.D, path []string, v interface{}) bson.D {
+	result := make(bson.D, 0, len(doc)+1)
+	found := false
+	for _, e := range doc {
+		if e.Key == path[0] {
+			found = true
+			if len(path) > 1 {
+				sub, _ := e.Value.(bson.D)
+				e = bson.E{Key: e.Key, Value: setDocumentPath(sub, path[1:], v)}
+			} else {
+				e = bson.E{Key: e.Key, Value: v}
+			}
+		}
+		result = append(result, e)
+	}
+	if !found {
+		if len(path) > 1 {
+			v = setDocumentPath(bson.D{}, path[1:], v)
+		}
+		result = append(result, bson.E{Key: path[0], Value: v})
+	}
+	return result
+}
+
+// Copy a document without the value at a dotted path
+func unsetDocumentPath(doc bson.D, path []string) bson.D {
+	result := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key == path[0] {
+			if len(path) == 1 {
+				continue
+			}
+			if sub, ok := e.Value.(bson.D); ok {
+				e = bson.E{Key: e.Key, Value: unsetDocumentPath(sub, path[1:])}
+			}
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// Copy an array without any element equal to v
+func pullArrayValue(arr bson.A, v interface{}) bson.A {
+	result := make(bson.A, 0, len(arr))
+	for _, x := range arr {
+		if !equalAuditValues(x, v) {
+			result = append(result, x)
+		}
+	}
+	return result
+}
+
+// Copy an array with v added, unless it's already there
+func addArrayValue(arr bson.A, v interface{}) bson.A {
+	for _, x := range arr {
+		if equalAuditValues(x, v) {
+			return arr
+		}
+	}
+	return append(append(make(bson.A, 0, len(arr)+1), arr...), v)
+}
+
+type RevertOptions struct {
+	Actor     *datastructure.User
+	Logs      []*datastructure.AuditLog // The entries to revert, most recent first
+	Reason    string
+	DryRun    bool
+	CanRevert func(l *datastructure.AuditLog) bool // Whether the actor may revert an entry
+}
+
+type RevertResult struct {
+	Log      *datastructure.AuditLog
+	Changes  []*datastructure.AuditLogChange // What the revert changes, or would change when previewing
+	Reverted bool
+	Skipped  string // Why the entry was not reverted
+}
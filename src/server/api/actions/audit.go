@@ -0,0 +1,234 @@
+package actions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How many times a write is retried when another writer extended the chain first
+const auditWriteAttempts = 10
+
+// Returned when an entry written in a transaction lost the race for its sequence number
+var ErrAuditChainConflict = fmt.Errorf("audit chain conflict")
+
+// Returned when the entry undone by a revert was already reverted
+var ErrAuditAlreadyReverted = fmt.Errorf("audit log entry already reverted")
+
+// Serializes writes from this instance, so that they don't compete with each other for the chain head
+var auditWriteMx sync.Mutex
+
+// Write: Append an entry to the audit log
+//
+// The entry is stamped with the time and the node it was written from, and linked to the previous entry by its hash
+func (audit) Write(ctx context.Context, entry *datastructure.AuditLog) error {
+	auditWriteMx.Lock()
+	defer auditWriteMx.Unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	entry.Timestamp = time.Now().UTC().Truncate(time.Millisecond)
+	entry.Node = configure.NodeName
+	entry.Pod = configure.PodName
+
+	for i := 0; i < auditWriteAttempts; i++ {
+		head := &datastructure.AuditLog{}
+		err := mongo.Collection(mongo.CollectionNameAudit).FindOne(ctx, bson.M{
+			"seq": bson.M{"$exists": true},
+		}, options.FindOne().SetSort(bson.M{"seq": -1}).SetProjection(bson.M{"seq": 1, "hash": 1})).Decode(head)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		entry.Seq = head.Seq + 1
+		entry.PrevHash = head.Hash
+		entry.Hash = ""
+
+		// Hash the entry exactly as it will be stored
+		b, err := bson.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		doc := bson.D{}
+		if err := bson.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+		if entry.Hash, err = AuditLogHash(entry.PrevHash, doc); err != nil {
+			return err
+		}
+
+		_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, append(doc, bson.E{Key: "hash", Value: entry.Hash}))
+		if mongo.IsDuplicateKeyError(err) && entry.RevertOf != nil && strings.Contains(err.Error(), "revert_of") {
+			return ErrAuditAlreadyReverted
+		}
+		if mongo.IsDuplicateKeyError(err) { // Another instance took this sequence number
+			// An aborted transaction can't be continued, so the whole transaction has to be retried
+			if mongo.InTransaction(ctx) {
+				return ErrAuditChainConflict
+			}
+			continue
+		}
+		return err
+	}
+
+	return fmt.Errorf("could not append to the audit chain after %d attempts", auditWriteAttempts)
+}
+
+// AuditLogHash: Compute the chain hash of an entry, given as stored but without its hash
+func AuditLogHash(prevHash string, doc bson.D) (string, error) {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Find: Get audit log entries matching a query, most recent first
+func (a audit) Find(ctx context.Context, q AuditQuery) ([]*datastructure.AuditLog, error) {
+	opts := options.Find().SetSort(bson.M{"_id": -1})
+	if q.Limit > 0 {
+		opts.SetLimit(q.Limit)
+	}
+	if q.Skip > 0 {
+		opts.SetSkip(q.Skip)
+	}
+
+	logs := []*datastructure.AuditLog{}
+	cur, err := mongo.Collection(mongo.CollectionNameAudit).Find(ctx, q.Filter(), opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+// Iterate: Stream every audit log entry matching a query, most recent first
+//
+// Iteration stops at the first error returned by fn
+func (audit) Iterate(ctx context.Context, q AuditQuery, fn func(l *datastructure.AuditLog) error) error {
+	opts := options.Find().SetSort(bson.M{"_id": -1}).SetBatchSize(500)
+	if q.Limit > 0 {
+		opts.SetLimit(q.Limit)
+	}
+
+	cur, err := mongo.Collection(mongo.CollectionNameAudit).Find(ctx, q.Filter(), opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		l := &datastructure.AuditLog{}
+		if err := cur.Decode(l); err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+// RecordView: Record in the audit log that an actor looked at or exported entries
+func (a audit) RecordView(ctx context.Context, actor *datastructure.User, q AuditQuery, via string) error {
+	query, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	return a.Write(ctx, &datastructure.AuditLog{
+		Type:      datastructure.AuditLogTypeAppLogsView,
+		CreatedBy: actor.ID,
+		Target:    &datastructure.Target{Type: "audit"},
+		Changes: []*datastructure.AuditLogChange{
+			{Key: "via", OldValue: nil, NewValue: via},
+			{Key: "query", OldValue: nil, NewValue: string(query)},
+		},
+	})
+}
+
+type AuditQuery struct {
+	ActorID    *primitive.ObjectID
+	TargetID   *primitive.ObjectID
+	TargetType string
+	Types      []int32
+	TypeMin    *int32
+	TypeMax    *int32
+	After      *time.Time          // Only entries created at or after this time
+	Before     *time.Time          // Only entries created before this time
+	Reason     string              // Case-insensitive text the reason must contain
+	Cursor     *primitive.ObjectID // Only entries older than this entry, for pagination
+	Limit      int64
+	Skip       int64
+}
+
+// Filter: The mongo query selecting the entries
+func (q AuditQuery) Filter() bson.M {
+	filter := bson.M{}
+	if q.ActorID != nil {
+		filter["action_user"] = *q.ActorID
+	}
+	if q.TargetID != nil {
+		filter["target.id"] = *q.TargetID
+	}
+	if q.TargetType != "" {
+		filter["target.type"] = q.TargetType
+	}
+
+	types := bson.M{}
+	if len(q.Types) > 0 {
+		types["$in"] = q.Types
+	}
+	if q.TypeMin != nil {
+		types["$gte"] = *q.TypeMin
+	}
+	if q.TypeMax != nil {
+		types["$lte"] = *q.TypeMax
+	}
+	if len(types) > 0 {
+		filter["type"] = types
+	}
+
+	// Object IDs embed their creation time, so the time window and the cursor both apply to the ID
+	id := bson.M{}
+	if q.After != nil {
+		id["$gte"] = primitive.NewObjectIDFromTimestamp(*q.After)
+	}
+	if q.Before != nil {
+		id["$lt"] = primitive.NewObjectIDFromTimestamp(*q.Before)
+	}
+	if q.Cursor != nil && (q.Before == nil || q.Cursor.Timestamp().Before(*q.Before)) {
+		id["$lt"] = *q.Cursor
+	}
+	if len(id) > 0 {
+		filter["_id"] = id
+	}
+
+	if q.Reason != "" {
+		filter["reason"] = primitive.Regex{Pattern: regexp.QuoteMeta(q.Reason), Options: "i"}
+	}
+
+	return filter
+}
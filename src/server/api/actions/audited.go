@@ -0,0 +1,236 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Mutate: Apply a change to a document and record it in the audit log
+//
+// The change and its audit entry are written in one transaction. The changes are computed from the document before and after,
+// and nothing is recorded when they are the same. Once committed, the Discord webhook and the redis events are sent
+func (a audit) Mutate(ctx context.Context, m AuditedMutation) ([]*datastructure.AuditLogChange, error) {
+	var changes []*datastructure.AuditLogChange
+	var err error
+	for i := 0; i < auditWriteAttempts; i++ {
+		err = mongo.WithTransaction(ctx, func(ctx context.Context) error {
+			changes = nil
+			if m.Apply != nil {
+				if err := m.Apply(ctx); err != nil {
+					return err
+				}
+			}
+
+			changes, err = Diff(m.Before, m.After, m.Ignore...)
+			if err != nil || len(changes) == 0 {
+				return err
+			}
+
+			return a.Write(ctx, &datastructure.AuditLog{
+				Type:      m.Type,
+				CreatedBy: m.Actor.ID,
+				Target:    m.Target,
+				Changes:   changes,
+				Reason:    m.Reason,
+				RevertOf:  m.RevertOf,
+			})
+		})
+		if !errors.Is(err, ErrAuditChainConflict) {
+			break
+		}
+	}
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	if m.Discord != nil {
+		go m.Discord(changes)
+	}
+	if m.Events != nil {
+		go func() {
+			for _, ev := range m.Events(changes) {
+				if err := redis.Publish(context.Background(), ev.Channel, ev.Payload); err != nil {
+					log.WithError(err).WithField("channel", ev.Channel).Error("redis")
+				}
+			}
+		}()
+	}
+
+	return changes, nil
+}
+
+// UpdateOne: Update a document from Apply, reading it as it was before and after the update within the transaction.
+// Either of before and after may be nil. Returns mongo.ErrNoDocuments if the filter matched nothing
+func (audit) UpdateOne(ctx context.Context, collection mongo.CollectionName, filter interface{}, update interface{}, before interface{}, after interface{}) error {
+	raw, err := mongo.Collection(collection).FindOneAndUpdate(ctx, filter, update).DecodeBytes()
+	if err != nil {
+		return err
+	}
+	if before != nil {
+		if err := bson.Unmarshal(raw, before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		return mongo.Collection(collection).FindOne(ctx, bson.M{"_id": raw.Lookup("_id")}).Decode(after)
+	}
+	return nil
+}
+
+// Diff: Compute the field-level changes between two versions of a document
+//
+// Either version may be nil, when the document was created or deleted. Embedded documents are compared by field, with dotted keys,
+// and arrays by element: each element removed or added is a change of its own
+func Diff(before interface{}, after interface{}, ignore ...string) ([]*datastructure.AuditLogChange, error) {
+	a, err := toAuditDocument(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toAuditDocument(after)
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := map[string]bool{"_id": true}
+	for _, k := range ignore {
+		ignored[k] = true
+	}
+
+	changes := []*datastructure.AuditLogChange{}
+	diffDocuments(&changes, "", a, b, ignored)
+	return changes, nil
+}
+
+func diffDocuments(changes *[]*datastructure.AuditLogChange, prefix string, a bson.D, b bson.D, ignored map[string]bool) {
+	old := make(map[string]interface{}, len(a))
+	for _, e := range a {
+		old[e.Key] = e.Value
+	}
+
+	// Walk the keys of the new version first, then the keys which were removed
+	seen := make(map[string]bool, len(b))
+	for _, e := range b {
+		seen[e.Key] = true
+		key := prefix + e.Key
+		if ignored[key] {
+			continue
+		}
+
+		oldValue, existed := old[e.Key]
+		if !existed && e.Value == nil {
+			continue
+		}
+		oldDoc, oldIsDoc := oldValue.(bson.D)
+		newDoc, newIsDoc := e.Value.(bson.D)
+		oldArr, oldIsArr := oldValue.(bson.A)
+		newArr, newIsArr := e.Value.(bson.A)
+		switch {
+		case oldIsDoc && newIsDoc:
+			diffDocuments(changes, key+".", oldDoc, newDoc, ignored)
+		case oldIsArr && newIsArr:
+			diffArrays(changes, key, oldArr, newArr)
+		case !existed || !equalAuditValues(oldValue, e.Value):
+			*changes = append(*changes, &datastructure.AuditLogChange{Key: key, OldValue: oldValue, NewValue: e.Value})
+		}
+	}
+	for _, e := range a {
+		if seen[e.Key] || ignored[prefix+e.Key] || e.Value == nil {
+			continue
+		}
+		*changes = append(*changes, &datastructure.AuditLogChange{Key: prefix + e.Key, OldValue: e.Value, NewValue: nil})
+	}
+}
+
+// Record the elements removed from and added to an array, one change per element
+func diffArrays(changes *[]*datastructure.AuditLogChange, key string, a bson.A, b bson.A) {
+	count := map[string]int{}
+	for _, v := range a {
+		count[auditValueKey(v)]++
+	}
+	added := bson.A{}
+	for _, v := range b {
+		k := auditValueKey(v)
+		if count[k] > 0 {
+			count[k]--
+			continue
+		}
+		added = append(added, v)
+	}
+
+	for _, v := range a {
+		k := auditValueKey(v)
+		if count[k] > 0 {
+			count[k]--
+			*changes = append(*changes, &datastructure.AuditLogChange{Key: key, OldValue: v, NewValue: nil})
+		}
+	}
+	for _, v := range added {
+		*changes = append(*changes, &datastructure.AuditLogChange{Key: key, OldValue: nil, NewValue: v})
+	}
+}
+
+// Identify a value by its stored form
+func auditValueKey(v interface{}) string {
+	t, b, _ := bson.MarshalValue(v)
+	return string(rune(t)) + string(b)
+}
+
+// Whether two values are stored identically
+func equalAuditValues(a interface{}, b interface{}) bool {
+	ta, ra, errA := bson.MarshalValue(a)
+	tb, rb, errB := bson.MarshalValue(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return ta == tb && bytes.Equal(ra, rb)
+}
+
+// Convert a document to its stored form, so that values compare the way they are saved
+func toAuditDocument(v interface{}) (bson.D, error) {
+	doc := bson.D{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return doc, nil
+	}
+
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := bson.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+type AuditedMutation struct {
+	Actor  *datastructure.User
+	Type   int32
+	Target *datastructure.Target
+	Reason *string
+
+	RevertOf *primitive.ObjectID // The entry this change undoes, if any
+
+	// The document before and after the change. Use nil when the document is created or deleted.
+	// They're compared after Apply has run, and should be read by Apply, so that they're read in the transaction
+	Before interface{}
+	After  interface{}
+	Ignore []string // Keys left out of the diff
+
+	Apply   func(ctx context.Context) error                              // Write the change, using the transaction's context
+	Discord func(changes []*datastructure.AuditLogChange)                // Send the activity webhook
+	Events  func(changes []*datastructure.AuditLogChange) []AuditedEvent // Build the redis events to publish. Runs in the background
+}
+
+type AuditedEvent struct {
+	Channel string
+	Payload interface{}
+}
@@ -0,0 +1,131 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SevenTV/ServerGo/src/aws"
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+var ErrInvalidBadgeImage = fmt.Errorf("invalid badge image")
+
+// The heights of a badge's images, by their scale
+var badgeSizes = []float64{18, 36, 72}
+
+const (
+	MAX_BADGE_FILE_SIZE   = 1000000
+	MAX_BADGE_PIXEL_SIZE  = 1000
+	MAX_BADGE_FRAME_COUNT = 256
+)
+
+// UploadImage: Resize a badge's image to each scale and upload them to the CDN
+func (badges) UploadImage(badgeID primitive.ObjectID, data []byte) error {
+	if len(data) == 0 || len(data) > MAX_BADGE_FILE_SIZE {
+		return ErrInvalidBadgeImage
+	}
+
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+	if err := mw.SetResourceLimit(imagick.RESOURCE_MEMORY, 500); err != nil {
+		log.WithError(err).Error("SetResourceLimit")
+	}
+	if err := mw.ReadImageBlob(data); err != nil {
+		return ErrInvalidBadgeImage
+	}
+	if mw.GetNumberImages() > MAX_BADGE_FRAME_COUNT {
+		return ErrInvalidBadgeImage
+	}
+
+	// Merge all frames with coalesce
+	aw := mw.CoalesceImages()
+	defer aw.Destroy()
+	width, height := float64(aw.GetImageWidth()), float64(aw.GetImageHeight())
+	if width == 0 || height == 0 || width > MAX_BADGE_PIXEL_SIZE || height > MAX_BADGE_PIXEL_SIZE {
+		return ErrInvalidBadgeImage
+	}
+
+	mime := "image/webp"
+	for i, size := range badgeSizes {
+		w, h := utils.GetSizeRatio([]float64{width, height}, []float64{size * width / height, size})
+
+		out := imagick.NewMagickWand()
+		for ind := 0; ind < int(aw.GetNumberImages()); ind++ {
+			aw.SetIteratorIndex(ind)
+			img := aw.GetImage()
+			if err := img.ResizeImage(uint(w), uint(h), imagick.FILTER_LANCZOS); err != nil {
+				log.WithError(err).Errorf("ResizeImage i=%v", ind)
+			} else if err := out.AddImage(img); err != nil {
+				log.WithError(err).Errorf("AddImage i=%v", ind)
+			}
+			img.Destroy()
+		}
+		if err := out.SetImageFormat("webp"); err != nil {
+			log.WithError(err).Error("SetImageFormat")
+		}
+		blob := out.GetImagesBlob()
+		out.Destroy()
+
+		if err := aws.UploadFile(configure.Config.GetString("aws_cdn_bucket"), fmt.Sprintf("badge/%s/%dx", badgeID.Hex(), i+1), blob, &mime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Holders: Get the users displaying each badge
+func (badges) Holders(ctx context.Context) (map[primitive.ObjectID][]primitive.ObjectID, error) {
+	return cosmeticHolders(ctx, datastructure.EntitlementKindBadge)
+}
+
+// Displayed: Get the badge a user displays, if any
+func (badges) Displayed(ctx context.Context, userID primitive.ObjectID) (*primitive.ObjectID, error) {
+	return displayedCosmetic(ctx, datastructure.EntitlementKindBadge, userID)
+}
+
+// Select: Choose which of a user's entitled badges they display
+func (badges) Select(ctx context.Context, user *datastructure.User, badgeID primitive.ObjectID) error {
+	if err := checkEntitledCosmetic(ctx, datastructure.EntitlementKindBadge, user.ID, badgeID); err != nil {
+		return err
+	}
+
+	before := bson.M{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  user,
+		Type:   datastructure.AuditLogTypeUserBadgeSelect,
+		Target: &datastructure.Target{ID: &user.ID, Type: "users"},
+		Before: before,
+		After:  bson.M{"badge": badgeID},
+		Apply: func(ctx context.Context) error {
+			current, err := Badges.Displayed(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+			before["badge"] = current
+
+			return selectEntitledCosmetic(ctx, datastructure.EntitlementKindBadge, user.ID, badgeID)
+		},
+	}); err != nil {
+		return err
+	}
+
+	Badges.Invalidate(ctx, badgeID)
+	return nil
+}
+
+// Invalidate: Drop cached data about a badge, after it or its holders changed
+func (badges) Invalidate(ctx context.Context, badgeID primitive.ObjectID) {
+	if _, err := redis.InvalidateCache(ctx, "", string(mongo.CollectionNameBadges), badgeID.Hex(), "", ""); err != nil {
+		log.WithError(err).Error("redis")
+	}
+	BadgeMap.MarkDirty(ctx)
+}
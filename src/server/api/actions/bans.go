@@ -0,0 +1,228 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Create: Issue a ban against a user and start enforcing it
+func (b bans) Create(ctx context.Context, opts CreateBanOptions) (*datastructure.Ban, error) {
+	ban := &datastructure.Ban{
+		ID:         primitive.NewObjectID(),
+		UserID:     &opts.VictimID,
+		Reason:     opts.Reason,
+		IssuedByID: &opts.Actor.ID,
+		ExpireAt:   opts.ExpireAt,
+		Active:     true,
+		Scope:      opts.Scope,
+	}
+
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  opts.Actor,
+		Type:   datastructure.AuditLogTypeUserBan,
+		Target: &datastructure.Target{ID: &opts.VictimID, Type: "users"},
+		Reason: &opts.Reason,
+		Before: bson.D{},
+		After:  bson.D{{Key: "bans", Value: ban.ID}, {Key: "scope", Value: ban.Scope}},
+		Apply: func(ctx context.Context) error {
+			_, err := mongo.Collection(mongo.CollectionNameBans).InsertOne(ctx, ban)
+			return err
+		},
+	}); err != nil {
+		return nil, err
+	}
+	if err := b.Sync(ctx, opts.VictimID); err != nil {
+		return nil, err
+	}
+
+	return ban, nil
+}
+
+// Lift: Deactivate a user's bans, stop enforcing them and let the user know
+//
+// Returns the bans which were lifted
+func (b bans) Lift(ctx context.Context, opts LiftBanOptions) ([]*datastructure.Ban, error) {
+	filter := bson.M{
+		"user_id": opts.UserID,
+		"active":  true,
+	}
+	if opts.BanIDs != nil {
+		filter["_id"] = bson.M{"$in": opts.BanIDs}
+	}
+
+	// The bans are read in the transaction, so that a ban lifted concurrently isn't recorded twice
+	lifted := []*datastructure.Ban{}
+	before := bson.M{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  opts.Actor,
+		Type:   datastructure.AuditLogTypeUserUnban,
+		Target: &datastructure.Target{ID: &opts.UserID, Type: "users"},
+		Reason: &opts.Reason,
+		Before: before,
+		After:  bson.M{"bans": bson.A{}},
+		Apply: func(ctx context.Context) error {
+			lifted = []*datastructure.Ban{}
+			delete(before, "bans")
+			cur, err := mongo.Collection(mongo.CollectionNameBans).Find(ctx, filter)
+			if err == nil {
+				err = cur.All(ctx, &lifted)
+			}
+			if err != nil || len(lifted) == 0 {
+				return err
+			}
+
+			ids := make([]primitive.ObjectID, len(lifted))
+			for i, ban := range lifted {
+				ids[i] = ban.ID
+				ban.Active = false
+			}
+			before["bans"] = ids
+
+			_, err = mongo.Collection(mongo.CollectionNameBans).UpdateMany(ctx, bson.M{
+				"_id": bson.M{"$in": ids},
+			}, bson.M{
+				"$set": bson.M{"active": false},
+			})
+			return err
+		},
+	}); err != nil {
+		return nil, err
+	}
+	if len(lifted) == 0 {
+		return lifted, nil
+	}
+	if err := b.Sync(ctx, opts.UserID); err != nil {
+		return nil, err
+	}
+
+	if opts.Silent {
+		return lifted, nil
+	}
+	go func() {
+		if err := Notifications.Create().
+			SetTitle("Your Ban Was Lifted").
+			AddTargetUsers(opts.UserID).
+			AddTextMessagePart(fmt.Sprintf("Your ban has been lifted. Reason: \"%v\"", opts.Reason)).
+			Write(context.Background()); err != nil {
+			log.WithError(err).Error("failed to create notification")
+		}
+	}()
+
+	return lifted, nil
+}
+
+// GetActive: Get the bans currently in effect against a user, most recent first
+func (bans) GetActive(ctx context.Context, userID primitive.ObjectID) ([]*datastructure.Ban, error) {
+	filter := ActiveBanFilter()
+	filter["user_id"] = userID
+
+	result := []*datastructure.Ban{}
+	cur, err := mongo.Collection(mongo.CollectionNameBans).Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Sync: Make the redis ban hashes reflect the bans currently in effect against a user
+//
+// Full bans are kept in "user:bans" along with their reason,
+// the combined scope of any scoped bans is kept in "user:bans:scoped"
+func (b bans) Sync(ctx context.Context, userID primitive.ObjectID) error {
+	active, err := b.GetActive(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	reason, full, scope := ReduceBans(active)
+	if full {
+		err = redis.Client.HSet(ctx, "user:bans", userID.Hex(), reason).Err()
+	} else {
+		err = redis.Client.HDel(ctx, "user:bans", userID.Hex()).Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	if scope != 0 {
+		return redis.Client.HSet(ctx, "user:bans:scoped", userID.Hex(), scope).Err()
+	}
+	return redis.Client.HDel(ctx, "user:bans:scoped", userID.Hex()).Err()
+}
+
+// IsRestricted: Check whether a user is banned from performing an action,
+// either by a full ban or by a ban of the given scope
+func (bans) IsRestricted(ctx context.Context, userID primitive.ObjectID, scope int64) (bool, error) {
+	exists, err := redis.Client.HExists(ctx, "user:bans", userID.Hex()).Result()
+	if err != nil || exists {
+		return exists, err
+	}
+
+	scoped, err := redis.Client.HGet(ctx, "user:bans:scoped", userID.Hex()).Int64()
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return utils.BitField.HasBits(scoped, scope), nil
+}
+
+// ReduceBans: Combine a user's active bans, sorted most recent first,
+// into the reason of the most recent full ban and the union of all ban scopes
+func ReduceBans(active []*datastructure.Ban) (reason string, full bool, scope int64) {
+	for _, ban := range active {
+		if ban.IsFull() {
+			if !full {
+				reason = ban.Reason
+			}
+			full = true
+			continue
+		}
+		scope |= ban.Scope
+	}
+	return reason, full, scope
+}
+
+// ActiveBanFilter: A query matching bans which are currently in effect
+func ActiveBanFilter() bson.M {
+	return bson.M{
+		"active": true,
+		"$or": bson.A{
+			bson.M{"expire_at": nil},
+			bson.M{"expire_at": time.Time{}},
+			bson.M{"expire_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+type CreateBanOptions struct {
+	Actor    *datastructure.User
+	VictimID primitive.ObjectID
+	Reason   string
+	ExpireAt time.Time // Zero for a ban which never expires
+	Scope    int64     // Zero for a full ban
+}
+
+type LiftBanOptions struct {
+	Actor  *datastructure.User
+	UserID primitive.ObjectID
+	BanIDs []primitive.ObjectID // The bans to lift, or all active bans if nil
+	Reason string
+	Silent bool // Don't notify the user, when lifting a ban that was only just issued by a failed action
+}
@@ -0,0 +1,370 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"github.com/SevenTV/ServerGo/src/validation"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrBatchInvalid       = fmt.Errorf("a batch holds at least one operation")
+	ErrBatchUnknownEmote  = fmt.Errorf("unknown emote")
+	ErrBatchUnknownAction = fmt.Errorf("unknown action")
+	ErrBatchAccessDenied  = fmt.Errorf("access denied")
+	ErrBatchInvalidAlias  = fmt.Errorf("invalid alias")
+	ErrBatchFiltered      = fmt.Errorf("alias rejected by filter")
+	ErrBatchNameConflict  = fmt.Errorf("another emote of the channel has this name")
+	ErrBatchSlotLimit     = fmt.Errorf("channel emote slot limit reached")
+	ErrChannelChanged     = fmt.Errorf("the channel's emotes changed meanwhile")
+)
+
+// The most operations a batch submitted by a user may hold
+const MAX_CHANNEL_EMOTE_BATCH = 250
+
+// A change to a channel's emotes, applied as part of a batch
+type ChannelEmoteOperation struct {
+	Action  ChannelEmoteAction
+	EmoteID primitive.ObjectID
+	// The name of the emote in the channel, for ADD and RENAME. Empty resets it to the emote's own name
+	Alias *string
+}
+
+type ChannelEmoteAction string
+
+var (
+	ChannelEmoteActionAdd    = ChannelEmoteAction("ADD")
+	ChannelEmoteActionRemove = ChannelEmoteAction("REMOVE")
+	ChannelEmoteActionRename = ChannelEmoteAction("RENAME")
+)
+
+// An operation of a batch which can't be applied, which fails the whole batch
+type ChannelEmoteBatchError struct {
+	Index int // The position of the operation, or -1 if the error concerns the batch as a whole
+	Err   error
+	Name  string // The conflicting name, for name conflicts
+	Slots int32  // The channel's slots, when the batch exceeds them
+}
+
+func (e *ChannelEmoteBatchError) Error() string {
+	if e.Index < 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+type ChannelEmoteBatch struct {
+	Actor      *datastructure.User
+	Channel    *datastructure.User
+	Operations []ChannelEmoteOperation
+	Reason     *string
+	// The audit log type recorded, if other than a batch edit
+	AuditType int32
+	// If set, a restore point of the channel as it was before the batch is taken with this reason, once the batch is applied
+	RestorePoint *string
+	// If set, the channel's editors are replaced in the same change, such as when a snapshot is restored
+	Editors *ChannelEditors
+}
+
+// The editors of a channel and their permissions, replacing the current ones
+type ChannelEditors struct {
+	IDs         []primitive.ObjectID
+	Permissions map[string]int64 // By editor ID
+}
+
+// ApplyChannelBatch: Validate a list of changes to a channel's emotes, then apply them all at once
+//
+// Operations run in order against the outcome of the previous ones. The batch fails as a whole if any of them is invalid,
+// if the channel ends up with more emotes than its slots while adding any, or if an added or renamed emote
+// shares its name with another. A single audit entry is written and a single batched event published.
+// Returns the channel as updated
+func (x *emotes) ApplyChannelBatch(ctx context.Context, b ChannelEmoteBatch) (*datastructure.User, error) {
+	channel := b.Channel
+	if len(b.Operations) == 0 && b.Editors == nil {
+		return nil, &ChannelEmoteBatchError{Index: -1, Err: ErrBatchInvalid}
+	}
+
+	// Fetch the emotes of the channel and those of the batch at once
+	ids := append([]primitive.ObjectID{}, channel.EmoteIDs...)
+	for _, op := range b.Operations {
+		ids = append(ids, op.EmoteID)
+	}
+	found := []*datastructure.Emote{}
+	cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err == nil {
+		err = cur.All(ctx, &found)
+	}
+	if err != nil {
+		return nil, err
+	}
+	emoteMap := make(map[primitive.ObjectID]*datastructure.Emote, len(found))
+	for _, e := range found {
+		emoteMap[e.ID] = e
+	}
+
+	// Run the operations against a copy of the channel's emotes
+	emoteIDs := append([]primitive.ObjectID{}, channel.EmoteIDs...)
+	aliases := make(map[string]string, len(channel.EmoteAlias))
+	for k, v := range channel.EmoteAlias {
+		aliases[k] = v
+	}
+	indexOf := func(id primitive.ObjectID) int {
+		for i, v := range emoteIDs {
+			if v == id {
+				return i
+			}
+		}
+		return -1
+	}
+	nameOf := func(e *datastructure.Emote) string {
+		if v, ok := aliases[e.ID.Hex()]; ok {
+			return v
+		}
+		return e.Name
+	}
+
+	added := 0
+	named := map[primitive.ObjectID]int{} // The emotes added or renamed, to the operation which named them last
+	changes := []ChannelEmoteChange{}
+	flags := []*FilterMatch{}
+	for i, op := range b.Operations {
+		fail := func(err error) (*datastructure.User, error) {
+			return nil, &ChannelEmoteBatchError{Index: i, Err: err}
+		}
+
+		emote, ok := emoteMap[op.EmoteID]
+		if !ok {
+			return fail(ErrBatchUnknownEmote)
+		}
+
+		switch op.Action {
+		case ChannelEmoteActionAdd:
+			if !channel.CanEditChannel(b.Actor, datastructure.UserEditorPermissionAddEmotes) {
+				return fail(ErrBatchAccessDenied)
+			}
+			if emote.Status != datastructure.EmoteStatusLive {
+				return fail(ErrBatchUnknownEmote)
+			}
+			// Private emotes can only be added by their owner or those they're shared with
+			if utils.BitField.HasBits(int64(emote.Visibility), int64(datastructure.EmoteVisibilityPrivate)) && emote.OwnerID != channel.ID {
+				shared := false
+				for _, v := range emote.SharedWith {
+					shared = shared || v == channel.ID
+				}
+				if !shared {
+					return fail(ErrBatchUnknownEmote)
+				}
+			}
+			if utils.BitField.HasBits(int64(emote.Visibility), int64(datastructure.EmoteVisibilityZeroWidth)) && !b.Actor.HasPermission(datastructure.RolePermissionUseZeroWidthEmote) {
+				return fail(ErrBatchAccessDenied)
+			}
+
+			if indexOf(emote.ID) == -1 {
+				emoteIDs = append(emoteIDs, emote.ID)
+				added++
+				named[emote.ID] = i
+				changes = append(changes, ChannelEmoteChange{Emote: emote, Action: "ADD"})
+			}
+		case ChannelEmoteActionRemove:
+			if !channel.CanEditChannel(b.Actor, datastructure.UserEditorPermissionRemoveEmotes) {
+				return fail(ErrBatchAccessDenied)
+			}
+
+			if j := indexOf(emote.ID); j != -1 {
+				changes = append(changes, ChannelEmoteChange{Emote: emote, Name: nameOf(emote), Action: "REMOVE"})
+				emoteIDs = append(emoteIDs[:j], emoteIDs[j+1:]...)
+				delete(aliases, emote.ID.Hex())
+				delete(named, emote.ID)
+			}
+		case ChannelEmoteActionRename:
+			if op.Alias == nil {
+				return fail(ErrBatchInvalidAlias)
+			}
+			if indexOf(emote.ID) == -1 {
+				return fail(ErrBatchUnknownEmote)
+			}
+		default:
+			return fail(ErrBatchUnknownAction)
+		}
+
+		// Set the emote's name in the channel
+		if op.Action != ChannelEmoteActionRemove && op.Alias != nil {
+			if !channel.CanEditChannel(b.Actor, datastructure.UserEditorPermissionAliasEmotes) {
+				return fail(ErrBatchAccessDenied)
+			}
+
+			alias := *op.Alias
+			if alias == "" {
+				delete(aliases, emote.ID.Hex())
+			} else {
+				if !validation.ValidateEmoteName(utils.S2B(alias)) {
+					return fail(ErrBatchInvalidAlias)
+				}
+				if !b.Actor.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+					match, err := Filter.Check(ctx, alias)
+					if err != nil {
+						return nil, err
+					}
+					if match != nil {
+						if match.Rule.Action == datastructure.FilterActionReject {
+							return fail(ErrBatchFiltered)
+						}
+						flags = append(flags, match)
+					}
+				}
+				aliases[emote.ID.Hex()] = alias
+			}
+
+			named[emote.ID] = i
+			if op.Action == ChannelEmoteActionRename {
+				changes = append(changes, ChannelEmoteChange{Emote: emote, Action: "UPDATE"})
+			}
+		}
+	}
+
+	// A channel over its limit keeps its emotes, but can't add more
+	if added > 0 && !b.Actor.HasPermission(datastructure.RolePermissionManageUsers) {
+		after := *channel
+		after.EmoteIDs = emoteIDs
+		slots, err := Users.EmoteSlots(ctx, &after)
+		if err != nil {
+			return nil, err
+		}
+		if slots.OverLimit() {
+			return nil, &ChannelEmoteBatchError{Index: -1, Err: ErrBatchSlotLimit, Slots: slots.Total}
+		}
+	}
+
+	// Added and renamed emotes must not share their name with another emote of the channel
+	for id, i := range named {
+		name := nameOf(emoteMap[id])
+		for _, other := range emoteIDs {
+			if e, ok := emoteMap[other]; ok && other != id && nameOf(e) == name {
+				return nil, &ChannelEmoteBatchError{Index: i, Err: ErrBatchNameConflict, Name: name}
+			}
+		}
+	}
+
+	// Name the changes after the batch ran
+	for i, c := range changes {
+		if c.Action != "REMOVE" {
+			changes[i].Name = nameOf(c.Emote)
+		}
+	}
+
+	auditType := b.AuditType
+	if auditType == 0 {
+		auditType = datastructure.AuditLogTypeUserChannelEmoteBatch
+	}
+	before := &datastructure.User{}
+	updated := &datastructure.User{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  b.Actor,
+		Type:   auditType,
+		Target: &datastructure.Target{ID: &channel.ID, Type: string(mongo.CollectionNameUsers)},
+		Reason: b.Reason,
+		Before: before,
+		After:  updated,
+		Apply: func(ctx context.Context) error {
+			set := bson.M{
+				"emotes":      emoteIDs,
+				"emote_alias": aliases,
+			}
+			if b.Editors != nil {
+				set["editors"] = b.Editors.IDs
+				set["editor_permissions"] = b.Editors.Permissions
+			}
+
+			err := Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{"_id": channel.ID}, bson.M{
+				"$set": set,
+			}, before, updated)
+			if err == mongo.ErrNoDocuments {
+				return ErrChannelChanged
+			}
+			if err != nil {
+				return err
+			}
+
+			// The channel's emotes, and editors when they are replaced, must not have changed since they were read.
+			// They are compared here rather than in the filter, as mongo matches embedded documents by field order
+			// and a missing array as null; a concurrent write to the channel aborts the transaction regardless
+			if !sameObjectIDs(before.EmoteIDs, channel.EmoteIDs) || !sameAliases(before.EmoteAlias, channel.EmoteAlias) {
+				return ErrChannelChanged
+			}
+			if b.Editors != nil && (!sameObjectIDs(before.EditorIDs, channel.EditorIDs) || !samePermissions(before.EditorPermissions, channel.EditorPermissions)) {
+				return ErrChannelChanged
+			}
+			return nil
+		},
+		Events: func(_ []*datastructure.AuditLogChange) []AuditedEvent {
+			if len(changes) == 0 {
+				return nil
+			}
+			return x.ChannelBatchEvents(updated, changes, b.Actor)
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	// The restore point keeps the channel as it was before the batch, once the batch is applied
+	if b.RestorePoint != nil && (len(changes) > 0 || b.Editors != nil) {
+		if _, err := Users.CreateRestorePoint(ctx, channel, b.Actor, *b.RestorePoint); err != nil {
+			log.WithError(err).Error("mongo")
+		}
+	}
+
+	// Aliases can't be held back, so moderators are alerted instead
+	if len(flags) > 0 {
+		go func() {
+			for _, match := range flags {
+				if err := Filter.Flag(context.Background(), &datastructure.Target{ID: &channel.ID, Type: "users"}, match); err != nil {
+					log.WithError(err).Error("mongo")
+				}
+			}
+		}()
+	}
+
+	return updated, nil
+}
+
+func sameObjectIDs(a []primitive.ObjectID, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameAliases(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+func samePermissions(a map[string]int64, b map[string]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package actions
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/SevenTV/ServerGo/src/cache"
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/emoteresolution"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	api_proxy "github.com/SevenTV/ServerGo/src/server/api/v2/proxy"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EmotePrecedence: The configured precedence of emote sources
+func EmotePrecedence() []emoteresolution.Source {
+	if v := configure.Config.GetStringSlice("emote_resolution.precedence"); len(v) > 0 {
+		if precedence, err := emoteresolution.ParsePrecedence(v); err == nil {
+			return precedence
+		}
+		log.Warn("emote_resolution.precedence is invalid, the default is used")
+	}
+
+	return emoteresolution.DefaultPrecedence
+}
+
+type ResolvedChannelEmotes struct {
+	Precedence  []emoteresolution.Source
+	Emotes      []*emoteresolution.ResolvedEmote // Sorted by name
+	Unavailable []emoteresolution.Source         // The sources which couldn't be fetched, whose emotes are left out
+}
+
+// ResolveChannel: Get the emotes in effect in a channel across 7TV, BTTV, FFZ and Twitch
+//
+// The emotes are fetched from each source of the precedence, then resolved by emoteresolution.Resolve
+func (*emotes) ResolveChannel(ctx context.Context, channel *datastructure.User, precedence []emoteresolution.Source) (*ResolvedChannelEmotes, error) {
+	result := &ResolvedChannelEmotes{
+		Precedence:  precedence,
+		Unavailable: []emoteresolution.Source{},
+	}
+
+	// Fetch the emotes of each source at once
+	fetched := make([][]*datastructure.Emote, len(precedence))
+	errs := make([]error, len(precedence))
+	wg := sync.WaitGroup{}
+	wg.Add(len(precedence))
+	for i, source := range precedence {
+		go func(i int, source emoteresolution.Source) {
+			defer wg.Done()
+			fetched[i], errs[i] = fetchEmoteSource(ctx, channel, source)
+		}(i, source)
+	}
+	wg.Wait()
+
+	for i, source := range precedence {
+		if errs[i] != nil {
+			// 7TV's own emotes must resolve, the other providers are left out when they can't be reached
+			if source.Provider() == "7TV" {
+				return nil, errs[i]
+			}
+			log.WithError(errs[i]).WithField("source", source).Warn("emote resolution, couldn't fetch emotes")
+			result.Unavailable = append(result.Unavailable, source)
+			fetched[i] = nil
+		}
+	}
+	result.Emotes = emoteresolution.Resolve(precedence, fetched)
+
+	return result, nil
+}
+
+func fetchEmoteSource(ctx context.Context, channel *datastructure.User, source emoteresolution.Source) ([]*datastructure.Emote, error) {
+	var emotes []*datastructure.Emote
+	var err error
+	switch source {
+	case emoteresolution.Source7TVChannel:
+		filter := bson.M{"_id": bson.M{"$in": append([]primitive.ObjectID{}, channel.EmoteIDs...)}}
+		if !channel.HasPermission(datastructure.RolePermissionUseZeroWidthEmote) {
+			// Omit zerowidth emote if the user lacks permission to use those
+			filter["visibility"] = bson.M{"$bitsAllClear": datastructure.EmoteVisibilityZeroWidth}
+		}
+		if err = cache.Find(ctx, "emotes", "", filter, &emotes); err == nil {
+			// Name the emotes as in the channel
+			c := *channel
+			c.Emotes = &emotes
+			emotes = datastructure.UserUtil.GetAliasedEmotes(&c)
+		}
+	case emoteresolution.Source7TVGlobal:
+		err = cache.Find(ctx, "emotes", "", bson.M{
+			"visibility": bson.M{"$bitsAllSet": datastructure.EmoteVisibilityGlobal},
+		}, &emotes)
+	case emoteresolution.SourceBTTVChannel:
+		emotes, err = api_proxy.GetChannelEmotesBTTV(ctx, channel.Login)
+	case emoteresolution.SourceBTTVGlobal:
+		emotes, err = api_proxy.GetGlobalEmotesBTTV(ctx)
+	case emoteresolution.SourceFFZChannel:
+		emotes, err = api_proxy.GetChannelEmotesFFZ(ctx, channel.Login)
+	case emoteresolution.SourceFFZGlobal:
+		emotes, err = api_proxy.GetGlobalEmotesFFZ(ctx)
+	case emoteresolution.SourceTwitchChannel:
+		if channel.TwitchID != "" {
+			emotes, err = api_proxy.GetChannelEmotesTwitch(ctx, channel.TwitchID)
+		}
+	case emoteresolution.SourceTwitchGlobal:
+		emotes, err = api_proxy.GetGlobalEmotesTwitch(ctx)
+	default:
+		err = emoteresolution.ErrUnknownSource
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range emotes {
+		if e == nil {
+			continue
+		}
+		if source.Provider() == "7TV" {
+			e.Provider = "7TV"
+		}
+		if !strings.HasSuffix(string(source), ":CHANNEL") {
+			e.Visibility |= datastructure.EmoteVisibilityGlobal
+		}
+	}
+	return emotes, nil
+}
@@ -0,0 +1,472 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"github.com/SevenTV/ServerGo/src/validation"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSnapshotVersion      = fmt.Errorf("unsupported snapshot version")
+	ErrSnapshotAccessDenied = fmt.Errorf("only the channel owner can restore its editors")
+)
+
+// The most restore points kept per channel. Older ones are deleted as new ones are taken, and all expire after 30 days
+const MAX_RESTORE_POINTS = 20
+
+// Snapshot: Take a snapshot of a channel's emotes, aliases and editors
+func (x users) Snapshot(ctx context.Context, channel *datastructure.User) (*datastructure.ChannelSnapshot, error) {
+	snapshots, err := x.snapshots(ctx, []*datastructure.User{channel})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots[0], nil
+}
+
+// CreateRestorePoint: Keep a snapshot of a channel, from which it can be restored
+func (x users) CreateRestorePoint(ctx context.Context, channel *datastructure.User, actor *datastructure.User, reason string) (*datastructure.ChannelSnapshot, error) {
+	snapshots, err := x.CreateRestorePoints(ctx, []*datastructure.User{channel}, actor, reason)
+	if err != nil {
+		return nil, err
+	}
+	return snapshots[0], nil
+}
+
+// CreateRestorePoints: Keep a snapshot of many channels at once, such as before an emote merge changes them all
+func (x users) CreateRestorePoints(ctx context.Context, channels []*datastructure.User, actor *datastructure.User, reason string) ([]*datastructure.ChannelSnapshot, error) {
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	snapshots, err := x.snapshots(ctx, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]interface{}, len(snapshots))
+	for i, s := range snapshots {
+		s.ID = primitive.NewObjectID()
+		s.Reason = reason
+		s.CreatedBy = &actor.ID
+		docs[i] = s
+	}
+	if _, err = mongo.Collection(mongo.CollectionNameRestorePoints).InsertMany(ctx, docs); err != nil {
+		return nil, err
+	}
+
+	for _, ch := range channels {
+		pruneRestorePoints(ctx, ch.ID)
+	}
+	return snapshots, nil
+}
+
+// Delete the oldest restore points of a channel past the limit
+func pruneRestorePoints(ctx context.Context, channelID primitive.ObjectID) {
+	cur, err := mongo.Collection(mongo.CollectionNameRestorePoints).Find(ctx, bson.M{"channel_id": channelID}, options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetSkip(MAX_RESTORE_POINTS).
+		SetProjection(bson.M{"_id": 1}),
+	)
+	old := []*datastructure.ChannelSnapshot{}
+	if err == nil {
+		err = cur.All(ctx, &old)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return
+	}
+	if len(old) == 0 {
+		return
+	}
+
+	ids := make([]primitive.ObjectID, len(old))
+	for i, s := range old {
+		ids[i] = s.ID
+	}
+	if _, err := mongo.Collection(mongo.CollectionNameRestorePoints).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		log.WithError(err).Error("mongo")
+	}
+}
+
+// RestorePoints: Get the restore points of a channel, the most recent first
+func (users) RestorePoints(ctx context.Context, channelID primitive.ObjectID) ([]*datastructure.ChannelSnapshot, error) {
+	snapshots := []*datastructure.ChannelSnapshot{}
+	cur, err := mongo.Collection(mongo.CollectionNameRestorePoints).Find(ctx, bson.M{
+		"channel_id": channelID,
+	}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(MAX_RESTORE_POINTS))
+	if err == nil {
+		err = cur.All(ctx, &snapshots)
+	}
+	return snapshots, err
+}
+
+// RestorePoint: Get a restore point of a channel, or nil if it has no such restore point
+func (users) RestorePoint(ctx context.Context, channelID primitive.ObjectID, id primitive.ObjectID) (*datastructure.ChannelSnapshot, error) {
+	snapshot := &datastructure.ChannelSnapshot{}
+	if err := mongo.Collection(mongo.CollectionNameRestorePoints).FindOne(ctx, bson.M{
+		"_id":        id,
+		"channel_id": channelID,
+	}).Decode(snapshot); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Take snapshots of channels, fetching the names of all their emotes at once
+func (users) snapshots(ctx context.Context, channels []*datastructure.User) ([]*datastructure.ChannelSnapshot, error) {
+	ids := []primitive.ObjectID{}
+	for _, ch := range channels {
+		ids = append(ids, ch.EmoteIDs...)
+	}
+	found := []*datastructure.Emote{}
+	cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{
+		"_id": bson.M{"$in": ids},
+	}, options.Find().SetProjection(bson.M{"name": 1}))
+	if err == nil {
+		err = cur.All(ctx, &found)
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[primitive.ObjectID]string, len(found))
+	for _, e := range found {
+		names[e.ID] = e.Name
+	}
+
+	now := time.Now()
+	result := make([]*datastructure.ChannelSnapshot, len(channels))
+	for i, ch := range channels {
+		snapshot := &datastructure.ChannelSnapshot{
+			Version:   datastructure.ChannelSnapshotVersion,
+			ChannelID: ch.ID,
+			CreatedAt: now,
+			Emotes:    make([]datastructure.ChannelSnapshotEmote, len(ch.EmoteIDs)),
+			Editors:   make([]datastructure.ChannelSnapshotEditor, len(ch.EditorIDs)),
+		}
+		for j, id := range ch.EmoteIDs {
+			snapshot.Emotes[j] = datastructure.ChannelSnapshotEmote{
+				ID:    id,
+				Name:  names[id],
+				Alias: ch.EmoteAlias[id.Hex()],
+			}
+		}
+		for j, id := range ch.EditorIDs {
+			p, _ := ch.GetEditorPermissions(id)
+			snapshot.Editors[j] = datastructure.ChannelSnapshotEditor{ID: id, Permissions: p}
+		}
+		result[i] = snapshot
+	}
+	return result, nil
+}
+
+// Find the emotes which emotes were merged into, following later merges of those
+func mergedEmotes(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]primitive.ObjectID, error) {
+	into := map[primitive.ObjectID]primitive.ObjectID{}
+	for round := 0; round < 5 && len(ids) > 0; round++ {
+		entries := []*datastructure.AuditLog{}
+		cur, err := mongo.Collection(mongo.CollectionNameAudit).Find(ctx, bson.M{
+			"type":        datastructure.AuditLogTypeEmoteMerge,
+			"target.type": "emotes",
+			"target.id":   bson.M{"$in": ids},
+		})
+		if err == nil {
+			err = cur.All(ctx, &entries)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ids = ids[:0]
+		for _, l := range entries {
+			for _, c := range l.Changes {
+				if id, ok := c.NewValue.(primitive.ObjectID); ok && c.Key == "merged_into" {
+					if _, seen := into[id]; !seen {
+						ids = append(ids, id)
+					}
+					into[*l.Target.ID] = id
+				}
+			}
+		}
+	}
+
+	// Resolve each emote to the last emote of its chain of merges
+	result := make(map[primitive.ObjectID]primitive.ObjectID, len(into))
+	for id := range into {
+		last := id
+		for i := 0; i <= 5; i++ {
+			next, ok := into[last]
+			if !ok {
+				break
+			}
+			last = next
+		}
+		result[id] = last
+	}
+	return result, nil
+}
+
+// A part of a snapshot which can't be imported, and is skipped
+type SnapshotConflict struct {
+	Kind SnapshotConflictKind
+	ID   primitive.ObjectID // The emote or editor
+	Name string             // The emote's name in the snapshot
+}
+
+type SnapshotConflictKind string
+
+var (
+	SnapshotConflictMissing     = SnapshotConflictKind("MISSING")                    // The emote does not exist
+	SnapshotConflictDeleted     = SnapshotConflictKind("DELETED")                    // The emote was deleted, or merged into an emote which no longer exists
+	SnapshotConflictUnavailable = SnapshotConflictKind("UNAVAILABLE")                // The emote is not live, private, or zero-width without permission
+	SnapshotConflictInvalidName = SnapshotConflictKind("INVALID_NAME")               // The alias is invalid, so the emote keeps its own name
+	SnapshotConflictDuplicate   = SnapshotConflictKind("NAME_CONFLICT")              // An emote earlier in the snapshot has the same name
+	SnapshotConflictSlots       = SnapshotConflictKind("SLOT_OVERFLOW")              // The channel has no slot left for the emote
+	SnapshotConflictInvitation  = SnapshotConflictKind("EDITOR_INVITATION_REQUIRED") // The user is no longer an editor, and must be invited again
+)
+
+type SnapshotImportOptions struct {
+	Actor    *datastructure.User
+	Channel  *datastructure.User
+	Snapshot *datastructure.ChannelSnapshot
+	// Whether the editors are restored as well. Editors removed since are not added back, as they must accept an invitation
+	IncludeEditors bool
+	// Whether the changes are only computed, without applying them
+	Preview bool
+	Reason  *string
+}
+
+// The changes made to a channel by importing a snapshot, or which would be made on preview
+type SnapshotImport struct {
+	Channel        *datastructure.User // The channel after the import, or as it is on preview
+	Added          []primitive.ObjectID
+	Removed        []primitive.ObjectID
+	Renamed        []primitive.ObjectID
+	EditorsUpdated []primitive.ObjectID
+	EditorsRemoved []primitive.ObjectID
+	Conflicts      []*SnapshotConflict
+}
+
+// ImportSnapshot: Restore a channel to a snapshot, skipping what can no longer be restored
+//
+// Emotes which no longer exist or can't be added are skipped, as are those sharing their name with an emote earlier in the snapshot,
+// and those past the channel's slots. Emotes merged into another since are restored as that emote.
+// The emotes and editors are then applied as one batch, and a restore point is taken of the channel as it was
+func (x users) ImportSnapshot(ctx context.Context, opts SnapshotImportOptions) (*SnapshotImport, error) {
+	channel, snapshot := opts.Channel, opts.Snapshot
+	if snapshot.Version < 1 || snapshot.Version > datastructure.ChannelSnapshotVersion {
+		return nil, ErrSnapshotVersion
+	}
+	if opts.IncludeEditors && opts.Actor.ID != channel.ID && !opts.Actor.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, ErrSnapshotAccessDenied
+	}
+
+	result := &SnapshotImport{Channel: channel, Conflicts: []*SnapshotConflict{}}
+	conflict := func(kind SnapshotConflictKind, id primitive.ObjectID, name string) {
+		result.Conflicts = append(result.Conflicts, &SnapshotConflict{Kind: kind, ID: id, Name: name})
+	}
+
+	ids := make([]primitive.ObjectID, len(snapshot.Emotes))
+	for i, e := range snapshot.Emotes {
+		ids[i] = e.ID
+	}
+	found := []*datastructure.Emote{}
+	cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err == nil {
+		err = cur.All(ctx, &found)
+	}
+	if err != nil {
+		return nil, err
+	}
+	emoteMap := make(map[primitive.ObjectID]*datastructure.Emote, len(found))
+	deleted := []primitive.ObjectID{}
+	for _, e := range found {
+		emoteMap[e.ID] = e
+		if e.Status == datastructure.EmoteStatusDeleted {
+			deleted = append(deleted, e.ID)
+		}
+	}
+
+	// Emotes merged away since are restored as the emotes they were merged into
+	merged, err := mergedEmotes(ctx, deleted)
+	if err != nil {
+		return nil, err
+	}
+	if len(merged) > 0 {
+		targets := make([]primitive.ObjectID, 0, len(merged))
+		for _, id := range merged {
+			targets = append(targets, id)
+		}
+		found = []*datastructure.Emote{}
+		cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{"_id": bson.M{"$in": targets}})
+		if err == nil {
+			err = cur.All(ctx, &found)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range found {
+			emoteMap[e.ID] = e
+		}
+	}
+
+	total := -1
+	if !opts.Actor.HasPermission(datastructure.RolePermissionManageUsers) {
+		slots, err := x.EmoteSlots(ctx, channel)
+		if err != nil {
+			return nil, err
+		}
+		total = int(slots.Total)
+	}
+
+	// Resolve the emotes kept, in the snapshot's order
+	kept := []datastructure.ChannelSnapshotEmote{}
+	keptIDs := map[primitive.ObjectID]bool{}
+	names := map[string]bool{}
+	zeroWidthOK := opts.Actor.HasPermission(datastructure.RolePermissionUseZeroWidthEmote)
+	for _, se := range snapshot.Emotes {
+		if into, ok := merged[se.ID]; ok {
+			if e, ok := emoteMap[into]; ok {
+				// Named as the merge named it in the channel
+				if se.Alias == "" && se.Name != e.Name {
+					se.Alias = se.Name
+				}
+				se.ID = into
+			}
+		}
+		if keptIDs[se.ID] {
+			continue
+		}
+		name := se.Name
+		if se.Alias != "" {
+			name = se.Alias
+		}
+
+		emote, ok := emoteMap[se.ID]
+		switch {
+		case !ok:
+			conflict(SnapshotConflictMissing, se.ID, name)
+			continue
+		case emote.Status == datastructure.EmoteStatusDeleted:
+			conflict(SnapshotConflictDeleted, se.ID, name)
+			continue
+		case utils.ContainsObjectID(channel.EmoteIDs, emote.ID):
+			// Emotes in the channel already are kept as they are
+		case emote.Status != datastructure.EmoteStatusLive,
+			utils.BitField.HasBits(int64(emote.Visibility), int64(datastructure.EmoteVisibilityPrivate)) && emote.OwnerID != channel.ID && !utils.ContainsObjectID(emote.SharedWith, channel.ID),
+			utils.BitField.HasBits(int64(emote.Visibility), int64(datastructure.EmoteVisibilityZeroWidth)) && !zeroWidthOK:
+			conflict(SnapshotConflictUnavailable, se.ID, name)
+			continue
+		}
+
+		if se.Alias != "" && !validation.ValidateEmoteName(utils.S2B(se.Alias)) {
+			conflict(SnapshotConflictInvalidName, se.ID, se.Alias)
+			se.Alias = ""
+		}
+		name = emote.Name
+		if se.Alias != "" {
+			name = se.Alias
+		}
+		if names[name] {
+			conflict(SnapshotConflictDuplicate, se.ID, name)
+			continue
+		}
+		if total >= 0 && len(kept) >= total {
+			conflict(SnapshotConflictSlots, se.ID, name)
+			continue
+		}
+
+		names[name] = true
+		keptIDs[se.ID] = true
+		kept = append(kept, se)
+	}
+
+	// Removals come first, freeing the names of the emotes added or renamed
+	operations := []ChannelEmoteOperation{}
+	for _, id := range channel.EmoteIDs {
+		if !keptIDs[id] {
+			operations = append(operations, ChannelEmoteOperation{Action: ChannelEmoteActionRemove, EmoteID: id})
+			result.Removed = append(result.Removed, id)
+		}
+	}
+	for _, se := range kept {
+		alias := se.Alias
+		current, aliased := channel.EmoteAlias[se.ID.Hex()]
+		switch {
+		case !utils.ContainsObjectID(channel.EmoteIDs, se.ID):
+			op := ChannelEmoteOperation{Action: ChannelEmoteActionAdd, EmoteID: se.ID}
+			if alias != "" || aliased {
+				op.Alias = &alias
+			}
+			operations = append(operations, op)
+			result.Added = append(result.Added, se.ID)
+		case current != alias:
+			operations = append(operations, ChannelEmoteOperation{Action: ChannelEmoteActionRename, EmoteID: se.ID, Alias: &alias})
+			result.Renamed = append(result.Renamed, se.ID)
+		}
+	}
+
+	// Editors keep the permissions they had, while those added since are removed
+	editorPermissions := map[string]int64{}
+	editorIDs := []primitive.ObjectID{}
+	if opts.IncludeEditors {
+		wanted := map[primitive.ObjectID]int64{}
+		for _, se := range snapshot.Editors {
+			if _, ok := channel.GetEditorPermissions(se.ID); !ok {
+				conflict(SnapshotConflictInvitation, se.ID, "")
+				continue
+			}
+			wanted[se.ID] = utils.BitField.RemoveBits(se.Permissions, ^datastructure.UserEditorPermissionAll)
+		}
+		for _, id := range channel.EditorIDs {
+			p, ok := wanted[id]
+			if !ok {
+				result.EditorsRemoved = append(result.EditorsRemoved, id)
+				continue
+			}
+
+			editorIDs = append(editorIDs, id)
+			editorPermissions[id.Hex()] = p
+			if held, _ := channel.GetEditorPermissions(id); held != p {
+				result.EditorsUpdated = append(result.EditorsUpdated, id)
+			}
+		}
+	}
+	editorsChanged := len(result.EditorsUpdated) > 0 || len(result.EditorsRemoved) > 0
+
+	if opts.Preview || (len(operations) == 0 && !editorsChanged) {
+		return result, nil
+	}
+
+	// The emotes and editors are applied together, as one audited change
+	restorePoint := "Before a snapshot was restored"
+	batch := ChannelEmoteBatch{
+		Actor:        opts.Actor,
+		Channel:      channel,
+		Operations:   operations,
+		Reason:       opts.Reason,
+		AuditType:    datastructure.AuditLogTypeUserChannelRestore,
+		RestorePoint: &restorePoint,
+	}
+	if editorsChanged {
+		batch.Editors = &ChannelEditors{IDs: editorIDs, Permissions: editorPermissions}
+	}
+	channel, err = Emotes.ApplyChannelBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Channel = channel
+	return result, nil
+}
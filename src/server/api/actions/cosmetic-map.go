@@ -0,0 +1,488 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/bsm/redislock"
+	goredis "github.com/go-redis/redis/v8"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCosmeticMapDiffUnavailable = fmt.Errorf("cosmetic map diff unavailable")
+
+const (
+	cosmeticMapDiffTTL    = time.Hour * 24   // How long a diff can be downloaded for
+	cosmeticMapRefreshTTL = time.Minute * 10 // How long a map is used before being rebuilt even though nothing changed, which catches renamed users
+	maxCosmeticMapDiffs   = 1000             // The most versions a diff may span
+)
+
+// The identifier types which users in a cosmetic map can be looked up by
+var CosmeticMapIdentifierTypes = []string{"object_id", "twitch_id", "login"}
+
+// A versioned map of the users displaying each cosmetic of a kind, built from entitlements and stored in redis
+//
+// Keys, under "<collection>:map":
+// the map as JSON, its version, a dirty flag set when a cosmetic or its holders changed,
+// a fresh flag which expires when the map is due a rebuild,
+// a hash of "<identifier type>:<identifier>" to the ID of the item the user displays,
+// a hash of item ID to the item as JSON, and the diff which produced each version
+type CosmeticMap struct {
+	kind       datastructure.EntitlementKind
+	collection mongo.CollectionName
+	newItem    func() interface{} // A pointer to the type of the collection's documents
+}
+
+var (
+	BadgeMap = &CosmeticMap{datastructure.EntitlementKindBadge, mongo.CollectionNameBadges, func() interface{} { return &datastructure.Badge{} }}
+	PaintMap = &CosmeticMap{datastructure.EntitlementKindPaint, mongo.CollectionNamePaints, func() interface{} { return &datastructure.Paint{} }}
+
+	CosmeticMaps = []*CosmeticMap{BadgeMap, PaintMap}
+)
+
+type CosmeticMapData struct {
+	Version int64               `json:"version"`
+	Items   []*CosmeticMapEntry `json:"items"`
+}
+
+type CosmeticMapEntry struct {
+	ID      primitive.ObjectID `json:"id"`
+	Item    json.RawMessage    `json:"item"`
+	Holders []*CosmeticHolder  `json:"holders"`
+}
+
+type CosmeticHolder struct {
+	ID       primitive.ObjectID `json:"id"`
+	TwitchID string             `json:"twitch_id"`
+	Login    string             `json:"login"`
+}
+
+// Identifier: Get the identifier of the holder, by type
+func (h *CosmeticHolder) Identifier(idType string) string {
+	switch idType {
+	case "twitch_id":
+		return h.TwitchID
+	case "login":
+		return h.Login
+	}
+	return h.ID.Hex()
+}
+
+// The changes between two versions of a cosmetic map. Clients apply the removed holders before the added ones
+type CosmeticMapDiff struct {
+	Version int64                        `json:"version"`
+	Since   int64                        `json:"since"`
+	Items   []json.RawMessage            `json:"items"`   // Items which were created or edited
+	Added   map[string][]*CosmeticHolder `json:"added"`   // Item ID to the holders it gained
+	Removed map[string][]*CosmeticHolder `json:"removed"` // Item ID to the holders it lost
+}
+
+// The map of an entitlement kind, or nil if its items aren't mapped
+func cosmeticMapByKind(kind datastructure.EntitlementKind) *CosmeticMap {
+	for _, m := range CosmeticMaps {
+		if m.kind == kind {
+			return m
+		}
+	}
+	return nil
+}
+
+func (m *CosmeticMap) key(suffix string) string {
+	return fmt.Sprintf("%s:map%s", m.collection, suffix)
+}
+
+// Name: The name of the mapped collection
+func (m *CosmeticMap) Name() string {
+	return string(m.collection)
+}
+
+// LockKey: The redis lock held while the map is rebuilt
+func (m *CosmeticMap) LockKey() string {
+	return "lock:task:rebuild-cosmetic-map:" + m.Name()
+}
+
+// MarkDirty: Have the map rebuilt, after an item or its holders changed
+func (m *CosmeticMap) MarkDirty(ctx context.Context) {
+	if err := redis.Client.Set(ctx, m.key(":dirty"), "1", 0).Err(); err != nil {
+		log.WithError(err).Error("redis")
+	}
+}
+
+// NeedsRebuild: Check whether the map is out of date, clearing the dirty flag
+func (m *CosmeticMap) NeedsRebuild(ctx context.Context) (bool, error) {
+	n, err := redis.Client.Del(ctx, m.key(":dirty")).Result()
+	if err != nil || n > 0 {
+		return true, err
+	}
+
+	n, err = redis.Client.Exists(ctx, m.key(":fresh")).Result()
+	return n == 0, err
+}
+
+// Version: Get the version of the latest map, or 0 if it was never built
+func (m *CosmeticMap) Version(ctx context.Context) (int64, error) {
+	v, err := redis.Client.Get(ctx, m.key(":version")).Int64()
+	if err == goredis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// Get: Get the latest map, or nil if it was never built
+func (m *CosmeticMap) Get(ctx context.Context) (*CosmeticMapData, error) {
+	b, err := redis.Client.Get(ctx, m.key("")).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	data := &CosmeticMapData{}
+	if err := json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Diff: Get the changes made to the map since a version
+//
+// Returns ErrCosmeticMapDiffUnavailable if the version is unknown, or too old for its diffs to still be kept
+func (m *CosmeticMap) Diff(ctx context.Context, since int64) (*CosmeticMapDiff, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since < 1 || since > version || version-since > maxCosmeticMapDiffs {
+		return nil, ErrCosmeticMapDiffUnavailable
+	}
+
+	keys := make([]string, 0, version-since)
+	for v := since + 1; v <= version; v++ {
+		keys = append(keys, m.key(fmt.Sprintf(":diff:%d", v)))
+	}
+	result := &CosmeticMapDiff{
+		Version: version,
+		Since:   since,
+		Items:   []json.RawMessage{},
+		Added:   map[string][]*CosmeticHolder{},
+		Removed: map[string][]*CosmeticHolder{},
+	}
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	values, err := redis.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	// Replay the diffs in order, keeping each holder's state before the first and after the last change
+	type holderKey struct {
+		itemID string
+		userID primitive.ObjectID
+	}
+	type holderState struct {
+		before *CosmeticHolder
+		after  *CosmeticHolder
+	}
+	states := map[holderKey]*holderState{}
+	change := func(itemID string, h *CosmeticHolder, removed bool) {
+		k := holderKey{itemID, h.ID}
+		st, ok := states[k]
+		if !ok {
+			st = &holderState{}
+			if removed {
+				st.before = h
+			}
+			states[k] = st
+		}
+		st.after = nil
+		if !removed {
+			st.after = h
+		}
+	}
+	items := map[string]json.RawMessage{}
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, ErrCosmeticMapDiffUnavailable
+		}
+		diff := &CosmeticMapDiff{}
+		if err := json.Unmarshal([]byte(s), diff); err != nil {
+			return nil, err
+		}
+
+		for _, item := range diff.Items {
+			var ref struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(item, &ref); err != nil {
+				return nil, err
+			}
+			items[ref.ID] = item
+		}
+		for itemID, holders := range diff.Removed {
+			for _, h := range holders {
+				change(itemID, h, true)
+			}
+		}
+		for itemID, holders := range diff.Added {
+			for _, h := range holders {
+				change(itemID, h, false)
+			}
+		}
+	}
+
+	for _, item := range items {
+		result.Items = append(result.Items, item)
+	}
+	for k, st := range states {
+		if st.before != nil && st.after != nil && *st.before == *st.after {
+			continue
+		}
+		if st.before != nil {
+			result.Removed[k.itemID] = append(result.Removed[k.itemID], st.before)
+		}
+		if st.after != nil {
+			result.Added[k.itemID] = append(result.Added[k.itemID], st.after)
+		}
+	}
+	return result, nil
+}
+
+// UserItem: Get the item displayed by a user, looked up by an identifier type, or nil if they display none
+func (m *CosmeticMap) UserItem(ctx context.Context, idType string, identifier string) (json.RawMessage, error) {
+	itemID, err := redis.Client.HGet(ctx, m.key(":users"), idType+":"+identifier).Result()
+	if err == goredis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	b, err := redis.Client.HGet(ctx, m.key(":items"), itemID).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	return b, err
+}
+
+// EnsureBuilt: Get the version of the latest map, building it first if it never was.
+// Waits for the lock, in case another pod is building it
+func (m *CosmeticMap) EnsureBuilt(ctx context.Context) (int64, error) {
+	if version, err := m.Version(ctx); err != nil || version != 0 {
+		return version, err
+	}
+
+	lock, err := redis.GetLocker().Obtain(ctx, m.LockKey(), time.Minute, &redislock.Options{
+		RetryStrategy: redislock.LimitRetry(redislock.LinearBackoff(250*time.Millisecond), 120),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = lock.Release(context.Background())
+	}()
+
+	// Another pod may have built it meanwhile
+	if version, err := m.Version(ctx); err != nil || version != 0 {
+		return version, err
+	}
+	return m.Rebuild(ctx)
+}
+
+// Rebuild: Build the map from the items' holders, storing it as a new version if anything changed
+//
+// Callers should hold a lock, as concurrent rebuilds may produce the same version twice
+func (m *CosmeticMap) Rebuild(ctx context.Context) (int64, error) {
+	data := &CosmeticMapData{Items: []*CosmeticMapEntry{}}
+	cur, err := mongo.Collection(m.collection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	for cur.Next(ctx) {
+		var ref struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		item := m.newItem()
+		if err := cur.Decode(&ref); err != nil {
+			return 0, err
+		}
+		if err := cur.Decode(item); err != nil {
+			return 0, err
+		}
+		b, err := json.Marshal(item)
+		if err != nil {
+			return 0, err
+		}
+		data.Items = append(data.Items, &CosmeticMapEntry{ID: ref.ID, Item: b, Holders: []*CosmeticHolder{}})
+	}
+	if err := cur.Err(); err != nil {
+		return 0, err
+	}
+
+	holders, err := cosmeticHolders(ctx, m.kind)
+	if err != nil {
+		return 0, err
+	}
+	userIDs := []primitive.ObjectID{}
+	for _, ids := range holders {
+		userIDs = append(userIDs, ids...)
+	}
+	users := []*datastructure.User{}
+	if len(userIDs) > 0 {
+		cur, err = mongo.Collection(mongo.CollectionNameUsers).Find(ctx, bson.M{
+			"_id": bson.M{"$in": userIDs},
+		}, options.Find().SetProjection(bson.M{"_id": 1, "id": 1, "login": 1}))
+		if err == nil {
+			err = cur.All(ctx, &users)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	usersByID := make(map[primitive.ObjectID]*datastructure.User, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
+	}
+
+	for _, entry := range data.Items {
+		for _, id := range holders[entry.ID] {
+			if u, ok := usersByID[id]; ok {
+				entry.Holders = append(entry.Holders, &CosmeticHolder{ID: u.ID, TwitchID: u.TwitchID, Login: u.Login})
+			}
+		}
+		sort.Slice(entry.Holders, func(i, j int) bool { return entry.Holders[i].ID.Hex() < entry.Holders[j].ID.Hex() })
+	}
+
+	old, err := m.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	diff := diffCosmeticMaps(old, data)
+	if old != nil && len(diff.Items) == 0 && len(diff.Added) == 0 && len(diff.Removed) == 0 {
+		// Nothing changed, the map is only marked as up to date
+		return old.Version, redis.Client.Set(ctx, m.key(":fresh"), "1", cosmeticMapRefreshTTL).Err()
+	}
+	if old != nil {
+		data.Version = old.Version
+	}
+	data.Version++
+	diff.Version = data.Version
+	diff.Since = data.Version - 1
+
+	mapJSON, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return 0, err
+	}
+	userItems := map[string]interface{}{}
+	itemsByID := map[string]interface{}{}
+	for _, entry := range data.Items {
+		itemsByID[entry.ID.Hex()] = []byte(entry.Item)
+		for _, h := range entry.Holders {
+			for _, idType := range CosmeticMapIdentifierTypes {
+				userItems[idType+":"+h.Identifier(idType)] = entry.ID.Hex()
+			}
+		}
+	}
+
+	// Write the map and its indexes at once, so that readers never see a version half written
+	_, err = redis.Client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Set(ctx, m.key(""), mapJSON, 0)
+		pipe.Set(ctx, m.key(fmt.Sprintf(":diff:%d", data.Version)), diffJSON, cosmeticMapDiffTTL)
+		pipe.Del(ctx, m.key(":users"), m.key(":items"))
+		if len(userItems) > 0 {
+			pipe.HSet(ctx, m.key(":users"), userItems)
+		}
+		if len(itemsByID) > 0 {
+			pipe.HSet(ctx, m.key(":items"), itemsByID)
+		}
+		pipe.Set(ctx, m.key(":version"), data.Version, 0)
+		pipe.Set(ctx, m.key(":fresh"), "1", cosmeticMapRefreshTTL)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return data.Version, nil
+}
+
+// Get the changes from one map to the next
+func diffCosmeticMaps(old *CosmeticMapData, data *CosmeticMapData) *CosmeticMapDiff {
+	diff := &CosmeticMapDiff{
+		Items:   []json.RawMessage{},
+		Added:   map[string][]*CosmeticHolder{},
+		Removed: map[string][]*CosmeticHolder{},
+	}
+
+	oldEntries := map[primitive.ObjectID]*CosmeticMapEntry{}
+	if old != nil {
+		for _, entry := range old.Items {
+			oldEntries[entry.ID] = entry
+		}
+	}
+
+	for _, entry := range data.Items {
+		oldEntry, ok := oldEntries[entry.ID]
+		if !ok {
+			oldEntry = &CosmeticMapEntry{}
+		}
+		delete(oldEntries, entry.ID)
+
+		if !bytes.Equal(oldEntry.Item, entry.Item) {
+			diff.Items = append(diff.Items, entry.Item)
+		}
+		added, removed := diffCosmeticHolders(oldEntry.Holders, entry.Holders)
+		if len(added) > 0 {
+			diff.Added[entry.ID.Hex()] = added
+		}
+		if len(removed) > 0 {
+			diff.Removed[entry.ID.Hex()] = removed
+		}
+	}
+
+	// Items aren't deleted, but their holders are dropped should it ever happen
+	for id, entry := range oldEntries {
+		if len(entry.Holders) > 0 {
+			diff.Removed[id.Hex()] = entry.Holders
+		}
+	}
+	return diff
+}
+
+// Get the holders which were added and removed. A renamed holder is both removed and added again
+func diffCosmeticHolders(old []*CosmeticHolder, holders []*CosmeticHolder) ([]*CosmeticHolder, []*CosmeticHolder) {
+	oldByID := make(map[primitive.ObjectID]*CosmeticHolder, len(old))
+	for _, h := range old {
+		oldByID[h.ID] = h
+	}
+
+	added := []*CosmeticHolder{}
+	for _, h := range holders {
+		if o, ok := oldByID[h.ID]; ok && *o == *h {
+			delete(oldByID, h.ID)
+			continue
+		}
+		added = append(added, h)
+	}
+
+	removed := []*CosmeticHolder{}
+	for _, h := range oldByID {
+		removed = append(removed, h)
+	}
+	return added, removed
+}
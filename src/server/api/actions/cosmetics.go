@@ -0,0 +1,110 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrNotEntitled = fmt.Errorf("user is not entitled to this item")
+
+// The data shared by the entitlements of cosmetics, such as badges and paints
+type entitledCosmetic struct {
+	ObjectReference primitive.ObjectID `bson:"ref"`
+	Selected        bool               `bson:"selected"`
+}
+
+// Get the users displaying each cosmetic of a kind
+//
+// The holders are the users with an active entitlement. A user holding several items
+// displays the one they selected, or their most recently granted one if they never picked
+func cosmeticHolders(ctx context.Context, kind datastructure.EntitlementKind) (map[primitive.ObjectID][]primitive.ObjectID, error) {
+	displayed, err := displayedCosmetics(ctx, kind, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[primitive.ObjectID][]primitive.ObjectID{}
+	for userID, itemID := range displayed {
+		result[itemID] = append(result[itemID], userID)
+	}
+	return result, nil
+}
+
+// Get the cosmetic of a kind displayed by a user, if any
+func displayedCosmetic(ctx context.Context, kind datastructure.EntitlementKind, userID primitive.ObjectID) (*primitive.ObjectID, error) {
+	displayed, err := displayedCosmetics(ctx, kind, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	if itemID, ok := displayed[userID]; ok {
+		return &itemID, nil
+	}
+	return nil, nil
+}
+
+// Get the cosmetic of a kind displayed by each user holding an active entitlement matching the filter
+func displayedCosmetics(ctx context.Context, kind datastructure.EntitlementKind, filter bson.M) (map[primitive.ObjectID]primitive.ObjectID, error) {
+	for k, v := range ActiveEntitlementFilter() {
+		filter[k] = v
+	}
+	filter["kind"] = kind
+
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}))
+	if err == nil {
+		err = cur.All(ctx, &ents)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := map[primitive.ObjectID]entitledCosmetic{}
+	for _, e := range ents {
+		var data entitledCosmetic
+		if err := bson.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		if d, ok := byUser[e.UserID]; ok && (d.Selected || !data.Selected) {
+			continue
+		}
+		byUser[e.UserID] = data
+	}
+
+	result := make(map[primitive.ObjectID]primitive.ObjectID, len(byUser))
+	for userID, d := range byUser {
+		result[userID] = d.ObjectReference
+	}
+	return result, nil
+}
+
+// Check that a user holds an active entitlement to a cosmetic
+func checkEntitledCosmetic(ctx context.Context, kind datastructure.EntitlementKind, userID primitive.ObjectID, itemID primitive.ObjectID) error {
+	filter := ActiveEntitlementFilter()
+	filter["kind"] = kind
+	filter["user_id"] = userID
+	filter["data.ref"] = itemID
+	if n, err := mongo.Collection(mongo.CollectionNameEntitlements).CountDocuments(ctx, filter); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotEntitled
+	}
+	return nil
+}
+
+// Mark the user's entitlements to a cosmetic as selected, and those to the other items of its kind as not
+func selectEntitledCosmetic(ctx context.Context, kind datastructure.EntitlementKind, userID primitive.ObjectID, itemID primitive.ObjectID) error {
+	_, err := mongo.Collection(mongo.CollectionNameEntitlements).UpdateMany(ctx, bson.M{
+		"kind":    kind,
+		"user_id": userID,
+	}, bson.A{
+		bson.M{"$set": bson.M{"data.selected": bson.M{"$eq": bson.A{"$data.ref", itemID}}}},
+	})
+	return err
+}
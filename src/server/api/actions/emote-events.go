@@ -0,0 +1,137 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChannelEvents: The redis events announcing a change to a channel's emotes
+//
+// Action is one of ADD, UPDATE or REMOVE
+func (*emotes) ChannelEvents(channel *datastructure.User, emote *datastructure.Emote, name string, action string, actor *datastructure.User) []AuditedEvent {
+	events := []AuditedEvent{{
+		Channel: fmt.Sprintf("users:%v:emotes", channel.Login),
+		Payload: redis.PubSubPayloadUserEmotes{
+			Removed: action == "REMOVE",
+			ID:      emote.ID.Hex(),
+			Actor:   actor.DisplayName,
+		},
+	}}
+
+	return append(events, AuditedEvent{
+		Channel: fmt.Sprintf("events-v1:channel-emotes:%s", channel.Login),
+		Payload: channelEmotesPayload(channel, emote, name, action, actor, emoteOwners([]*datastructure.Emote{emote})),
+	})
+}
+
+// ChannelBatchEvents: The redis events announcing several changes to a channel's emotes at once
+//
+// Each change is announced as ChannelEvents would, for the subscribers of single changes,
+// and the changes are announced together on the batch channel
+func (*emotes) ChannelBatchEvents(channel *datastructure.User, changes []ChannelEmoteChange, actor *datastructure.User) []AuditedEvent {
+	payload := redis.EventApiV1ChannelEmotesBatch{
+		Channel: channel.Login,
+		Actor:   actor.DisplayName,
+		Changes: make([]redis.EventApiV1ChannelEmotes, len(changes)),
+	}
+	emotes := make([]*datastructure.Emote, len(changes))
+	for i, c := range changes {
+		emotes[i] = c.Emote
+	}
+	owners := emoteOwners(emotes)
+
+	events := make([]AuditedEvent, 0, len(changes)*2+1)
+	for i, c := range changes {
+		payload.Changes[i] = channelEmotesPayload(channel, c.Emote, c.Name, c.Action, actor, owners)
+		events = append(events, AuditedEvent{
+			Channel: fmt.Sprintf("users:%v:emotes", channel.Login),
+			Payload: redis.PubSubPayloadUserEmotes{
+				Removed: c.Action == "REMOVE",
+				ID:      c.Emote.ID.Hex(),
+				Actor:   actor.DisplayName,
+			},
+		}, AuditedEvent{
+			Channel: fmt.Sprintf("events-v1:channel-emotes:%s", channel.Login),
+			Payload: payload.Changes[i],
+		})
+	}
+
+	return append(events, AuditedEvent{
+		Channel: fmt.Sprintf("events-v1:channel-emotes-batch:%s", channel.Login),
+		Payload: payload,
+	})
+}
+
+// A change to a channel's emotes, announced in a batch
+type ChannelEmoteChange struct {
+	Emote  *datastructure.Emote
+	Name   string // The emote's name in the channel, or its name before it was removed
+	Action string // One of ADD, UPDATE or REMOVE
+}
+
+// Load the owners of the given emotes in a single query, keyed by their ID
+func emoteOwners(emotes []*datastructure.Emote) map[primitive.ObjectID]*datastructure.User {
+	ids := make([]primitive.ObjectID, 0, len(emotes))
+	for _, e := range emotes {
+		ids = append(ids, e.OwnerID)
+	}
+
+	owners := make(map[primitive.ObjectID]*datastructure.User, len(ids))
+	users := []*datastructure.User{}
+	cur, err := mongo.Collection(mongo.CollectionNameUsers).Find(context.Background(), bson.M{
+		"_id": bson.M{"$in": ids},
+	})
+	if err == nil {
+		err = cur.All(context.Background(), &users)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+	}
+	for _, u := range users {
+		owners[u.ID] = u
+	}
+
+	return owners
+}
+
+func channelEmotesPayload(channel *datastructure.User, emote *datastructure.Emote, name string, action string, actor *datastructure.User, owners map[primitive.ObjectID]*datastructure.User) redis.EventApiV1ChannelEmotes {
+	payload := redis.EventApiV1ChannelEmotes{
+		Channel: channel.Login,
+		EmoteID: emote.ID.Hex(),
+		Name:    name,
+		Action:  action,
+		Actor:   actor.DisplayName,
+	}
+	if action != "REMOVE" {
+		owner, ok := owners[emote.OwnerID]
+		if !ok {
+			owner = &datastructure.User{}
+		}
+
+		payload.Emote = &redis.EventApiV1ChannelEmotesEmote{
+			Name:       emote.Name,
+			Visibility: emote.Visibility,
+			MIME:       emote.Mime,
+			Tags:       emote.Tags,
+			Width:      emote.Width,
+			Height:     emote.Height,
+			Animated:   emote.Animated,
+			URLs:       datastructure.GetEmoteURLs(*emote),
+			Owner: redis.EventApiV1ChannelEmotesEmoteOwner{
+				ID:          emote.OwnerID.Hex(),
+				TwitchID:    owner.TwitchID,
+				DisplayName: owner.DisplayName,
+				Login:       owner.Login,
+			},
+		}
+	}
+
+	return payload
+}
@@ -0,0 +1,651 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	api_proxy "github.com/SevenTV/ServerGo/src/server/api/v2/proxy"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"github.com/SevenTV/ServerGo/src/validation"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+var (
+	ErrImportAccessDenied        = fmt.Errorf("access denied")
+	ErrImportInProgress          = fmt.Errorf("an import of this channel's emotes is already in progress")
+	ErrImportUnknownProvider     = fmt.Errorf("unknown provider")
+	ErrImportNotReady            = fmt.Errorf("the import is not awaiting confirmation")
+	ErrImportItemUnacceptable    = fmt.Errorf("the item can't be accepted")
+	ErrImportItemFiltered        = fmt.Errorf("name rejected by filter")
+	ErrImportProviderUnavailable = fmt.Errorf("couldn't fetch the channel's emotes from a provider")
+)
+
+// The providers whose emotes can be imported
+var EmoteImportProviders = []string{"BTTV", "FFZ"}
+
+const (
+	// How alike a 7TV emote must look to be matched, unless configured otherwise
+	DEFAULT_IMPORT_SIMILARITY = 0.9
+	// The most 7TV emotes of the same name compared to each imported emote, the most used first
+	MAX_IMPORT_CANDIDATES = 5
+	// The most imports of a channel which are kept
+	MAX_CHANNEL_EMOTE_IMPORTS = 10
+)
+
+var importHttpClient = &http.Client{Timeout: 15 * time.Second}
+
+// An accepted item of an import which can't be applied, which fails the whole confirmation
+type EmoteImportItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *EmoteImportItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *EmoteImportItemError) Unwrap() error {
+	return e.Err
+}
+
+// CreateImport: Queue a job matching a channel's emotes on other providers to 7TV emotes
+func (*emotes) CreateImport(ctx context.Context, actor *datastructure.User, channel *datastructure.User, providers []string) (*datastructure.EmoteImport, error) {
+	if !channel.CanEditChannel(actor, datastructure.UserEditorPermissionAddEmotes) {
+		return nil, ErrImportAccessDenied
+	}
+
+	list := []string{}
+	for _, p := range providers {
+		if !utils.Contains(EmoteImportProviders, p) {
+			return nil, ErrImportUnknownProvider
+		}
+		if !utils.Contains(list, p) {
+			list = append(list, p)
+		}
+	}
+	if len(list) == 0 {
+		return nil, ErrImportUnknownProvider
+	}
+
+	count, err := mongo.Collection(mongo.CollectionNameEmoteImports).CountDocuments(ctx, bson.M{
+		"channel_id": channel.ID,
+		"status":     bson.M{"$in": []datastructure.EmoteImportStatus{datastructure.EmoteImportStatusPending, datastructure.EmoteImportStatusProcessing}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrImportInProgress
+	}
+
+	now := time.Now()
+	imp := &datastructure.EmoteImport{
+		ID:        primitive.NewObjectID(),
+		ChannelID: channel.ID,
+		CreatedBy: actor.ID,
+		Providers: list,
+		Status:    datastructure.EmoteImportStatusPending,
+		Items:     []datastructure.EmoteImportItem{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := mongo.Collection(mongo.CollectionNameEmoteImports).InsertOne(ctx, imp); err != nil {
+		return nil, err
+	}
+
+	return imp, nil
+}
+
+// Imports: Get the most recent emote imports of a channel
+func (*emotes) Imports(ctx context.Context, channelID primitive.ObjectID) ([]*datastructure.EmoteImport, error) {
+	imports := []*datastructure.EmoteImport{}
+	cur, err := mongo.Collection(mongo.CollectionNameEmoteImports).Find(ctx, bson.M{"channel_id": channelID}, options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetLimit(MAX_CHANNEL_EMOTE_IMPORTS),
+	)
+	if err == nil {
+		err = cur.All(ctx, &imports)
+	}
+
+	return imports, err
+}
+
+// Import: Get an emote import, or nil if it doesn't exist
+func (*emotes) Import(ctx context.Context, id primitive.ObjectID) (*datastructure.EmoteImport, error) {
+	imp := &datastructure.EmoteImport{}
+	if err := mongo.Collection(mongo.CollectionNameEmoteImports).FindOne(ctx, bson.M{"_id": id}).Decode(imp); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return imp, nil
+}
+
+// ProcessImport: Fetch the channel's emotes from the import's providers and propose a 7TV emote for each,
+// then let the channel know that the plan awaits confirmation. The import fails if a provider can't be reached
+func (x *emotes) ProcessImport(ctx context.Context, imp *datastructure.EmoteImport) error {
+	items, err := x.planImport(ctx, imp)
+	update := bson.M{"updated_at": time.Now()}
+	if err != nil {
+		update["status"] = datastructure.EmoteImportStatusFailed
+		update["error"] = "an internal error occurred"
+		if errors.Is(err, ErrImportProviderUnavailable) {
+			update["error"] = err.Error()
+		} else {
+			log.WithError(err).WithField("import_id", imp.ID).Error("emote import")
+		}
+	} else {
+		update["status"] = datastructure.EmoteImportStatusReady
+		update["items"] = items
+	}
+
+	res, err := mongo.Collection(mongo.CollectionNameEmoteImports).UpdateOne(ctx, bson.M{
+		"_id":    imp.ID,
+		"status": datastructure.EmoteImportStatusProcessing,
+	}, bson.M{"$set": update})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return nil // Another pod finished it meanwhile
+	}
+
+	notify := Notifications.Create().
+		AddTargetUsers(imp.ChannelID)
+	if imp.CreatedBy != imp.ChannelID {
+		notify = notify.AddTargetUsers(imp.CreatedBy)
+	}
+	if update["status"] == datastructure.EmoteImportStatusReady {
+		notify = notify.SetTitle("Emote Import Ready").
+			AddTextMessagePart(fmt.Sprintf("The emotes of your channel on %v were matched to 7TV emotes, and await your confirmation.", strings.Join(imp.Providers, " and ")))
+	} else {
+		notify = notify.SetTitle("Emote Import Failed").
+			AddTextMessagePart(fmt.Sprintf("The emotes of your channel on %v could not be imported: %v.", strings.Join(imp.Providers, " and "), update["error"]))
+	}
+	if err := notify.Write(ctx); err != nil {
+		log.WithError(err).Error("notifications")
+	}
+
+	return nil
+}
+
+func (x *emotes) planImport(ctx context.Context, imp *datastructure.EmoteImport) ([]datastructure.EmoteImportItem, error) {
+	channel := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": imp.ChannelID}).Decode(channel); err != nil {
+		return nil, err
+	}
+
+	thirdParty := []*datastructure.Emote{}
+	for _, p := range imp.Providers {
+		var emotes []*datastructure.Emote
+		var err error
+		switch p {
+		case "BTTV":
+			emotes, err = api_proxy.GetChannelEmotesBTTV(ctx, channel.Login)
+		case "FFZ":
+			emotes, err = api_proxy.GetChannelEmotesFFZ(ctx, channel.Login)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrImportProviderUnavailable, p)
+		}
+		thirdParty = append(thirdParty, emotes...)
+	}
+
+	// The channel's emotes, by their name in the channel
+	channelEmotes := []*datastructure.Emote{}
+	cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{"_id": bson.M{"$in": append([]primitive.ObjectID{}, channel.EmoteIDs...)}})
+	if err == nil {
+		err = cur.All(ctx, &channelEmotes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	named := make(map[string]*datastructure.Emote, len(channelEmotes))
+	for _, e := range channelEmotes {
+		name := e.Name
+		if alias, ok := channel.EmoteAlias[e.ID.Hex()]; ok {
+			name = alias
+		}
+		named[name] = e
+	}
+
+	threshold := configure.Config.GetFloat64("emote_import.similarity_threshold")
+	if threshold <= 0 {
+		threshold = DEFAULT_IMPORT_SIMILARITY
+	}
+
+	items := make([]datastructure.EmoteImportItem, 0, len(thirdParty))
+	for _, e := range thirdParty {
+		if e == nil || e.ProviderID == nil || len(e.URLs) == 0 {
+			continue
+		}
+		item := datastructure.EmoteImportItem{
+			Provider:   e.Provider,
+			ProviderID: *e.ProviderID,
+			Name:       e.Name,
+		}
+		if e.Owner != nil {
+			item.AuthorLogin = e.Owner.Login
+			item.AuthorDisplayName = e.Owner.DisplayName
+		}
+
+		// Hash the largest image which can be fetched, which is also the one re-hosted
+		var hash uint64
+		for i := len(e.URLs) - 1; i >= 0 && item.ImageURL == ""; i-- {
+			h, err := remoteImageHash(ctx, e.URLs[i][1])
+			if err != nil {
+				continue
+			}
+			item.ImageURL, hash = e.URLs[i][1], h
+		}
+		if item.ImageURL == "" {
+			item.ImageURL = e.URLs[len(e.URLs)-1][1]
+			item.Proposal = datastructure.EmoteImportProposalUnavailable
+			items = append(items, item)
+			continue
+		}
+
+		// The channel already has an emote of this name
+		if ce, ok := named[e.Name]; ok {
+			item.EmoteID = &ce.ID
+			item.Proposal = datastructure.EmoteImportProposalNameTaken
+			if h, err := emoteImageHash(ctx, ce.ID); err == nil {
+				if item.Similarity = utils.HashSimilarity(hash, h); item.Similarity >= threshold {
+					item.Proposal = datastructure.EmoteImportProposalAlreadyAdded
+				}
+			}
+			items = append(items, item)
+			continue
+		}
+
+		// Compare the most used live emotes of the same name
+		candidates := []*datastructure.Emote{}
+		cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{
+			"name":       e.Name,
+			"status":     datastructure.EmoteStatusLive,
+			"visibility": bson.M{"$bitsAllClear": datastructure.EmoteVisibilityPrivate},
+		}, options.Find().SetSort(bson.M{"channel_count": -1}).SetLimit(MAX_IMPORT_CANDIDATES))
+		if err == nil {
+			err = cur.All(ctx, &candidates)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item.Proposal = datastructure.EmoteImportProposalUnmatched
+		for _, c := range candidates {
+			h, err := emoteImageHash(ctx, c.ID)
+			if err != nil {
+				log.WithError(err).WithField("emote_id", c.ID).Warn("emote import, couldn't hash emote")
+				continue
+			}
+			if similarity := utils.HashSimilarity(hash, h); similarity >= threshold && similarity > item.Similarity {
+				id := c.ID
+				item.EmoteID = &id
+				item.Similarity = similarity
+				item.Proposal = datastructure.EmoteImportProposalMatched
+			}
+		}
+		if item.Proposal == datastructure.EmoteImportProposalUnmatched {
+			item.Similarity = 0
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+type EmoteImportConfirmation struct {
+	Actor   *datastructure.User
+	Channel *datastructure.User
+	Import  *datastructure.EmoteImport
+	Items   []int // The positions of the accepted items
+	Rehost  bool  // Whether accepted items which no 7TV emote matched are re-hosted
+	Reason  *string
+}
+
+// ConfirmImport: Apply the accepted items of an import's plan to its channel, which only the channel's owner may do
+//
+// Accepted items which no 7TV emote matched are re-hosted as new emotes owned by the imported user and credited to their author.
+// Like other uploads, they may be held for review, and are then not added yet. The other accepted emotes are added as a batch,
+// after taking a restore point. Returns the import as updated
+func (x *emotes) ConfirmImport(ctx context.Context, c EmoteImportConfirmation) (*datastructure.EmoteImport, error) {
+	imp := c.Import
+	if c.Actor.ID != c.Channel.ID && !c.Actor.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, ErrImportAccessDenied
+	}
+	if imp.Status != datastructure.EmoteImportStatusReady {
+		return nil, ErrImportNotReady
+	}
+
+	accepted := make(map[int]bool, len(c.Items))
+	for _, i := range c.Items {
+		if i < 0 || i >= len(imp.Items) {
+			return nil, &EmoteImportItemError{Index: i, Err: ErrImportItemUnacceptable}
+		}
+		item := imp.Items[i]
+		switch item.Proposal {
+		case datastructure.EmoteImportProposalMatched, datastructure.EmoteImportProposalRehosted:
+		case datastructure.EmoteImportProposalUnmatched:
+			if !c.Rehost || item.AuthorLogin == "" || !validation.ValidateEmoteName(utils.S2B(item.Name)) {
+				return nil, &EmoteImportItemError{Index: i, Err: ErrImportItemUnacceptable}
+			}
+		default:
+			return nil, &EmoteImportItemError{Index: i, Err: ErrImportItemUnacceptable}
+		}
+		accepted[i] = true
+	}
+
+	// Only one confirmation of an import runs at a time
+	lock, err := redis.GetLocker().Obtain(ctx, fmt.Sprintf("lock:emote-import:%v", imp.ID.Hex()), time.Minute*5, &redislock.Options{})
+	if err == redislock.ErrNotObtained {
+		return nil, ErrImportNotReady
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = lock.Release(context.Background())
+	}()
+
+	// Re-host the accepted items no 7TV emote matched. They are saved as they go, so that a failed confirmation doesn't upload them twice
+	items := append([]datastructure.EmoteImportItem{}, imp.Items...)
+	save := func(status datastructure.EmoteImportStatus) error {
+		_, err := mongo.Collection(mongo.CollectionNameEmoteImports).UpdateOne(ctx, bson.M{"_id": imp.ID}, bson.M{
+			"$set": bson.M{"items": items, "status": status, "updated_at": time.Now()},
+		})
+		return err
+	}
+	for i := range items {
+		if !accepted[i] || items[i].Proposal != datastructure.EmoteImportProposalUnmatched {
+			continue
+		}
+
+		emote, err := x.rehostImportItem(ctx, c.Actor, &items[i], c.Reason)
+		if err != nil {
+			if saveErr := save(datastructure.EmoteImportStatusReady); saveErr != nil {
+				log.WithError(saveErr).Error("mongo")
+			}
+			return nil, &EmoteImportItemError{Index: i, Err: err}
+		}
+		items[i].EmoteID = &emote.ID
+		items[i].Similarity = 1
+		items[i].Proposal = datastructure.EmoteImportProposalRehosted
+	}
+
+	// Add the accepted emotes which are live, those held for review are left out
+	ids := []primitive.ObjectID{}
+	for i, item := range items {
+		if accepted[i] && item.EmoteID != nil {
+			ids = append(ids, *item.EmoteID)
+		}
+	}
+	live := []*datastructure.Emote{}
+	cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{
+		"_id":    bson.M{"$in": ids},
+		"status": datastructure.EmoteStatusLive,
+	})
+	if err == nil {
+		err = cur.All(ctx, &live)
+	}
+	if err != nil {
+		return nil, err
+	}
+	isLive := make(map[primitive.ObjectID]bool, len(live))
+	for _, e := range live {
+		isLive[e.ID] = true
+	}
+
+	operations := []ChannelEmoteOperation{}
+	opItems := []int{} // The item of each operation
+	for i, item := range items {
+		if !accepted[i] || item.EmoteID == nil || !isLive[*item.EmoteID] {
+			continue
+		}
+		operations = append(operations, ChannelEmoteOperation{Action: ChannelEmoteActionAdd, EmoteID: *item.EmoteID})
+		opItems = append(opItems, i)
+	}
+	if len(operations) > 0 {
+		if _, err := x.ApplyChannelBatch(ctx, ChannelEmoteBatch{
+			Actor:        c.Actor,
+			Channel:      c.Channel,
+			Operations:   operations,
+			Reason:       c.Reason,
+			AuditType:    datastructure.AuditLogTypeUserChannelEmoteImport,
+			RestorePoint: utils.StringPointer(fmt.Sprintf("Before emotes were imported from %v", strings.Join(imp.Providers, " and "))),
+		}); err != nil {
+			if saveErr := save(datastructure.EmoteImportStatusReady); saveErr != nil {
+				log.WithError(saveErr).Error("mongo")
+			}
+
+			// Point at the item rather than the operation which failed
+			batchErr := &ChannelEmoteBatchError{}
+			if errors.As(err, &batchErr) && batchErr.Index >= 0 {
+				i := opItems[batchErr.Index]
+				batchErr.Index = -1
+				return nil, &EmoteImportItemError{Index: i, Err: batchErr}
+			}
+			return nil, err
+		}
+	}
+
+	for i := range items {
+		items[i].Accepted = accepted[i]
+	}
+	if err := save(datastructure.EmoteImportStatusApplied); err != nil {
+		return nil, err
+	}
+
+	result := *imp
+	result.Items = items
+	result.Status = datastructure.EmoteImportStatusApplied
+	return &result, nil
+}
+
+// Upload the image of an import item as a new emote, owned by the imported user and credited to its author
+func (x *emotes) rehostImportItem(ctx context.Context, actor *datastructure.User, item *datastructure.EmoteImportItem, reason *string) (*datastructure.Emote, error) {
+	// Names are checked as for uploads
+	filtered := false
+	if !actor.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+		match, err := Filter.Check(ctx, item.Name)
+		if err != nil {
+			return nil, err
+		}
+		if match != nil {
+			if match.Rule.Action == datastructure.FilterActionReject {
+				return nil, ErrImportItemFiltered
+			}
+			filtered = true
+		}
+	}
+
+	data, err := fetchImage(ctx, item.ImageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	id := primitive.NewObjectID()
+	sizes, err := x.UploadImage(id, data)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := x.RequiresReview(ctx, actor)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		pending = true
+	}
+	pending = pending || filtered
+
+	now := time.Now()
+	emote := &datastructure.Emote{
+		ID:               id,
+		Name:             item.Name,
+		OwnerID:          datastructure.ImportedUser.ID,
+		Mime:             "image/webp",
+		Status:           utils.Ternary(pending, datastructure.EmoteStatusPending, datastructure.EmoteStatusLive).(int32),
+		Tags:             []string{},
+		SharedWith:       []primitive.ObjectID{},
+		LastModifiedDate: now,
+		Width:            sizes.Width,
+		Height:           sizes.Height,
+		Animated:         sizes.Animated,
+		Attribution: &datastructure.EmoteAttribution{
+			Provider:          item.Provider,
+			ProviderID:        item.ProviderID,
+			AuthorLogin:       item.AuthorLogin,
+			AuthorDisplayName: item.AuthorDisplayName,
+			ImportedAt:        now,
+		},
+	}
+	created := &datastructure.Emote{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  actor,
+		Type:   datastructure.AuditLogTypeEmoteImport,
+		Target: &datastructure.Target{ID: &id, Type: "emotes"},
+		Reason: reason,
+		After:  created,
+		Ignore: []string{"edited_at"},
+		Apply: func(ctx context.Context) error {
+			// The imported user owns the emote, its document is created on first use.
+			// It has no Twitch account, so its login stands in for the unique Twitch ID
+			if _, err := mongo.Collection(mongo.CollectionNameUsers).UpdateOne(ctx, bson.M{"_id": datastructure.ImportedUser.ID}, bson.M{
+				"$setOnInsert": bson.M{
+					"id":           datastructure.ImportedUser.Login,
+					"login":        datastructure.ImportedUser.Login,
+					"display_name": datastructure.ImportedUser.DisplayName,
+					"emotes":       []primitive.ObjectID{},
+					"editors":      []primitive.ObjectID{},
+				},
+			}, options.Update().SetUpsert(true)); err != nil {
+				return err
+			}
+
+			if _, err := mongo.Collection(mongo.CollectionNameEmotes).InsertOne(ctx, emote); err != nil {
+				return err
+			}
+			return mongo.Collection(mongo.CollectionNameEmotes).FindOne(ctx, bson.M{"_id": id}).Decode(created)
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// Get the perceptual hash of a 7TV emote's smallest image, which is cached as emote images don't change
+func emoteImageHash(ctx context.Context, emoteID primitive.ObjectID) (uint64, error) {
+	key := fmt.Sprintf("emote-hash:%v", emoteID.Hex())
+	if v, err := redis.Client.Get(ctx, key).Result(); err == nil {
+		if hash, err := strconv.ParseUint(v, 16, 64); err == nil {
+			return hash, nil
+		}
+	} else if err != redis.ErrNil {
+		return 0, err
+	}
+
+	hash, err := remoteImageHash(ctx, utils.GetCdnURL(emoteID.Hex(), 1))
+	if err != nil {
+		return 0, err
+	}
+	if err := redis.Client.Set(ctx, key, strconv.FormatUint(hash, 16), time.Hour*24*7).Err(); err != nil {
+		log.WithError(err).Error("redis")
+	}
+
+	return hash, nil
+}
+
+// Get the perceptual hash of an image on the web, from its first frame.
+// Its dimensions are read before it is decoded, and images larger than an emote may be are rejected
+func remoteImageHash(ctx context.Context, url string) (uint64, error) {
+	data, err := fetchImage(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+
+	var img image.Image
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		if !validImageSize(cfg.Width, cfg.Height) {
+			return 0, ErrInvalidEmoteImage
+		}
+		if img, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return 0, ErrInvalidEmoteImage
+		}
+	} else {
+		// WEBP isn't decoded by the standard library, so ImageMagick converts it first
+		mw := imagick.NewMagickWand()
+		defer mw.Destroy()
+		if err := mw.SetResourceLimit(imagick.RESOURCE_MEMORY, 500); err != nil {
+			log.WithError(err).Error("SetResourceLimit")
+		}
+		if err := mw.PingImageBlob(data); err != nil {
+			return 0, ErrInvalidEmoteImage
+		}
+		if !validImageSize(int(mw.GetImageWidth()), int(mw.GetImageHeight())) || mw.GetNumberImages() > MAX_EMOTE_FRAME_COUNT {
+			return 0, ErrInvalidEmoteImage
+		}
+		mw.Clear()
+		if err := mw.ReadImageBlob(data); err != nil {
+			return 0, ErrInvalidEmoteImage
+		}
+		mw.SetIteratorIndex(0)
+		if err := mw.SetImageFormat("png"); err != nil {
+			return 0, err
+		}
+		if img, err = png.Decode(bytes.NewReader(mw.GetImageBlob())); err != nil {
+			return 0, err
+		}
+	}
+
+	return utils.DifferenceHash(img), nil
+}
+
+func validImageSize(width int, height int) bool {
+	return width > 0 && height > 0 && width <= MAX_EMOTE_PIXEL_SIZE && height <= MAX_EMOTE_PIXEL_SIZE
+}
+
+func fetchImage(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := importHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v responded with %v", url, res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, MAX_EMOTE_FILE_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MAX_EMOTE_FILE_SIZE {
+		return nil, ErrInvalidEmoteImage
+	}
+
+	return data, nil
+}
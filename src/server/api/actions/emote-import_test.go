@@ -0,0 +1,255 @@
+//go:build integration
+// +build integration
+
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	api_proxy "github.com/SevenTV/ServerGo/src/server/api/v2/proxy"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A stand-in for FFZ and the 7TV CDN, serving the rooms and images given to it
+type importServer struct {
+	*httptest.Server
+	mx      sync.Mutex
+	rooms   map[string][]map[string]interface{} // FFZ emotes by channel login
+	ffz     map[string][]byte                   // FFZ images by emote ID
+	sevenTV map[string][]byte                   // 7TV images by emote ID
+}
+
+func newImportServer(t *testing.T) *importServer {
+	s := &importServer{
+		rooms:   map[string][]map[string]interface{}{},
+		ffz:     map[string][]byte{},
+		sevenTV: map[string][]byte{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		var data []byte
+		switch {
+		case len(parts) == 3 && parts[0] == "ffz" && parts[1] == "rooms":
+			data, _ = json.Marshal(map[string]interface{}{"emotes": s.rooms[parts[2]]})
+		case len(parts) == 4 && parts[0] == "ffz-cdn" && parts[1] == "emoticon":
+			data = s.ffz[parts[2]]
+		case len(parts) == 4 && parts[0] == "cdn" && parts[1] == "emote":
+			data = s.sevenTV[parts[2]]
+		}
+		if data == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+
+	configure.Config.Set("proxy.ffz.api_url", s.URL+"/ffz")
+	configure.Config.Set("proxy.ffz.cdn_url", s.URL+"/ffz-cdn")
+	configure.Config.Set("cdn_url", s.URL+"/cdn")
+	api_proxy.Configure()
+	return s
+}
+
+// A PNG shaded from black to white, or from white to black when reversed, whose hashes are opposite
+func gradientPNG(t *testing.T, width int, height int, reversed bool) []byte {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		v := uint8(x * 255 / (width - 1))
+		if reversed {
+			v = 255 - v
+		}
+		for y := 0; y < height; y++ {
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Insert a live 7TV emote served by the stand-in CDN, removed with its cached hash once the test ends
+func insertImportEmote(t *testing.T, srv *importServer, name string, channelCount int32, img []byte) *datastructure.Emote {
+	ctx := context.Background()
+	e := &datastructure.Emote{
+		ID:           primitive.NewObjectID(),
+		Name:         name,
+		OwnerID:      primitive.NewObjectID(),
+		Status:       datastructure.EmoteStatusLive,
+		Tags:         []string{},
+		SharedWith:   []primitive.ObjectID{},
+		ChannelCount: &channelCount,
+	}
+	if _, err := mongo.Collection(mongo.CollectionNameEmotes).InsertOne(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	srv.mx.Lock()
+	srv.sevenTV[e.ID.Hex()] = img
+	srv.mx.Unlock()
+
+	t.Cleanup(func() {
+		_, _ = mongo.Collection(mongo.CollectionNameEmotes).DeleteOne(ctx, bson.M{"_id": e.ID})
+		_ = redis.Client.Del(ctx, fmt.Sprintf("emote-hash:%v", e.ID.Hex())).Err()
+	})
+	return e
+}
+
+func TestEmoteImageHash(t *testing.T) {
+	ctx := context.Background()
+	srv := newImportServer(t)
+	suffix := primitive.NewObjectID().Hex()
+
+	a := insertImportEmote(t, srv, "hashA"+suffix, 0, gradientPNG(t, 32, 32, false))
+	b := insertImportEmote(t, srv, "hashB"+suffix, 0, gradientPNG(t, 64, 48, false))
+	c := insertImportEmote(t, srv, "hashC"+suffix, 0, gradientPNG(t, 32, 32, true))
+
+	hashes := map[primitive.ObjectID]uint64{}
+	for _, e := range []*datastructure.Emote{a, b, c} {
+		h, err := emoteImageHash(ctx, e.ID)
+		if err != nil {
+			t.Fatalf("%v: %v", e.Name, err)
+		}
+		hashes[e.ID] = h
+	}
+
+	// The same picture at another size looks alike, its reverse doesn't
+	if s := utils.HashSimilarity(hashes[a.ID], hashes[b.ID]); s < DEFAULT_IMPORT_SIMILARITY {
+		t.Errorf("resized image: similarity %v, want at least %v", s, DEFAULT_IMPORT_SIMILARITY)
+	}
+	if s := utils.HashSimilarity(hashes[a.ID], hashes[c.ID]); s >= DEFAULT_IMPORT_SIMILARITY {
+		t.Errorf("reversed image: similarity %v, want below %v", s, DEFAULT_IMPORT_SIMILARITY)
+	}
+
+	// Hashes are cached, so they are known once the image is gone
+	srv.mx.Lock()
+	delete(srv.sevenTV, a.ID.Hex())
+	srv.mx.Unlock()
+	if h, err := emoteImageHash(ctx, a.ID); err != nil || h != hashes[a.ID] {
+		t.Errorf("cached hash: got %x, %v, want %x", h, err, hashes[a.ID])
+	}
+}
+
+func TestRemoteImageHashRejectsLargeImages(t *testing.T) {
+	srv := newImportServer(t)
+	id := primitive.NewObjectID()
+	srv.sevenTV[id.Hex()] = gradientPNG(t, MAX_EMOTE_PIXEL_SIZE+1, 2, false)
+
+	if _, err := remoteImageHash(context.Background(), utils.GetCdnURL(id.Hex(), 1)); err != ErrInvalidEmoteImage {
+		t.Fatalf("got %v, want %v", err, ErrInvalidEmoteImage)
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	ctx := context.Background()
+	srv := newImportServer(t)
+	suffix := primitive.NewObjectID().Hex()
+	forward, reversed := gradientPNG(t, 32, 32, false), gradientPNG(t, 32, 32, true)
+
+	// A match is the most alike of the emotes of the same name, not the most used
+	matchedName := "matched" + suffix
+	lookalike := insertImportEmote(t, srv, matchedName, 1, forward)
+	insertImportEmote(t, srv, matchedName, 100, reversed)
+
+	// Only emotes which look different share this name
+	unmatchedName := "unmatched" + suffix
+	insertImportEmote(t, srv, unmatchedName, 1, reversed)
+
+	// The channel has emotes under the names of two FFZ emotes, one of which looks alike
+	added := insertImportEmote(t, srv, "original"+suffix, 1, forward)
+	taken := insertImportEmote(t, srv, "taken"+suffix, 1, forward)
+	addedName, takenName := "added"+suffix, "taken"+suffix
+
+	channel := &datastructure.User{
+		ID:         primitive.NewObjectID(),
+		Login:      "importer_" + suffix,
+		EmoteIDs:   []primitive.ObjectID{added.ID, taken.ID},
+		EmoteAlias: map[string]string{added.ID.Hex(): addedName},
+	}
+	if _, err := mongo.Collection(mongo.CollectionNameUsers).InsertOne(ctx, channel); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = mongo.Collection(mongo.CollectionNameUsers).DeleteOne(ctx, bson.M{"_id": channel.ID})
+	})
+
+	ffzEmote := func(id int, name string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "name": name, "owner": map[string]interface{}{"name": "author", "display_name": "Author"}}
+	}
+	srv.mx.Lock()
+	srv.rooms[channel.Login] = []map[string]interface{}{
+		ffzEmote(1, matchedName),
+		ffzEmote(2, unmatchedName),
+		ffzEmote(3, addedName),
+		ffzEmote(4, takenName),
+		ffzEmote(5, "unavailable"+suffix),
+	}
+	srv.ffz["1"] = forward
+	srv.ffz["2"] = forward
+	srv.ffz["3"] = forward
+	srv.ffz["4"] = reversed
+	srv.mx.Unlock()
+
+	items, err := Emotes.planImport(ctx, &datastructure.EmoteImport{
+		ID:        primitive.NewObjectID(),
+		ChannelID: channel.ID,
+		Providers: []string{"FFZ"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		proposal datastructure.EmoteImportProposal
+		emoteID  *primitive.ObjectID
+	}{
+		{datastructure.EmoteImportProposalMatched, &lookalike.ID},
+		{datastructure.EmoteImportProposalUnmatched, nil},
+		{datastructure.EmoteImportProposalAlreadyAdded, &added.ID},
+		{datastructure.EmoteImportProposalNameTaken, &taken.ID},
+		{datastructure.EmoteImportProposalUnavailable, nil},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for i, w := range want {
+		item := items[i]
+		if item.Proposal != w.proposal {
+			t.Errorf("%v: proposal %v, want %v", item.Name, item.Proposal, w.proposal)
+		}
+		if (item.EmoteID == nil) != (w.emoteID == nil) || (w.emoteID != nil && *item.EmoteID != *w.emoteID) {
+			t.Errorf("%v: emote %v, want %v", item.Name, item.EmoteID, w.emoteID)
+		}
+		if item.AuthorLogin != "author" {
+			t.Errorf("%v: author %q, want %q", item.Name, item.AuthorLogin, "author")
+		}
+	}
+	if items[0].Similarity < DEFAULT_IMPORT_SIMILARITY {
+		t.Errorf("matched similarity %v, want at least %v", items[0].Similarity, DEFAULT_IMPORT_SIMILARITY)
+	}
+	if items[1].Similarity != 0 {
+		t.Errorf("unmatched similarity %v, want 0", items[1].Similarity)
+	}
+}
@@ -66,6 +66,12 @@ func (*emotes) MergeEmote(ctx context.Context, opts MergeEmoteOptions) (*datastr
 			return nil, err
 		}
 
+		// Keep the channels as they were, should the merge need undoing.
+		// They are taken before the aliases below are changed in place
+		if _, err := Users.CreateRestorePoints(ctx, channels, opts.Actor, fmt.Sprintf("Before the emote %v was merged into %v", oldEmote.Name, newEmote.Name)); err != nil {
+			return nil, err
+		}
+
 		// Find aliases
 		for _, ch := range channels {
 			update := bson.M{
@@ -186,23 +192,19 @@ func (*emotes) MergeEmote(ctx context.Context, opts MergeEmoteOptions) (*datastr
 		go discord.SendEmoteMerge(oldEmote, newEmote, *opts.Actor, int32(len(switchedChannels)), opts.Reason)
 	}
 
-	// Now we will delete the old emote
-	if err := Emotes.Delete(ctx, &oldEmote); err != nil {
-		return nil, err
-	}
-
-	// Create an Audit Log
-	_, err := mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeEmoteMerge,
-		CreatedBy: opts.Actor.ID,
-		Target:    &datastructure.Target{ID: &oldEmote.ID, Type: "emotes"},
-		Changes: []*datastructure.AuditLogChange{
-			{Key: "merged_into", OldValue: nil, NewValue: newEmote.ID},
-		},
+	// Now we will delete the old emote, recording the merge along with it
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  opts.Actor,
+		Type:   datastructure.AuditLogTypeEmoteMerge,
+		Target: &datastructure.Target{ID: &oldEmote.ID, Type: "emotes"},
 		Reason: &opts.Reason,
-	})
-	if err != nil {
-		log.WithError(err).Error("mongo")
+		Before: bson.M{},
+		After:  bson.M{"merged_into": newEmote.ID},
+		Apply: func(ctx context.Context) error {
+			return Emotes.Delete(ctx, &oldEmote)
+		},
+	}); err != nil {
+		return nil, err
 	}
 
 	return &newEmote, nil
@@ -0,0 +1,36 @@
+package actions
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RequiresReview: Whether emotes uploaded by a user must be approved by a moderator before going live
+func (*emotes) RequiresReview(ctx context.Context, usr *datastructure.User) (bool, error) {
+	if usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+		return false, nil
+	}
+
+	threshold := configure.Config.GetInt32("emote_review.default_threshold")
+	if usr.Role != nil && usr.Role.EmoteReviewThreshold != nil {
+		threshold = *usr.Role.EmoteReviewThreshold
+	}
+	if threshold <= 0 {
+		return false, nil
+	}
+
+	count, err := mongo.Collection(mongo.CollectionNameEmotes).CountDocuments(ctx, bson.M{
+		"owner":  usr.ID,
+		"status": datastructure.EmoteStatusLive,
+	}, options.Count().SetLimit(int64(threshold)))
+	if err != nil {
+		return false, err
+	}
+
+	return count < int64(threshold), nil
+}
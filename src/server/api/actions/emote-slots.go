@@ -0,0 +1,130 @@
+package actions
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A user's channel emote slots, and where they come from
+type EmoteSlotBreakdown struct {
+	Total   int32
+	Used    int32
+	Sources []*EmoteSlotSource
+}
+
+// Whether the channel holds more emotes than it has slots for, such as after a subscription ended.
+// Its emotes are kept, but none can be added until enough are removed
+func (b *EmoteSlotBreakdown) OverLimit() bool {
+	return b.Used > b.Total
+}
+
+// Whether a count of emotes can be added to the channel
+func (b *EmoteSlotBreakdown) CanAdd(count int) bool {
+	return int(b.Used)+count <= int(b.Total)
+}
+
+// A source of channel emote slots
+type EmoteSlotSource struct {
+	Kind  EmoteSlotSourceKind
+	Slots int32
+	// Whether the slots count toward the total. Of the default and the user's roles only the largest base applies,
+	// and a manual override replaces it, while entitlements add to it
+	Applied bool
+	RefID   *primitive.ObjectID // The role or entitlement granting the slots
+	Name    string
+}
+
+type EmoteSlotSourceKind string
+
+var (
+	EmoteSlotSourceDefault      = EmoteSlotSourceKind("DEFAULT")      // The configured default
+	EmoteSlotSourceRole         = EmoteSlotSourceKind("ROLE")         // A role held directly or through an entitlement
+	EmoteSlotSourceOverride     = EmoteSlotSourceKind("OVERRIDE")     // Set on the user by an admin
+	EmoteSlotSourceSubscription = EmoteSlotSourceKind("SUBSCRIPTION") // The perk of a subscription entitlement
+)
+
+// EmoteSlots: Compute a user's channel emote slots from the default, their roles, their entitlements and any manual override
+func (users) EmoteSlots(ctx context.Context, user *datastructure.User) (*EmoteSlotBreakdown, error) {
+	b := &EmoteSlotBreakdown{Used: int32(len(user.EmoteIDs))}
+
+	// The bases: the default and the slots of every role held
+	bases := []*EmoteSlotSource{{
+		Kind:  EmoteSlotSourceDefault,
+		Slots: configure.Config.GetInt32("limits.meta.channel_emote_slots"),
+	}}
+	addRole := func(role datastructure.Role) {
+		if role.EmoteSlots > 0 {
+			id := role.ID
+			bases = append(bases, &EmoteSlotSource{
+				Kind:  EmoteSlotSourceRole,
+				Slots: role.EmoteSlots,
+				RefID: &id,
+				Name:  role.Name,
+			})
+		}
+	}
+	addRole(datastructure.GetRole(user.RoleID))
+
+	filter := ActiveEntitlementFilter()
+	filter["user_id"] = user.ID
+	filter["kind"] = bson.M{"$in": bson.A{datastructure.EntitlementKindRole, datastructure.EntitlementKindSubscription}}
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, filter)
+	if err == nil {
+		err = cur.All(ctx, &ents)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bonuses := []*EmoteSlotSource{}
+	for _, e := range ents {
+		eb := Entitlements.With(ctx, *e)
+		switch e.Kind {
+		case datastructure.EntitlementKindRole:
+			roleID := eb.ReadRoleData().ObjectReference
+			if role := datastructure.GetRole(&roleID); role.ID == roleID {
+				addRole(role)
+			}
+		case datastructure.EntitlementKindSubscription:
+			if slots := eb.ReadSubscriptionData().EmoteSlots; slots > 0 {
+				id := e.ID
+				bonuses = append(bonuses, &EmoteSlotSource{
+					Kind:    EmoteSlotSourceSubscription,
+					Slots:   slots,
+					Applied: true,
+					RefID:   &id,
+				})
+			}
+		}
+	}
+
+	// Apply the largest base, unless an admin overrode it
+	base := bases[0]
+	for _, s := range bases[1:] {
+		if s.Slots > base.Slots {
+			base = s
+		}
+	}
+	if user.EmoteSlots > 0 {
+		base = &EmoteSlotSource{
+			Kind:  EmoteSlotSourceOverride,
+			Slots: user.EmoteSlots,
+		}
+		bases = append(bases, base)
+	}
+	base.Applied = true
+
+	b.Sources = append(bases, bonuses...)
+	for _, s := range b.Sources {
+		if s.Applied {
+			b.Total += s.Slots
+		}
+	}
+	return b, nil
+}
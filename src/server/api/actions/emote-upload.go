@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SevenTV/ServerGo/src/aws"
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+var ErrInvalidEmoteImage = fmt.Errorf("invalid emote image")
+
+const (
+	MAX_EMOTE_FILE_SIZE   = 2500000
+	MAX_EMOTE_PIXEL_SIZE  = 3000
+	MAX_EMOTE_FRAME_COUNT = 4096
+)
+
+// The size of each of an emote's images, as uploaded
+type EmoteImageSizes struct {
+	Width    [4]int16
+	Height   [4]int16
+	Animated bool
+}
+
+// UploadImage: Resize an emote's image to each size served by the CDN and upload them as WEBP
+func (*emotes) UploadImage(emoteID primitive.ObjectID, data []byte) (*EmoteImageSizes, error) {
+	if len(data) == 0 || len(data) > MAX_EMOTE_FILE_SIZE {
+		return nil, ErrInvalidEmoteImage
+	}
+
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+	if err := mw.SetResourceLimit(imagick.RESOURCE_MEMORY, 500); err != nil {
+		log.WithError(err).Error("SetResourceLimit")
+	}
+	if err := mw.ReadImageBlob(data); err != nil {
+		return nil, ErrInvalidEmoteImage
+	}
+	if mw.GetNumberImages() > MAX_EMOTE_FRAME_COUNT {
+		return nil, ErrInvalidEmoteImage
+	}
+
+	// Merge all frames with coalesce
+	aw := mw.CoalesceImages()
+	defer aw.Destroy()
+	width, height := float64(aw.GetImageWidth()), float64(aw.GetImageHeight())
+	if width == 0 || height == 0 || width > MAX_EMOTE_PIXEL_SIZE || height > MAX_EMOTE_PIXEL_SIZE {
+		return nil, ErrInvalidEmoteImage
+	}
+
+	sizes := &EmoteImageSizes{Animated: aw.GetNumberImages() > 1}
+	mime := "image/webp"
+	for i, file := range datastructure.EmoteUtil.GetFilesMeta("") {
+		bounds := strings.Split(file[2], "x")
+		maxWidth, _ := strconv.ParseFloat(bounds[0], 64)
+		maxHeight, _ := strconv.ParseFloat(bounds[1], 64)
+		quality, _ := strconv.Atoi(file[3])
+
+		w, h := utils.GetSizeRatio([]float64{width, height}, []float64{maxWidth, maxHeight})
+		sizes.Width[i], sizes.Height[i] = int16(w), int16(h)
+
+		out := imagick.NewMagickWand()
+		for ind := 0; ind < int(aw.GetNumberImages()); ind++ {
+			aw.SetIteratorIndex(ind)
+			img := aw.GetImage()
+			if err := img.ResizeImage(uint(w), uint(h), imagick.FILTER_LANCZOS); err != nil {
+				log.WithError(err).Errorf("ResizeImage i=%v", ind)
+			} else if err := out.AddImage(img); err != nil {
+				log.WithError(err).Errorf("AddImage i=%v", ind)
+			}
+			img.Destroy()
+		}
+		if err := out.SetImageCompressionQuality(uint(quality)); err != nil {
+			log.WithError(err).Error("SetImageCompressionQuality")
+		}
+		if err := out.SetImageFormat("webp"); err != nil {
+			log.WithError(err).Error("SetImageFormat")
+		}
+		blob := out.GetImagesBlob()
+		out.Destroy()
+
+		if err := aws.UploadFile(configure.Config.GetString("aws_cdn_bucket"), fmt.Sprintf("emote/%s/%s", emoteID.Hex(), file[1]), blob, &mime); err != nil {
+			return nil, err
+		}
+	}
+
+	return sizes, nil
+}
@@ -3,6 +3,7 @@ package actions
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
@@ -55,6 +56,14 @@ func (b EntitlementBuilder) SetKind(kind datastructure.EntitlementKind) Entitlem
 	return b
 }
 
+// SetPeriod: Change when the entitlement starts and ends. Either may be nil
+func (b EntitlementBuilder) SetPeriod(startsAt *time.Time, endsAt *time.Time) EntitlementBuilder {
+	b.Entitlement.StartsAt = startsAt
+	b.Entitlement.EndsAt = endsAt
+
+	return b
+}
+
 // SetUserID: Change the entitlement's assigned user
 func (b EntitlementBuilder) SetUserID(id primitive.ObjectID) EntitlementBuilder {
 	b.Entitlement.UserID = id
@@ -82,6 +91,11 @@ func (b EntitlementBuilder) SetEmoteSetData(data datastructure.EntitledEmoteSet)
 	return b.marshalData(data)
 }
 
+// SetPaintData: Add a paint reference to the entitlement
+func (b EntitlementBuilder) SetPaintData(data datastructure.EntitledPaint) EntitlementBuilder {
+	return b.marshalData(data)
+}
+
 func (b EntitlementBuilder) marshalData(data interface{}) EntitlementBuilder {
 	d, err := bson.Marshal(data)
 	if err != nil {
@@ -133,6 +147,16 @@ func (b EntitlementBuilder) ReadEmoteSetData() datastructure.EntitledEmoteSet {
 	return e
 }
 
+// ReadPaintData: Read the data as an Entitled Paint
+func (b EntitlementBuilder) ReadPaintData() datastructure.EntitledPaint {
+	var e datastructure.EntitledPaint
+	if err := bson.Unmarshal(b.Entitlement.Data, &e); err != nil {
+		log.WithError(err).Error("bson")
+		return e
+	}
+	return e
+}
+
 // Create: Get a new entitlement builder
 func (entitlements) Create(ctx context.Context) EntitlementBuilder {
 	return EntitlementBuilder{
@@ -147,6 +171,34 @@ func (entitlements) With(ctx context.Context, e datastructure.Entitlement) Entit
 	}
 }
 
+// Invalidate: Rebuild the cosmetic map derived from an entitlement, after it was granted, revoked, started or expired.
+// This is the only cache holding entitlement data: the redis query cache never reads the entitlements collection,
+// and the roles and emote sets granted are read from the entitlements whenever a user is loaded
+func (entitlements) Invalidate(ctx context.Context, e *datastructure.Entitlement) {
+	if m := cosmeticMapByKind(e.Kind); m != nil {
+		m.MarkDirty(ctx)
+	}
+}
+
+// ActiveEntitlementFilter: A query matching entitlements which are currently in effect
+func ActiveEntitlementFilter() bson.M {
+	now := time.Now()
+	return bson.M{
+		"disabled": bson.M{"$ne": true},
+		"expired":  bson.M{"$ne": true},
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"starts_at": nil},
+				bson.M{"starts_at": bson.M{"$lte": now}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"ends_at": nil},
+				bson.M{"ends_at": bson.M{"$gt": now}},
+			}},
+		},
+	}
+}
+
 func (b EntitlementBuilder) Log(str string) {
 	log.WithFields(log.Fields{
 		"id":      b.Entitlement.ID,
@@ -162,3 +214,164 @@ func (b EntitlementBuilder) LogError(str string) {
 		"user_id": b.Entitlement.UserID,
 	}).Error(str)
 }
+
+// Grant: Give an entitlement to each of a list of users, recording an audit entry for every one
+//
+// Users who already hold the same item for an overlapping period are skipped. Returns the entitlements created
+func (x entitlements) Grant(ctx context.Context, opts GrantEntitlementOptions) ([]*datastructure.Entitlement, error) {
+	var ref *primitive.ObjectID
+	if v, err := opts.Entitlement.Data.LookupErr("ref"); err == nil {
+		if id, ok := v.ObjectIDOK(); ok {
+			ref = &id
+		}
+	}
+
+	granted := []*datastructure.Entitlement{}
+	for _, userID := range opts.UserIDs {
+		e := opts.Entitlement
+		e.ID = primitive.NewObjectID()
+		e.UserID = userID
+		e.Pending = e.StartsAt != nil && e.StartsAt.After(time.Now())
+
+		if _, err := Audit.Mutate(ctx, AuditedMutation{
+			Actor:  opts.Actor,
+			Type:   datastructure.AuditLogTypeUserEntitlementGrant,
+			Target: &datastructure.Target{ID: &e.ID, Type: string(mongo.CollectionNameEntitlements)},
+			Reason: opts.Reason,
+			After:  &e,
+			Apply: func(ctx context.Context) error {
+				filter := overlappingEntitlementFilter(&e)
+				if ref != nil {
+					filter["data.ref"] = *ref
+				}
+				if n, err := mongo.Collection(mongo.CollectionNameEntitlements).CountDocuments(ctx, filter); err != nil {
+					return err
+				} else if n > 0 {
+					return errAlreadyEntitled
+				}
+
+				_, err := mongo.Collection(mongo.CollectionNameEntitlements).InsertOne(ctx, &e)
+				return err
+			},
+		}); err == errAlreadyEntitled {
+			continue
+		} else if err != nil {
+			return granted, err
+		}
+
+		x.Invalidate(ctx, &e)
+		granted = append(granted, &e)
+		if !e.Pending { // Otherwise the user is told once it starts
+			go NotifyEntitlementGranted(&e, opts.Actor)
+		}
+	}
+
+	return granted, nil
+}
+
+// Revoke: Delete entitlements, recording an audit entry for every one
+//
+// Returns the entitlements deleted
+func (x entitlements) Revoke(ctx context.Context, opts RevokeEntitlementOptions) ([]*datastructure.Entitlement, error) {
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, opts.Filter)
+	if err == nil {
+		err = cur.All(ctx, &ents)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := []*datastructure.Entitlement{}
+	for _, ent := range ents {
+		id := ent.ID
+		e := &datastructure.Entitlement{}
+		if _, err := Audit.Mutate(ctx, AuditedMutation{
+			Actor:  opts.Actor,
+			Type:   datastructure.AuditLogTypeUserEntitlementRevoke,
+			Target: &datastructure.Target{ID: &id, Type: string(mongo.CollectionNameEntitlements)},
+			Reason: opts.Reason,
+			Before: e,
+			Apply: func(ctx context.Context) error {
+				return mongo.Collection(mongo.CollectionNameEntitlements).FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(e)
+			},
+		}); err == mongo.ErrNoDocuments { // Revoked concurrently
+			continue
+		} else if err != nil {
+			return revoked, err
+		}
+
+		x.Invalidate(ctx, e)
+		revoked = append(revoked, e)
+	}
+
+	return revoked, nil
+}
+
+var errAlreadyEntitled = fmt.Errorf("already entitled")
+
+// A query matching the user's entitlements of the same kind which are in effect at some point of the entitlement's period
+func overlappingEntitlementFilter(e *datastructure.Entitlement) bson.M {
+	start := time.Now()
+	if e.StartsAt != nil && e.StartsAt.After(start) {
+		start = *e.StartsAt
+	}
+
+	and := bson.A{bson.M{"$or": bson.A{
+		bson.M{"ends_at": nil},
+		bson.M{"ends_at": bson.M{"$gt": start}},
+	}}}
+	if e.EndsAt != nil {
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"starts_at": nil},
+			bson.M{"starts_at": bson.M{"$lt": *e.EndsAt}},
+		}})
+	}
+
+	return bson.M{
+		"user_id":  e.UserID,
+		"kind":     e.Kind,
+		"disabled": bson.M{"$ne": true},
+		"expired":  bson.M{"$ne": true},
+		"$and":     and,
+	}
+}
+
+// NotifyEntitlementGranted: Let a user know about an entitlement they were granted, by an actor if known
+func NotifyEntitlementGranted(e *datastructure.Entitlement, actor *datastructure.User) {
+	if e.Kind != datastructure.EntitlementKindRole {
+		return
+	}
+
+	roleID := Entitlements.With(context.Background(), *e).ReadRoleData().ObjectReference
+	role := datastructure.GetRole(&roleID)
+	notify := Notifications.Create().
+		AddTargetUsers(e.UserID).
+		SetTitle("Global Role Granted").
+		AddTextMessagePart("You've been granted the role").
+		AddRoleMentionPart(role.ID)
+	if actor != nil {
+		notify = notify.AddTextMessagePart("by").AddUserMentionPart(actor.ID)
+	}
+	if e.EndsAt != nil {
+		notify = notify.AddTextMessagePart(fmt.Sprintf("until %v", e.EndsAt.Format("Mon, 02 Jan 2006 15:04:05 MST")))
+	}
+	notify = notify.AddTextMessagePart(".")
+
+	if err := notify.Write(context.Background()); err != nil {
+		log.WithError(err).Error("notifications")
+	}
+}
+
+type GrantEntitlementOptions struct {
+	Actor       *datastructure.User
+	Entitlement datastructure.Entitlement // The kind, data and period granted
+	UserIDs     []primitive.ObjectID
+	Reason      *string
+}
+
+type RevokeEntitlementOptions struct {
+	Actor  *datastructure.User
+	Filter bson.M // The entitlements to revoke
+	Reason *string
+}
@@ -0,0 +1,247 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How long the rules are kept in memory before being fetched again
+const filterRulesTTL = 30 * time.Second
+
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "2", "z", "3", "e", "4", "a", "5", "s",
+	"6", "g", "7", "t", "8", "b", "9", "g", "@", "a", "$", "s",
+	"!", "i", "|", "l", "+", "t",
+)
+
+type compiledFilterRule struct {
+	*datastructure.FilterRule
+	pattern string // The pattern of word and substring rules, normalized as the content it is matched against
+	regex   *regexp.Regexp
+}
+
+type compiledFilterRules struct {
+	rules   []*compiledFilterRule
+	allowed *regexp.Regexp // Matches any allowlisted term, or nil if there are none
+}
+
+// Check: Test content against the enabled filter rules
+//
+// Returns the match with the most severe action, or nil if the content passed
+func (f *filter) Check(ctx context.Context, values ...string) (*FilterMatch, error) {
+	f.mx.Lock()
+	if f.compiled == nil || time.Since(f.fetchedAt) > filterRulesTTL {
+		rules := []*datastructure.FilterRule{}
+		cur, err := mongo.Collection(mongo.CollectionNameFilterRules).Find(ctx, bson.M{"enabled": true})
+		if err == nil {
+			err = cur.All(ctx, &rules)
+		}
+		if err != nil {
+			f.mx.Unlock()
+			return nil, err
+		}
+
+		f.compiled = CompileFilterRules(rules)
+		f.fetchedAt = time.Now()
+	}
+	compiled := f.compiled
+	f.mx.Unlock()
+
+	return compiled.Match(values...), nil
+}
+
+// The redis channel on which pods are told to drop their cached rules
+const FilterInvalidateChannel = "filter-rules:invalidate"
+
+// Invalidate: Drop the cached rules of every pod so that changes apply immediately
+func (f *filter) Invalidate(ctx context.Context) {
+	f.Drop()
+	if err := redis.Publish(ctx, FilterInvalidateChannel, time.Now().Unix()); err != nil {
+		log.WithError(err).Error("redis")
+	}
+}
+
+// Drop: Drop this pod's cached rules
+func (f *filter) Drop() {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	f.compiled = nil
+}
+
+// Flag: File a report on behalf of the system so moderators look at content which matched a review rule
+func (*filter) Flag(ctx context.Context, target *datastructure.Target, match *FilterMatch) error {
+	_, err := mongo.Collection(mongo.CollectionNameReports).InsertOne(ctx, &datastructure.Report{
+		ID:         primitive.NewObjectID(),
+		ReporterID: &datastructure.SystemUser.ID,
+		Reason:     fmt.Sprintf("Content filter: \"%v\" matched %v rule \"%v\"", match.Value, strings.ToLower(match.Rule.Kind), match.Rule.Pattern),
+		Target:     target,
+		Status:     datastructure.ReportStatusOpen,
+	})
+	return err
+}
+
+// ValidateFilterRule: Check that a rule is well-formed
+func ValidateFilterRule(rule *datastructure.FilterRule) bool {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return false
+	}
+
+	switch rule.Kind {
+	case datastructure.FilterRuleKindWord, datastructure.FilterRuleKindAllow:
+	case datastructure.FilterRuleKindSubstring:
+		// A pattern of separators only would match any content
+		if collapseFilterText(normalizeFilterText(rule.Pattern, rule.Normalize)) == "" {
+			return false
+		}
+	case datastructure.FilterRuleKindRegex:
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return rule.Kind == datastructure.FilterRuleKindAllow ||
+		rule.Action == datastructure.FilterActionReview || rule.Action == datastructure.FilterActionReject
+}
+
+// CompileFilterRules: Prepare rules for matching. Invalid rules are skipped
+func CompileFilterRules(rules []*datastructure.FilterRule) *compiledFilterRules {
+	result := &compiledFilterRules{}
+	allowed := []string{}
+
+	for _, r := range rules {
+		if !ValidateFilterRule(r) {
+			continue
+		}
+
+		c := &compiledFilterRule{FilterRule: r}
+		switch r.Kind {
+		case datastructure.FilterRuleKindAllow:
+			allowed = append(allowed, regexp.QuoteMeta(strings.ToLower(r.Pattern)))
+			continue
+		case datastructure.FilterRuleKindRegex:
+			c.regex = regexp.MustCompile(r.Pattern)
+		case datastructure.FilterRuleKindSubstring:
+			c.pattern = collapseFilterText(normalizeFilterText(r.Pattern, r.Normalize))
+		default:
+			c.pattern = normalizeFilterText(r.Pattern, r.Normalize)
+		}
+		result.rules = append(result.rules, c)
+	}
+
+	if len(allowed) > 0 {
+		result.allowed = regexp.MustCompile("(?i)" + strings.Join(allowed, "|"))
+	}
+	return result
+}
+
+// Match: Test content against compiled rules
+func (c *compiledFilterRules) Match(values ...string) *FilterMatch {
+	var match *FilterMatch
+	for _, value := range values {
+		// Allowlisted terms are cut out before anything else is looked at
+		v := value
+		if c.allowed != nil {
+			v = c.allowed.ReplaceAllString(v, " ")
+		}
+
+		raw := normalizeFilterText(v, false)
+		leet := normalizeFilterText(v, true)
+
+		for _, r := range c.rules {
+			if match != nil && match.Rule.Action >= r.Action {
+				continue
+			}
+
+			text := utils.Ternary(r.Normalize, leet, raw).(string)
+			matched := false
+			switch r.Kind {
+			case datastructure.FilterRuleKindWord:
+				for _, w := range splitFilterWords(v, r.Normalize) {
+					if w == r.pattern {
+						matched = true
+						break
+					}
+				}
+			case datastructure.FilterRuleKindSubstring:
+				matched = strings.Contains(collapseFilterText(text), r.pattern)
+			case datastructure.FilterRuleKindRegex:
+				matched = r.regex.MatchString(utils.Ternary(r.Normalize, leet, v).(string))
+			}
+
+			if matched {
+				match = &FilterMatch{Rule: r.FilterRule, Value: value}
+			}
+		}
+	}
+
+	return match
+}
+
+// Split content into lowercase words at separators and at lower to upper case changes
+func splitFilterWords(s string, normalize bool) []string {
+	words := []string{}
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		words = append(words, normalizeFilterText(word.String(), normalize))
+		word.Reset()
+	}
+
+	var prev rune
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r) || strings.ContainsRune("-_:()", r):
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			word.WriteRune(r)
+		default:
+			word.WriteRune(r)
+		}
+		prev = r
+	}
+	flush()
+
+	return words
+}
+
+// Lowercase content, undoing leetspeak if set to normalize
+func normalizeFilterText(s string, normalize bool) string {
+	s = strings.ToLower(s)
+	if normalize {
+		s = leetReplacer.Replace(s)
+	}
+	return s
+}
+
+// Strip everything but letters and digits
+func collapseFilterText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+type FilterMatch struct {
+	Rule  *datastructure.FilterRule
+	Value string // The content which matched
+}
@@ -0,0 +1,255 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrLockedDown                 = fmt.Errorf("user is locked down")
+	ErrNotLockedDown              = fmt.Errorf("user is not locked down")
+	ErrLockdownNotReauthenticated = fmt.Errorf("the owner has not signed in again since the lockdown")
+)
+
+// The actions frozen by a lockdown's ban
+const LockdownBanScope = datastructure.BanScopeUpload | datastructure.BanScopeChannelEdit
+
+// Create: Lock down a compromised account
+//
+// Edits to any channel, including as an editor elsewhere, and uploads are frozen by a scoped ban,
+// then every session is invalidated. The account's owner and the owners of the channels it edits are notified
+func (lockdowns) Create(ctx context.Context, opts CreateLockdownOptions) (*datastructure.UserLockdown, error) {
+	user := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": opts.UserID}).Decode(user); err != nil {
+		return nil, err
+	}
+	if user.Lockdown != nil {
+		return nil, ErrLockedDown
+	}
+
+	// Freeze the account first, so that nothing more is changed while its sessions are invalidated
+	ban, err := Bans.Create(ctx, CreateBanOptions{
+		Actor:    opts.Actor,
+		VictimID: user.ID,
+		Reason:   "Account lockdown: " + opts.Reason,
+		Scope:    LockdownBanScope,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lockdown := &datastructure.UserLockdown{
+		IssuedByID: opts.Actor.ID,
+		Reason:     opts.Reason,
+		BanID:      ban.ID,
+		CreatedAt:  time.Now(),
+	}
+	before := &datastructure.User{}
+	after := &datastructure.User{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  opts.Actor,
+		Type:   datastructure.AuditLogTypeUserLockdown,
+		Target: &datastructure.Target{ID: &user.ID, Type: "users"},
+		Reason: &opts.Reason,
+		Before: before,
+		After:  after,
+		Apply: func(ctx context.Context) error {
+			if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": user.ID}).Decode(before); err != nil {
+				return err
+			}
+			if before.Lockdown != nil {
+				return ErrLockedDown
+			}
+
+			// Tokens carry the version they were signed with, so bumping it signs the account out everywhere
+			return Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id":      user.ID,
+				"lockdown": nil,
+			}, bson.M{
+				"$set": bson.M{
+					"token_version": nextTokenVersion(before.TokenVersion),
+					"lockdown":      lockdown,
+				},
+			}, nil, after)
+		},
+	}); err != nil {
+		// Don't leave the account frozen without a lockdown to lift
+		if _, liftErr := Bans.Lift(ctx, LiftBanOptions{
+			Actor:  opts.Actor,
+			UserID: user.ID,
+			BanIDs: []primitive.ObjectID{ban.ID},
+			Reason: "Account lockdown failed",
+			Silent: true,
+		}); liftErr != nil {
+			log.WithError(liftErr).WithField("ban_id", ban.ID).Error("mongo, could not lift the ban of a failed lockdown")
+		}
+		return nil, err
+	}
+
+	go notifyLockdown(user, lockdown)
+	return lockdown, nil
+}
+
+// Reauthenticate: Record that the owner of a locked down account signed in again through Twitch,
+// which allows the lockdown to be lifted
+func (lockdowns) Reauthenticate(ctx context.Context, user *datastructure.User) error {
+	if user.Lockdown == nil || user.Lockdown.ReauthenticatedAt != nil {
+		return nil
+	}
+
+	before := &datastructure.User{}
+	after := &datastructure.User{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  user,
+		Type:   datastructure.AuditLogTypeUserLockdownReauth,
+		Target: &datastructure.Target{ID: &user.ID, Type: "users"},
+		Before: before,
+		After:  after,
+		Apply: func(ctx context.Context) error {
+			return Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id":                         user.ID,
+				"lockdown.ban_id":             user.Lockdown.BanID,
+				"lockdown.reauthenticated_at": nil,
+			}, bson.M{
+				"$set": bson.M{"lockdown.reauthenticated_at": time.Now()},
+			}, before, after)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments { // Reauthenticated concurrently, or lifted
+			return nil
+		}
+		return err
+	}
+	user.Lockdown = after.Lockdown
+	issuedByID := after.Lockdown.IssuedByID
+
+	go func() {
+		if err := Notifications.Create().
+			SetTitle("Locked Down Account Signed In").
+			AddTargetUsers(issuedByID).
+			AddUserMentionPart(user.ID).
+			AddTextMessagePart(" signed in again through Twitch. Their lockdown can now be lifted.").
+			Write(context.Background()); err != nil {
+			log.WithError(err).Error("failed to create notification")
+		}
+	}()
+	return nil
+}
+
+// Lift: End the lockdown of an account whose owner has signed in again, and lift the ban which enforced it
+func (lockdowns) Lift(ctx context.Context, opts LiftLockdownOptions) error {
+	user := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": opts.UserID}).Decode(user); err != nil {
+		return err
+	}
+	if user.Lockdown == nil {
+		return ErrNotLockedDown
+	}
+	if user.Lockdown.ReauthenticatedAt == nil {
+		return ErrLockdownNotReauthenticated
+	}
+
+	lockdown := user.Lockdown
+	before := &datastructure.User{}
+	after := &datastructure.User{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  opts.Actor,
+		Type:   datastructure.AuditLogTypeUserLockdownLift,
+		Target: &datastructure.Target{ID: &user.ID, Type: "users"},
+		Reason: &opts.Reason,
+		Before: before,
+		After:  after,
+		Apply: func(ctx context.Context) error {
+			return Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id":             user.ID,
+				"lockdown.ban_id": lockdown.BanID,
+			}, bson.M{
+				"$unset": bson.M{"lockdown": ""},
+			}, before, after)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments { // Lifted concurrently
+			return ErrNotLockedDown
+		}
+		return err
+	}
+
+	_, err := Bans.Lift(ctx, LiftBanOptions{
+		Actor:  opts.Actor,
+		UserID: user.ID,
+		BanIDs: []primitive.ObjectID{lockdown.BanID},
+		Reason: "Account lockdown lifted: " + opts.Reason,
+	})
+	return err
+}
+
+// Let the owner of a locked down account, and the owners of the channels it edits, know about the lockdown
+func notifyLockdown(user *datastructure.User, lockdown *datastructure.UserLockdown) {
+	ctx := context.Background()
+
+	if err := Notifications.Create().
+		SetTitle("Your Account Was Locked Down").
+		AddTargetUsers(user.ID).
+		AddTextMessagePart(fmt.Sprintf(
+			"Your account appears to be compromised and was locked down: \"%v\". You were signed out everywhere and your edits and uploads are frozen. Sign in again with Twitch to confirm you own this account, then a moderator will lift the lockdown.",
+			lockdown.Reason,
+		)).
+		Write(ctx); err != nil {
+		log.WithError(err).Error("failed to create notification")
+	}
+
+	channels := []*datastructure.User{}
+	cur, err := mongo.Collection(mongo.CollectionNameUsers).Find(ctx, bson.M{
+		"editors": user.ID,
+	}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err == nil {
+		err = cur.All(ctx, &channels)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	ids := make([]primitive.ObjectID, len(channels))
+	for i, c := range channels {
+		ids[i] = c.ID
+	}
+	if err := Notifications.Create().
+		SetTitle("Channel Editor Locked Down").
+		AddTargetUsers(ids...).
+		AddUserMentionPart(user.ID).
+		AddTextMessagePart(" was locked down as a compromised account and can no longer edit your channel. Recent changes made by this editor may not have been theirs, a moderator can revert them if needed.").
+		Write(ctx); err != nil {
+		log.WithError(err).Error("failed to create notification")
+	}
+}
+
+// The token version following v. Versions which aren't numbers start over from 1
+func nextTokenVersion(v string) string {
+	n, _ := strconv.Atoi(v)
+	return strconv.Itoa(n + 1)
+}
+
+type CreateLockdownOptions struct {
+	Actor  *datastructure.User
+	UserID primitive.ObjectID
+	Reason string
+}
+
+type LiftLockdownOptions struct {
+	Actor  *datastructure.User
+	UserID primitive.ObjectID
+	Reason string
+}
@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MAX_PAINT_STOPS   = 16
+	MAX_PAINT_SHADOWS = 4
+)
+
+// ValidatePaint: Check that a paint is well-formed for its function
+func ValidatePaint(p *datastructure.Paint) bool {
+	if strings.TrimSpace(p.Name) == "" || len(p.Shadows) > MAX_PAINT_SHADOWS {
+		return false
+	}
+	for _, s := range p.Shadows {
+		if s.Radius < 0 {
+			return false
+		}
+	}
+
+	switch p.Function {
+	case datastructure.PaintFunctionSolid:
+		return p.Color != nil
+	case datastructure.PaintFunctionLinearGradient:
+		if len(p.Stops) < 2 || len(p.Stops) > MAX_PAINT_STOPS {
+			return false
+		}
+		for i, s := range p.Stops {
+			if s.At < 0 || s.At > 1 || (i > 0 && s.At < p.Stops[i-1].At) {
+				return false
+			}
+		}
+		return true
+	case datastructure.PaintFunctionImage:
+		u, err := url.Parse(p.ImageURL)
+		return err == nil && u.Scheme == "https" && u.Host != ""
+	}
+	return false
+}
+
+// Holders: Get the users displaying each paint
+func (paints) Holders(ctx context.Context) (map[primitive.ObjectID][]primitive.ObjectID, error) {
+	return cosmeticHolders(ctx, datastructure.EntitlementKindPaint)
+}
+
+// Displayed: Get the paint a user displays, if any
+func (paints) Displayed(ctx context.Context, userID primitive.ObjectID) (*primitive.ObjectID, error) {
+	return displayedCosmetic(ctx, datastructure.EntitlementKindPaint, userID)
+}
+
+// Select: Choose which of a user's entitled paints they display
+func (paints) Select(ctx context.Context, user *datastructure.User, paintID primitive.ObjectID) error {
+	if err := checkEntitledCosmetic(ctx, datastructure.EntitlementKindPaint, user.ID, paintID); err != nil {
+		return err
+	}
+
+	before := bson.M{}
+	if _, err := Audit.Mutate(ctx, AuditedMutation{
+		Actor:  user,
+		Type:   datastructure.AuditLogTypeUserPaintSelect,
+		Target: &datastructure.Target{ID: &user.ID, Type: "users"},
+		Before: before,
+		After:  bson.M{"paint": paintID},
+		Apply: func(ctx context.Context) error {
+			current, err := Paints.Displayed(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+			before["paint"] = current
+
+			return selectEntitledCosmetic(ctx, datastructure.EntitlementKindPaint, user.ID, paintID)
+		},
+	}); err != nil {
+		return err
+	}
+
+	Paints.Invalidate(ctx, paintID)
+	return nil
+}
+
+// Invalidate: Drop cached data about a paint, after it or its holders changed
+func (paints) Invalidate(ctx context.Context, paintID primitive.ObjectID) {
+	if _, err := redis.InvalidateCache(ctx, "", string(mongo.CollectionNamePaints), paintID.Hex(), "", ""); err != nil {
+		log.WithError(err).Error("redis")
+	}
+	PaintMap.MarkDirty(ctx)
+}
@@ -0,0 +1,146 @@
+package actions
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// A personal emote set, granted to a user through an EMOTE_SET entitlement and usable in every channel
+type PersonalEmoteSet struct {
+	ID         primitive.ObjectID // The ID of the entitlement
+	UserID     primitive.ObjectID
+	UnicodeTag string
+	Emotes     []*datastructure.Emote
+}
+
+// PersonalSets: Get the active personal emote sets of users, by user ID
+//
+// Banned users have no personal emotes. Emotes which are not live, or are zero-width
+// while the user may not use those, are left out before the set's slot limit applies
+func (emotes) PersonalSets(ctx context.Context, users []*datastructure.User) (map[primitive.ObjectID][]*PersonalEmoteSet, error) {
+	result := map[primitive.ObjectID][]*PersonalEmoteSet{}
+	if len(users) == 0 {
+		return result, nil
+	}
+
+	// Omit banned users
+	userIDs := make([]string, len(users))
+	for i, u := range users {
+		userIDs[i] = u.ID.Hex()
+	}
+	banned, err := redis.Client.HMGet(ctx, "user:bans", userIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	userMap := make(map[primitive.ObjectID]*datastructure.User, len(users))
+	ids := []primitive.ObjectID{}
+	for i, u := range users {
+		if banned[i] != nil {
+			continue
+		}
+		userMap[u.ID] = u
+		ids = append(ids, u.ID)
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	// Find the users' active emote set entitlements
+	filter := ActiveEntitlementFilter()
+	filter["kind"] = datastructure.EntitlementKindEmoteSet
+	filter["user_id"] = bson.M{"$in": ids}
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err == nil {
+		err = cur.All(ctx, &ents)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]datastructure.EntitledEmoteSet, len(ents))
+	emoteIDs := []primitive.ObjectID{}
+	for i, e := range ents {
+		sets[i] = Entitlements.With(ctx, *e).ReadEmoteSetData()
+		emoteIDs = append(emoteIDs, sets[i].EmoteIDs...)
+	}
+
+	// Fetch the emotes of every set at once
+	found := []*datastructure.Emote{}
+	if len(emoteIDs) > 0 {
+		cur, err = mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{
+			"_id":    bson.M{"$in": emoteIDs},
+			"status": datastructure.EmoteStatusLive,
+		})
+		if err == nil {
+			err = cur.All(ctx, &found)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	emoteMap := make(map[primitive.ObjectID]*datastructure.Emote, len(found))
+	for _, e := range found {
+		emoteMap[e.ID] = e
+	}
+
+	// Resolve who may use zero-width emotes from every role held, directly or through an entitlement
+	zeroWidthOK := make(map[primitive.ObjectID]bool, len(userMap))
+	for id, u := range userMap {
+		zeroWidthOK[id] = u.HasPermission(datastructure.RolePermissionUseZeroWidthEmote)
+	}
+	roleFilter := ActiveEntitlementFilter()
+	roleFilter["kind"] = datastructure.EntitlementKindRole
+	roleFilter["user_id"] = bson.M{"$in": ids}
+	roleEnts := []*datastructure.Entitlement{}
+	cur, err = mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, roleFilter)
+	if err == nil {
+		err = cur.All(ctx, &roleEnts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range roleEnts {
+		roleID := Entitlements.With(ctx, *e).ReadRoleData().ObjectReference
+		role := datastructure.GetRole(&roleID)
+		if role.ID != roleID {
+			continue
+		}
+		holder := datastructure.User{Role: &role}
+		zeroWidthOK[e.UserID] = zeroWidthOK[e.UserID] || holder.HasPermission(datastructure.RolePermissionUseZeroWidthEmote)
+	}
+
+	for i, e := range ents {
+		set := &PersonalEmoteSet{
+			ID:         e.ID,
+			UserID:     e.UserID,
+			UnicodeTag: sets[i].UnicodeTag,
+			Emotes:     []*datastructure.Emote{},
+		}
+		slots := int(sets[i].GetSlots())
+		for _, id := range sets[i].EmoteIDs {
+			if len(set.Emotes) >= slots {
+				break
+			}
+
+			emote, ok := emoteMap[id]
+			if !ok {
+				continue
+			}
+			if !zeroWidthOK[e.UserID] && utils.BitField.HasBits(int64(emote.Visibility), int64(datastructure.EmoteVisibilityZeroWidth)) {
+				continue // Skip if the emote is zero-width and user lacks permission
+			}
+			set.Emotes = append(set.Emotes, emote)
+		}
+
+		result[e.UserID] = append(result[e.UserID], set)
+	}
+	return result, nil
+}
@@ -0,0 +1,386 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/billing"
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type subscriptions struct{}
+
+var Subscriptions subscriptions = subscriptions{}
+
+var (
+	ErrUnknownPlan         = fmt.Errorf("unknown subscription plan")
+	ErrUnknownSubscription = fmt.Errorf("unknown subscription")
+	ErrUnknownSubscriber   = fmt.Errorf("unknown subscriber")
+)
+
+// Get how late a renewal may be before the subscription's perks end, which is also how long a past due subscription lasts
+func SubscriptionGracePeriod() time.Duration {
+	return configure.Config.GetDuration("billing.grace_period")
+}
+
+// Apply: Update a subscription from an event of its payment provider, and sync the entitlements it grants
+//
+// A subscription is created by its activation. Events older than the last one applied are ignored,
+// as providers may deliver them out of order
+func (x subscriptions) Apply(ctx context.Context, provider string, ev *billing.Event) (*datastructure.Subscription, error) {
+	at := ev.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if (ev.Type == billing.EventActivated || ev.Type == billing.EventRenewed) && !ev.PeriodEnd.After(ev.PeriodStart) {
+		return nil, billing.ErrMalformedWebhook
+	}
+
+	var sub *datastructure.Subscription
+	err := x.withLock(ctx, provider, ev.SubscriptionID, func() error {
+		target := &datastructure.Target{Type: "users"}
+		before := bson.M{}
+		after := bson.M{}
+		if _, err := Audit.Mutate(ctx, AuditedMutation{
+			Actor:  datastructure.SystemUser,
+			Type:   datastructure.AuditLogTypeUserSubscription,
+			Target: target,
+			Reason: utils.StringPointer(fmt.Sprintf("%v %v", provider, ev.Type)),
+			Before: before,
+			After:  after,
+			Apply: func(ctx context.Context) error {
+				sub = nil
+				delete(before, "subscription")
+				delete(after, "subscription")
+				old, updated, err := x.applyEvent(ctx, provider, ev, at)
+				if err != nil || updated == nil {
+					return err
+				}
+
+				if old != nil {
+					before["subscription"] = old
+				}
+				after["subscription"] = updated
+				target.ID = &updated.UserID
+				sub = updated
+				return nil
+			},
+		}); err != nil || sub == nil {
+			return err
+		}
+
+		return x.SyncEntitlements(ctx, sub)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Apply an event onto the subscription it is about, in a transaction
+//
+// Returns the subscription before and after the event. It is nil before its activation,
+// and nil after when the event is older than the last one applied
+func (subscriptions) applyEvent(ctx context.Context, provider string, ev *billing.Event, at time.Time) (*datastructure.Subscription, *datastructure.Subscription, error) {
+	sub := &datastructure.Subscription{}
+	var old *datastructure.Subscription
+	err := mongo.Collection(mongo.CollectionNameSubscriptions).FindOne(ctx, bson.M{
+		"provider":    provider,
+		"provider_id": ev.SubscriptionID,
+	}).Decode(sub)
+	if err == mongo.ErrNoDocuments {
+		if ev.Type != billing.EventActivated {
+			return nil, nil, ErrUnknownSubscription
+		}
+
+		userID, err := primitive.ObjectIDFromHex(ev.UserID)
+		if err != nil {
+			return nil, nil, ErrUnknownSubscriber
+		}
+		if n, err := mongo.Collection(mongo.CollectionNameUsers).CountDocuments(ctx, bson.M{"_id": userID}); err != nil {
+			return nil, nil, err
+		} else if n == 0 {
+			return nil, nil, ErrUnknownSubscriber
+		}
+
+		sub = &datastructure.Subscription{
+			ID:         primitive.NewObjectID(),
+			UserID:     userID,
+			Provider:   provider,
+			ProviderID: ev.SubscriptionID,
+			StartedAt:  at,
+		}
+	} else if err != nil {
+		return nil, nil, err
+	} else if at.Before(sub.UpdatedAt) {
+		return sub, nil, nil
+	} else {
+		s := *sub
+		old = &s
+	}
+
+	// The plan may change with any event naming one, such as an upgrade on renewal
+	if ev.PlanID != "" {
+		plan := &datastructure.SubscriptionPlan{}
+		if err := mongo.Collection(mongo.CollectionNamePlans).FindOne(ctx, bson.M{
+			"provider_plans." + provider: ev.PlanID,
+		}).Decode(plan); err == mongo.ErrNoDocuments {
+			return nil, nil, ErrUnknownPlan
+		} else if err != nil {
+			return nil, nil, err
+		}
+		sub.PlanID = plan.ID
+	}
+	if sub.PlanID.IsZero() {
+		return nil, nil, ErrUnknownPlan
+	}
+
+	switch ev.Type {
+	case billing.EventActivated, billing.EventRenewed:
+		sub.Status = datastructure.SubscriptionStatusActive
+		sub.PeriodStart = ev.PeriodStart
+		sub.PeriodEnd = ev.PeriodEnd
+		sub.GraceEndsAt = nil
+		sub.CanceledAt = nil
+		sub.EndedAt = nil
+	case billing.EventPaymentFailed:
+		if sub.Status == datastructure.SubscriptionStatusActive || sub.Status == datastructure.SubscriptionStatusCanceled {
+			graceEndsAt := sub.PeriodEnd.Add(SubscriptionGracePeriod())
+			sub.Status = datastructure.SubscriptionStatusPastDue
+			sub.GraceEndsAt = &graceEndsAt
+		}
+	case billing.EventCanceled:
+		if sub.Status != datastructure.SubscriptionStatusEnded {
+			sub.Status = datastructure.SubscriptionStatusCanceled
+			sub.CanceledAt = &at
+		}
+	case billing.EventResumed:
+		if sub.Status == datastructure.SubscriptionStatusCanceled {
+			sub.Status = datastructure.SubscriptionStatusActive
+			sub.CanceledAt = nil
+		}
+	case billing.EventEnded:
+		sub.Status = datastructure.SubscriptionStatusEnded
+		sub.EndedAt = &at
+	default:
+		return nil, nil, billing.ErrMalformedWebhook
+	}
+	sub.UpdatedAt = at
+
+	if _, err := mongo.Collection(mongo.CollectionNameSubscriptions).ReplaceOne(ctx, bson.M{"_id": sub.ID}, sub, options.Replace().SetUpsert(true)); err != nil {
+		return nil, nil, err
+	}
+	return old, sub, nil
+}
+
+// EndLapsed: End a subscription whose perks ran out without a renewal
+//
+// Returns whether the subscription was ended
+func (x subscriptions) EndLapsed(ctx context.Context, sub *datastructure.Subscription) (bool, error) {
+	ended := false
+	err := x.withLock(ctx, sub.Provider, sub.ProviderID, func() error {
+		before := &datastructure.Subscription{}
+		if _, err := Audit.Mutate(ctx, AuditedMutation{
+			Actor:  datastructure.SystemUser,
+			Type:   datastructure.AuditLogTypeUserSubscription,
+			Target: &datastructure.Target{ID: &sub.UserID, Type: "users"},
+			Reason: utils.StringPointer("Lapsed without a renewal"),
+			Before: bson.M{"subscription": before},
+			After:  bson.M{"subscription": sub},
+			Apply: func(ctx context.Context) error {
+				if err := mongo.Collection(mongo.CollectionNameSubscriptions).FindOne(ctx, bson.M{"_id": sub.ID}).Decode(sub); err != nil {
+					return err
+				}
+				*before = *sub
+
+				until := sub.EntitledUntil(SubscriptionGracePeriod())
+				if sub.Status == datastructure.SubscriptionStatusEnded || until.After(time.Now()) {
+					ended = false
+					return nil
+				}
+
+				sub.Status = datastructure.SubscriptionStatusEnded
+				sub.EndedAt = &until
+				ended = true
+				_, err := mongo.Collection(mongo.CollectionNameSubscriptions).UpdateOne(ctx, bson.M{"_id": sub.ID}, bson.M{
+					"$set": bson.M{
+						"status":   sub.Status,
+						"ended_at": sub.EndedAt,
+					},
+				})
+				return err
+			},
+		}); err != nil || !ended {
+			return err
+		}
+
+		return x.SyncEntitlements(ctx, sub)
+	})
+
+	return ended, err
+}
+
+// SyncEntitlements: Grant the perks of a subscription's plan until the subscription's perks end,
+// and revoke those it no longer grants
+func (x subscriptions) SyncEntitlements(ctx context.Context, sub *datastructure.Subscription) error {
+	plan := &datastructure.SubscriptionPlan{}
+	if err := mongo.Collection(mongo.CollectionNamePlans).FindOne(ctx, bson.M{"_id": sub.PlanID}).Decode(plan); err == mongo.ErrNoDocuments {
+		return ErrUnknownPlan
+	} else if err != nil {
+		return err
+	}
+
+	// The entitlements granted by the plan, while the subscription lasts
+	until := sub.EntitledUntil(SubscriptionGracePeriod())
+	wanted := []datastructure.Entitlement{}
+	if sub.Status != datastructure.SubscriptionStatusEnded && until.After(time.Now()) {
+		wanted = append(wanted, Entitlements.Create(ctx).
+			SetKind(datastructure.EntitlementKindSubscription).
+			SetSubscriptionData(datastructure.EntitledSubscription{
+				ObjectReference: sub.ID,
+				EmoteSlots:      plan.Perks.EmoteSlots,
+			}).Entitlement)
+		if plan.Perks.RoleID != nil {
+			wanted = append(wanted, Entitlements.Create(ctx).
+				SetKind(datastructure.EntitlementKindRole).
+				SetRoleData(datastructure.EntitledRole{ObjectReference: *plan.Perks.RoleID}).Entitlement)
+		}
+		if plan.Perks.BadgeID != nil {
+			wanted = append(wanted, Entitlements.Create(ctx).
+				SetKind(datastructure.EntitlementKindBadge).
+				SetBadgeData(datastructure.EntitledBadge{ObjectReference: *plan.Perks.BadgeID}).Entitlement)
+		}
+	}
+
+	// Extend the entitlements granted already, and find those which the plan no longer grants
+	existing := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, bson.M{
+		"subscription_id": sub.ID,
+		"expired":         bson.M{"$ne": true},
+	})
+	if err == nil {
+		err = cur.All(ctx, &existing)
+	}
+	if err != nil {
+		return err
+	}
+
+	granted := make([]bool, len(wanted))
+	revokeIDs := []primitive.ObjectID{}
+	for _, e := range existing {
+		i := -1
+		for j, w := range wanted {
+			if !granted[j] && w.Kind == e.Kind && entitlementRef(w.Data) == entitlementRef(e.Data) {
+				i = j
+				break
+			}
+		}
+		if i == -1 {
+			revokeIDs = append(revokeIDs, e.ID)
+			continue
+		}
+
+		granted[i] = true
+		update := bson.M{"ends_at": until}
+		if e.Kind == datastructure.EntitlementKindSubscription {
+			update["data"] = wanted[i].Data // The plan's perks may have changed. Other kinds keep their data, such as a badge being selected
+		}
+		if _, err := mongo.Collection(mongo.CollectionNameEntitlements).UpdateOne(ctx, bson.M{"_id": e.ID}, bson.M{
+			"$set": update,
+		}); err != nil {
+			return err
+		}
+		Entitlements.Invalidate(ctx, e)
+	}
+
+	reason := utils.StringPointer(fmt.Sprintf("Subscription %v is %v", sub.ID.Hex(), sub.Status))
+	if len(revokeIDs) > 0 {
+		if _, err := Entitlements.Revoke(ctx, RevokeEntitlementOptions{
+			Actor:  datastructure.SystemUser,
+			Filter: bson.M{"_id": bson.M{"$in": revokeIDs}},
+			Reason: reason,
+		}); err != nil {
+			return err
+		}
+	}
+
+	for i, w := range wanted {
+		if granted[i] {
+			continue
+		}
+
+		w.SubscriptionID = &sub.ID
+		w.EndsAt = &until
+		if _, err := Entitlements.Grant(ctx, GrantEntitlementOptions{
+			Actor:       datastructure.SystemUser,
+			Entitlement: w,
+			UserIDs:     []primitive.ObjectID{sub.UserID},
+			Reason:      reason,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SyncPlan: Sync the entitlements of every subscription to a plan, after its perks changed
+func (x subscriptions) SyncPlan(ctx context.Context, planID primitive.ObjectID) error {
+	subs := []*datastructure.Subscription{}
+	cur, err := mongo.Collection(mongo.CollectionNameSubscriptions).Find(ctx, bson.M{
+		"plan_id": planID,
+		"status":  bson.M{"$ne": datastructure.SubscriptionStatusEnded},
+	})
+	if err == nil {
+		err = cur.All(ctx, &subs)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		sub := sub
+		if err := x.withLock(ctx, sub.Provider, sub.ProviderID, func() error {
+			return x.SyncEntitlements(ctx, sub)
+		}); err != nil {
+			log.WithError(err).WithField("subscription_id", sub.ID).Error("could not sync subscription entitlements")
+		}
+	}
+	return nil
+}
+
+// Run a change of a subscription, while no other change of it runs
+func (subscriptions) withLock(ctx context.Context, provider string, providerID string, f func() error) error {
+	lock, err := redis.GetLocker().Obtain(ctx, fmt.Sprintf("lock:subscription:%v:%v", provider, providerID), 30*time.Second, &redislock.Options{
+		RetryStrategy: redislock.LimitRetry(redislock.LinearBackoff(100*time.Millisecond), 100),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Release(context.Background())
+	}()
+
+	return f()
+}
+
+// Get the ID of the item referenced by entitlement data
+func entitlementRef(data bson.Raw) primitive.ObjectID {
+	if v, err := data.LookupErr("ref"); err == nil {
+		if id, ok := v.ObjectIDOK(); ok {
+			return id
+		}
+	}
+	return primitive.NilObjectID
+}
@@ -99,11 +99,10 @@ func (b UserBuilder) GetRole() datastructure.Role {
 func (b UserBuilder) FetchEntitlements(kind *datastructure.EntitlementKind) ([]EntitlementBuilder, error) {
 	// Make a request to get the user's entitlements
 	var entitlements []*datastructure.Entitlement
-	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(b.ctx, bson.M{
-		"user_id":  b.User.ID,
-		"kind":     kind,
-		"disabled": bson.M{"$not": bson.M{"$eq": true}},
-	})
+	filter := ActiveEntitlementFilter()
+	filter["user_id"] = b.User.ID
+	filter["kind"] = kind
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(b.ctx, filter)
 	if err == mongo.ErrNoDocuments {
 		return nil, nil
 	} else if err != nil {
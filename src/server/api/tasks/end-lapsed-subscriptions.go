@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// End subscriptions which were neither renewed nor paid for within their grace period,
+// in case their provider never told of it
+func EndLapsedSubscriptions(ctx context.Context) error {
+	// Create ticker
+	// This is the interval between checks for lapsed subscriptions
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	log.Info("Task=EndLapsedSubscriptions, starting now")
+
+	f := func() error {
+		// Acquire lock. Only one pod should end subscriptions at a time, the others skip this cycle
+		lock, err := redis.GetLocker().Obtain(ctx, "lock:task:end-lapsed-subscriptions", time.Minute*4, &redislock.Options{})
+		if err == redislock.ErrNotObtained {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Release(context.Background())
+		}()
+
+		// The perks of a subscription never end before its period does
+		subs := []*datastructure.Subscription{}
+		cur, err := mongo.Collection(mongo.CollectionNameSubscriptions).Find(ctx, bson.M{
+			"status":     bson.M{"$ne": datastructure.SubscriptionStatusEnded},
+			"period_end": bson.M{"$lte": time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+		if err := cur.All(ctx, &subs); err != nil {
+			return err
+		}
+
+		count := 0
+		for _, sub := range subs {
+			ended, err := actions.Subscriptions.EndLapsed(ctx, sub)
+			if err != nil {
+				log.WithError(err).WithField("subscription_id", sub.ID).Error("Task=EndLapsedSubscriptions, could not end subscription")
+				continue
+			}
+			if ended {
+				count++
+			}
+		}
+
+		if count > 0 {
+			log.WithField("count", count).Info("Task=EndLapsedSubscriptions, ended subscriptions")
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f(); err != nil {
+				log.WithError(err).Error("EndLapsedSubscriptions")
+			}
+		}
+	}
+}
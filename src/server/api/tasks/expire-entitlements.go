@@ -0,0 +1,163 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Start entitlements which reached their start date and expire those which passed their end date, letting their users know
+func ExpireEntitlements(ctx context.Context) error {
+	// Create ticker
+	// This is the interval between checks for ended entitlements
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	log.Info("Task=ExpireEntitlements, starting now")
+
+	f := func() error {
+		// Acquire lock. Only one pod should expire entitlements at a time, the others skip this cycle
+		lock, err := redis.GetLocker().Obtain(ctx, "lock:task:expire-entitlements", time.Second*50, &redislock.Options{})
+		if err == redislock.ErrNotObtained {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Release(context.Background())
+		}()
+
+		if err := startEntitlements(ctx); err != nil {
+			return err
+		}
+
+		ents := []*datastructure.Entitlement{}
+		cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, bson.M{
+			"ends_at": bson.M{"$lte": time.Now()},
+			"expired": bson.M{"$ne": true},
+		})
+		if err != nil {
+			return err
+		}
+		if err := cur.All(ctx, &ents); err != nil {
+			return err
+		}
+
+		for _, e := range ents {
+			// Mark the entitlement first, so that the user is only told once
+			res, err := mongo.Collection(mongo.CollectionNameEntitlements).UpdateOne(ctx, bson.M{
+				"_id":     e.ID,
+				"expired": bson.M{"$ne": true},
+			}, bson.M{
+				"$set": bson.M{"expired": true},
+			})
+			if err != nil {
+				log.WithError(err).WithField("entitlement_id", e.ID).Error("Task=ExpireEntitlements, could not expire entitlement")
+				continue
+			}
+			if res.ModifiedCount == 0 {
+				continue
+			}
+			actions.Entitlements.Invalidate(ctx, e)
+
+			if !e.Disabled {
+				notifyEntitlementExpired(ctx, e)
+			}
+		}
+
+		if len(ents) > 0 {
+			log.WithField("count", len(ents)).Info("Task=ExpireEntitlements, expired entitlements")
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f(); err != nil {
+				log.WithError(err).Error("ExpireEntitlements")
+			}
+		}
+	}
+}
+
+// Put into effect the entitlements granted to start later, once they do
+func startEntitlements(ctx context.Context) error {
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, bson.M{
+		"pending":   true,
+		"starts_at": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if err := cur.All(ctx, &ents); err != nil {
+		return err
+	}
+
+	for _, e := range ents {
+		// Mark the entitlement first, so that the user is only told once
+		res, err := mongo.Collection(mongo.CollectionNameEntitlements).UpdateOne(ctx, bson.M{
+			"_id":     e.ID,
+			"pending": true,
+		}, bson.M{
+			"$unset": bson.M{"pending": ""},
+		})
+		if err != nil {
+			log.WithError(err).WithField("entitlement_id", e.ID).Error("Task=ExpireEntitlements, could not start entitlement")
+			continue
+		}
+		if res.ModifiedCount == 0 {
+			continue
+		}
+		actions.Entitlements.Invalidate(ctx, e)
+
+		if !e.Disabled && !e.Expired {
+			actions.NotifyEntitlementGranted(e, nil)
+		}
+	}
+
+	if len(ents) > 0 {
+		log.WithField("count", len(ents)).Info("Task=ExpireEntitlements, started entitlements")
+	}
+	return nil
+}
+
+func notifyEntitlementExpired(ctx context.Context, e *datastructure.Entitlement) {
+	notify := actions.Notifications.Create().
+		AddTargetUsers(e.UserID)
+
+	b := actions.Entitlements.With(ctx, *e)
+	switch e.Kind {
+	case datastructure.EntitlementKindRole:
+		notify = notify.SetTitle("Global Role Expired").
+			AddTextMessagePart("Your role").
+			AddRoleMentionPart(b.ReadRoleData().ObjectReference).
+			AddTextMessagePart("has expired.")
+	case datastructure.EntitlementKindBadge:
+		notify = notify.SetTitle("Badge Expired").
+			AddTextMessagePart("A badge you were granted has expired.")
+	case datastructure.EntitlementKindPaint:
+		notify = notify.SetTitle("Paint Expired").
+			AddTextMessagePart("A paint you were granted has expired.")
+	case datastructure.EntitlementKindSubscription:
+		notify = notify.SetTitle("Subscription Ended").
+			AddTextMessagePart("Your subscription has ended, and its perks were removed.")
+	default:
+		notify = notify.SetTitle("Entitlement Expired").
+			AddTextMessagePart(fmt.Sprintf("Your %v entitlement has expired.", e.Kind))
+	}
+
+	if err := notify.Write(ctx); err != nil {
+		log.WithError(err).Error("notifications")
+	}
+}
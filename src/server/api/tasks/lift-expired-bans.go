@@ -0,0 +1,85 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Lift bans which have passed their expiry date
+func LiftExpiredBans(ctx context.Context) error {
+	// Create ticker
+	// This is the interval between checks for expired bans
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	log.Info("Task=LiftExpiredBans, starting now")
+
+	f := func() error {
+		// Acquire lock. Only one pod should lift bans at a time, the others skip this cycle
+		lock, err := redis.GetLocker().Obtain(ctx, "lock:task:lift-expired-bans", time.Second*50, &redislock.Options{})
+		if err == redislock.ErrNotObtained {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Release(context.Background())
+		}()
+
+		bans := []*datastructure.Ban{}
+		cur, err := mongo.Collection(mongo.CollectionNameBans).Find(ctx, bson.M{
+			"active":    true,
+			"expire_at": bson.M{"$gt": time.Time{}, "$lte": time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+		if err := cur.All(ctx, &bans); err != nil {
+			return err
+		}
+
+		// Group the expired bans by user
+		expired := map[primitive.ObjectID][]primitive.ObjectID{}
+		for _, b := range bans {
+			if b.UserID == nil {
+				continue
+			}
+			expired[*b.UserID] = append(expired[*b.UserID], b.ID)
+		}
+
+		for userID, banIDs := range expired {
+			if _, err := actions.Bans.Lift(ctx, actions.LiftBanOptions{
+				Actor:  datastructure.SystemUser,
+				UserID: userID,
+				BanIDs: banIDs,
+				Reason: "Ban expired",
+			}); err != nil {
+				log.WithError(err).WithField("user_id", userID).Error("Task=LiftExpiredBans, could not lift ban")
+			}
+		}
+
+		if len(expired) > 0 {
+			log.WithField("count", len(bans)).Info("Task=LiftExpiredBans, lifted expired bans")
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f(); err != nil {
+				log.WithError(err).Error("LiftExpiredBans")
+			}
+		}
+	}
+}
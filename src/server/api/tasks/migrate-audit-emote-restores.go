@@ -0,0 +1,35 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Retype the emote restorations logged as edits, from before they had a type of their own
+//
+// Only entries predating the audit chain are changed, as chained entries can't be modified without breaking it
+func MigrateAuditEmoteRestores(ctx context.Context) error {
+	res, err := mongo.Collection(mongo.CollectionNameAudit).UpdateMany(ctx, bson.M{
+		"type":        datastructure.AuditLogTypeEmoteEdit,
+		"seq":         bson.M{"$exists": false},
+		"target.type": "emotes",
+		"changes": bson.M{"$elemMatch": bson.M{
+			"key":       "status",
+			"new_value": datastructure.EmoteStatusLive,
+		}},
+	}, bson.M{
+		"$set": bson.M{"type": datastructure.AuditLogTypeEmoteUndoDelete},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount > 0 {
+		log.WithField("count", res.ModifiedCount).Info("Task=MigrateAuditEmoteRestores, retyped emote restorations")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Grant badge entitlements to the users still listed on badges, which predate badge entitlements
+func MigrateBadgeHolders(ctx context.Context) error {
+	// Acquire lock. Only one pod should migrate, the others find nothing left to do once it's done
+	lock, err := redis.GetLocker().Obtain(ctx, "lock:task:migrate-badge-holders", time.Minute*10, &redislock.Options{})
+	if err == redislock.ErrNotObtained {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Release(context.Background())
+	}()
+
+	badges := []*datastructure.Badge{}
+	cur, err := mongo.Collection(mongo.CollectionNameBadges).Find(ctx, bson.M{
+		"users.0": bson.M{"$exists": true},
+	})
+	if err != nil {
+		return err
+	}
+	if err := cur.All(ctx, &badges); err != nil {
+		return err
+	}
+
+	reason := "Migrated from the badge's holder list"
+	for _, b := range badges {
+		ent := actions.Entitlements.Create(ctx).
+			SetKind(datastructure.EntitlementKindBadge).
+			SetBadgeData(datastructure.EntitledBadge{ObjectReference: b.ID}).
+			Entitlement
+
+		granted, err := actions.Entitlements.Grant(ctx, actions.GrantEntitlementOptions{
+			Actor:       datastructure.SystemUser,
+			Entitlement: ent,
+			UserIDs:     b.Users,
+			Reason:      &reason,
+		})
+		if err != nil {
+			log.WithError(err).WithField("badge", b.Name).Error("Task=MigrateBadgeHolders, could not grant entitlements")
+			continue
+		}
+
+		if _, err := mongo.Collection(mongo.CollectionNameBadges).UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{
+			"$unset": bson.M{"users": ""},
+		}); err != nil {
+			return err
+		}
+		actions.Badges.Invalidate(ctx, b.ID)
+		log.WithField("badge", b.Name).WithField("count", len(granted)).Info("Task=MigrateBadgeHolders, migrated badge holders")
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Match the emotes of queued emote imports, readying their plans for confirmation
+func ProcessEmoteImports(ctx context.Context) error {
+	// Create ticker
+	// This is the interval between checks for queued imports
+	ticker := time.NewTicker(time.Second * 10)
+	defer ticker.Stop()
+	log.Info("Task=ProcessEmoteImports, starting now")
+
+	f := func() error {
+		// Imports are claimed one at a time, so that pods share them without a lock.
+		// Those left processing by a pod which went away are claimed again
+		for {
+			imp := &datastructure.EmoteImport{}
+			if err := mongo.Collection(mongo.CollectionNameEmoteImports).FindOneAndUpdate(ctx, bson.M{
+				"$or": bson.A{
+					bson.M{"status": datastructure.EmoteImportStatusPending},
+					bson.M{"status": datastructure.EmoteImportStatusProcessing, "updated_at": bson.M{"$lte": time.Now().Add(-time.Minute * 10)}},
+				},
+			}, bson.M{
+				"$set": bson.M{
+					"status":     datastructure.EmoteImportStatusProcessing,
+					"updated_at": time.Now(),
+				},
+			}, options.FindOneAndUpdate().
+				SetSort(bson.M{"created_at": 1}).
+				SetReturnDocument(options.After),
+			).Decode(imp); err != nil {
+				if err == mongo.ErrNoDocuments {
+					return nil
+				}
+				return err
+			}
+
+			// Keep the claim fresh while the import is processed, so that no other pod claims it meanwhile
+			done := make(chan struct{})
+			go func(id primitive.ObjectID) {
+				heartbeat := time.NewTicker(time.Minute)
+				defer heartbeat.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-heartbeat.C:
+						if _, err := mongo.Collection(mongo.CollectionNameEmoteImports).UpdateOne(ctx, bson.M{
+							"_id":    id,
+							"status": datastructure.EmoteImportStatusProcessing,
+						}, bson.M{
+							"$set": bson.M{"updated_at": time.Now()},
+						}); err != nil {
+							log.WithError(err).WithField("import_id", id).Error("Task=ProcessEmoteImports, could not refresh claim")
+						}
+					}
+				}
+			}(imp.ID)
+
+			err := actions.Emotes.ProcessImport(ctx, imp)
+			close(done)
+			if err != nil {
+				log.WithError(err).WithField("import_id", imp.ID).Error("Task=ProcessEmoteImports, could not process import")
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f(); err != nil {
+				log.WithError(err).Error("ProcessEmoteImports")
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rebuild the cosmetic maps served to clients once cosmetics or their holders changed
+func RebuildCosmeticMaps(ctx context.Context) error {
+	// Create ticker
+	// This is the interval between checks for changes, which bounds how long a change takes to reach clients
+	ticker := time.NewTicker(time.Second * 10)
+	defer ticker.Stop()
+	log.Info("Task=RebuildCosmeticMaps, starting now")
+
+	f := func(m *actions.CosmeticMap) error {
+		// Acquire lock. Only one pod should rebuild a map at a time, the others skip this cycle
+		lock, err := redis.GetLocker().Obtain(ctx, m.LockKey(), time.Minute, &redislock.Options{})
+		if err == redislock.ErrNotObtained {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Release(context.Background())
+		}()
+
+		if ok, err := m.NeedsRebuild(ctx); err != nil || !ok {
+			return err
+		}
+
+		version, err := m.Rebuild(ctx)
+		if err != nil {
+			// Try again on the next cycle
+			m.MarkDirty(ctx)
+			return err
+		}
+		log.WithField("map", m.Name()).WithField("version", version).Debug("Task=RebuildCosmeticMaps, rebuilt a cosmetic map")
+		return nil
+	}
+	rebuild := func() {
+		for _, m := range actions.CosmeticMaps {
+			if err := f(m); err != nil {
+				log.WithError(err).WithField("map", m.Name()).Error("RebuildCosmeticMaps")
+			}
+		}
+	}
+
+	rebuild()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			rebuild()
+		}
+	}
+}
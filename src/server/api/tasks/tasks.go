@@ -15,6 +15,52 @@ func Start() {
 	taskCtx = ctx
 	taskCancelCtx = cancel
 
+	go func() {
+		if err := LiftExpiredBans(taskCtx); err != nil {
+			log.WithError(err).Error("failed to lift expired bans")
+		}
+	}()
+	go func() {
+		if err := MigrateBadgeHolders(taskCtx); err != nil {
+			log.WithError(err).Error("failed to migrate badge holders")
+		}
+	}()
+	go func() {
+		if err := MigrateAuditEmoteRestores(taskCtx); err != nil {
+			log.WithError(err).Error("failed to migrate emote restorations in the audit log")
+		}
+	}()
+	go func() {
+		if err := ExpireEntitlements(taskCtx); err != nil {
+			log.WithError(err).Error("failed to expire entitlements")
+		}
+	}()
+	go func() {
+		if err := EndLapsedSubscriptions(taskCtx); err != nil {
+			log.WithError(err).Error("failed to end lapsed subscriptions")
+		}
+	}()
+	go func() {
+		if err := RebuildCosmeticMaps(taskCtx); err != nil {
+			log.WithError(err).Error("failed to rebuild the cosmetic maps")
+		}
+	}()
+	go func() {
+		if err := WatchFilterRules(taskCtx); err != nil {
+			log.WithError(err).Error("failed to watch the filter rules")
+		}
+	}()
+	go func() {
+		if err := VerifyAuditChain(taskCtx); err != nil {
+			log.WithError(err).Error("failed to verify the audit chain")
+		}
+	}()
+	go func() {
+		if err := ProcessEmoteImports(taskCtx); err != nil {
+			log.WithError(err).Error("failed to process emote imports")
+		}
+	}()
+
 	if err := CheckEmotesPopularity(taskCtx); err != nil {
 		log.WithError(err).Error("failed to check popularity")
 	}
@@ -0,0 +1,152 @@
+package tasks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/discord"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/bsm/redislock"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Walk the audit log chain and alert when entries are missing or were modified
+func VerifyAuditChain(ctx context.Context) error {
+	// Create ticker
+	// This is the interval between verifications of the whole chain
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	log.Info("Task=VerifyAuditChain, starting now")
+
+	f := func() error {
+		// Acquire lock. Only one pod should verify the chain at a time, the others skip this cycle
+		lock, err := redis.GetLocker().Obtain(ctx, "lock:task:verify-audit-chain", time.Minute*50, &redislock.Options{})
+		if err == redislock.ErrNotObtained {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer func() {
+			_ = lock.Release(context.Background())
+		}()
+
+		problems, head, err := verifyAuditChain(ctx)
+		if err != nil {
+			return err
+		}
+
+		// The highest sequence number seen so far. If the chain now ends before it, entries were removed from its end
+		lastHead, err := redis.Client.Get(ctx, "audit:chain:head").Int64()
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+		if head < lastHead {
+			problems = append(problems, fmt.Sprintf("chain ends at #%d but previously reached #%d", head, lastHead))
+		} else if err := redis.Client.Set(ctx, "audit:chain:head", head, 0).Err(); err != nil {
+			return err
+		}
+
+		// Only alert when the problems changed since the last verification
+		sum := sha256.Sum256([]byte(strings.Join(problems, "\n")))
+		fingerprint := hex.EncodeToString(sum[:])
+		lastFingerprint, err := redis.Client.Get(ctx, "audit:chain:problems").Result()
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+		if err := redis.Client.Set(ctx, "audit:chain:problems", fingerprint, 0).Err(); err != nil {
+			return err
+		}
+
+		if len(problems) > 0 {
+			log.WithField("problems", problems).Error("Task=VerifyAuditChain, the audit chain is broken")
+			if fingerprint != lastFingerprint {
+				go discord.SendAuditChainBroken(problems)
+			}
+		}
+		return nil
+	}
+
+	if err := f(); err != nil {
+		log.WithError(err).Error("VerifyAuditChain")
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := f(); err != nil {
+				log.WithError(err).Error("VerifyAuditChain")
+			}
+		}
+	}
+}
+
+// Check every chained entry against the one before it
+//
+// Returns the problems found and the last sequence number in the chain
+func verifyAuditChain(ctx context.Context) ([]string, int64, error) {
+	cur, err := mongo.Collection(mongo.CollectionNameAudit).Find(ctx, bson.M{
+		"seq": bson.M{"$exists": true},
+	}, options.Find().SetSort(bson.M{"seq": 1}).SetBatchSize(500))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(ctx)
+
+	problems := []string{}
+	var lastSeq int64
+	lastHash := ""
+	for cur.Next(ctx) {
+		doc := bson.D{}
+		if err := cur.Decode(&doc); err != nil {
+			return nil, 0, err
+		}
+
+		// Split the stored hash from the rest of the entry
+		var id primitive.ObjectID
+		var seq int64
+		prevHash, hash := "", ""
+		content := make(bson.D, 0, len(doc))
+		for _, e := range doc {
+			switch e.Key {
+			case "_id":
+				id, _ = e.Value.(primitive.ObjectID)
+			case "seq":
+				seq, _ = e.Value.(int64)
+			case "prev_hash":
+				prevHash, _ = e.Value.(string)
+			case "hash":
+				hash, _ = e.Value.(string)
+				continue
+			}
+			content = append(content, e)
+		}
+
+		entry := "#" + strconv.FormatInt(seq, 10) + " (" + id.Hex() + ")"
+		if seq != lastSeq+1 {
+			problems = append(problems, fmt.Sprintf("entries #%d to #%d are missing before %v", lastSeq+1, seq-1, entry))
+		} else if prevHash != lastHash {
+			problems = append(problems, fmt.Sprintf("%v does not link to the entry before it", entry))
+		}
+		if computed, err := actions.AuditLogHash(prevHash, content); err != nil {
+			return nil, 0, err
+		} else if computed != hash {
+			problems = append(problems, fmt.Sprintf("%v was modified after it was written", entry))
+		}
+
+		lastSeq = seq
+		lastHash = hash
+	}
+
+	return problems, lastSeq, cur.Err()
+}
@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	log "github.com/sirupsen/logrus"
+)
+
+// Drop this pod's cached filter rules whenever another pod changes them
+func WatchFilterRules(ctx context.Context) error {
+	ch := make(chan []byte, 1)
+	redis.Subscribe(ctx, ch, actions.FilterInvalidateChannel)
+	log.Info("Task=WatchFilterRules, starting now")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ch:
+			actions.Filter.Drop()
+		}
+	}
+}
@@ -83,6 +83,7 @@ func GQL(app fiber.Router) fiber.Router {
 
 		rCtx := context.WithValue(Ctx, utils.RequestCtxKey, c)
 		rCtx = context.WithValue(rCtx, utils.UserKey, c.Locals("user"))
+		rCtx = context.WithValue(rCtx, utils.BannedUserKey, c.Locals("banned_user"))
 		result := schema.Exec(rCtx, req.Query, req.OperationName, req.Variables)
 
 		status := 200
@@ -15,11 +15,47 @@ var (
 	ErrUnknownChannel        = fmt.Errorf("Unknown Channel")
 	ErrUnknownUser           = fmt.Errorf("Unknown User")
 	ErrUnknownRole           = fmt.Errorf("Unknown Role")
+	ErrUnknownInvitation     = fmt.Errorf("Unknown Invitation")
+	ErrUnknownBan            = fmt.Errorf("Unknown Ban")
+	ErrUnknownAppeal         = fmt.Errorf("Unknown Appeal")
+	ErrUnknownReport         = fmt.Errorf("Unknown Report")
+	ErrBanAlreadyAppealed    = fmt.Errorf("Ban Already Appealed")
 	ErrAccessDenied          = fmt.Errorf("Insufficient Privilege")
 	ErrUserBanned            = fmt.Errorf("User Is Banned")
 	ErrUserNotBanned         = fmt.Errorf("User Is Not Banned")
+	ErrAlreadyEntitled       = fmt.Errorf("User Already Holds This Entitlement")
+	ErrInvalidEntitlement    = fmt.Errorf("Invalid Entitlement ID")
+	ErrUserLockedDown        = fmt.Errorf("User Is Locked Down")
+	ErrUserNotLockedDown     = fmt.Errorf("User Is Not Locked Down")
+	ErrNotReauthenticated    = fmt.Errorf("The Owner Has Not Signed In Again")
 	ErrYourself              = fmt.Errorf("Don't Be Silly")
 	ErrNoReason              = fmt.Errorf("No Reason")
+	ErrInvalidPermissions    = fmt.Errorf("Invalid Permissions")
+	ErrInvalidBanScope       = fmt.Errorf("Invalid Ban Scope")
+	ErrInvalidReportAction   = fmt.Errorf("Invalid Report Action")
+	ErrInvalidFilterRule     = fmt.Errorf("Invalid Filter Rule")
+	ErrUnknownFilterRule     = fmt.Errorf("Unknown Filter Rule")
+	ErrUnknownAuditLog       = fmt.Errorf("Unknown Audit Log")
+	ErrUnknownBadge          = fmt.Errorf("Unknown Badge")
+	ErrInvalidBadgeImage     = fmt.Errorf("Invalid Badge Image (Max 1MB and 1000px)")
+	ErrNotEntitled           = fmt.Errorf("You Are Not Entitled To This Item")
+	ErrUnknownPaint          = fmt.Errorf("Unknown Paint")
+	ErrInvalidPaint          = fmt.Errorf("Invalid Paint")
+	ErrUnknownPlan           = fmt.Errorf("Unknown Subscription Plan")
+	ErrInvalidPlan           = fmt.Errorf("Invalid Subscription Plan")
+	ErrUnknownRestorePoint   = fmt.Errorf("Unknown Restore Point")
+	ErrUnknownEmoteImport    = fmt.Errorf("Unknown Emote Import")
+	ErrEmoteImportInProgress = fmt.Errorf("An Import Of This Channel's Emotes Is Already In Progress")
+	ErrEmoteImportNotReady   = fmt.Errorf("The Import Is Not Awaiting Confirmation")
+	ErrInvalidProvider       = fmt.Errorf("Invalid Provider")
+	ErrInvalidPrecedence     = fmt.Errorf("Invalid Precedence (Unknown Or Repeated Emote Source)")
+	ErrInvalidImportItem     = fmt.Errorf("This Item Can't Be Accepted")
+	ErrInvalidEmoteImage     = fmt.Errorf("Invalid Emote Image (Max 2.5MB and 3000px)")
+	ErrContentFiltered       = fmt.Errorf("Content Rejected By Filter")
+	ErrInvalidTimestamp      = fmt.Errorf("Invalid Timestamp (RFC3339)")
+	ErrInvalidPeriod         = fmt.Errorf("Invalid Period (Must End In The Future, After It Starts)")
+	ErrChannelChanged        = fmt.Errorf("The Channel Changed Meanwhile, Try Again")
+	ErrRevertTooLarge        = fmt.Errorf("Too Many Entries To Revert At Once (Max 500), Narrow The Time Range")
 	ErrInternalServer        = fmt.Errorf("Internal Server Error")
 	ErrDepth                 = fmt.Errorf("Max Depth Exceeded (%v)", MaxDepth)
 	ErrQueryLimit            = fmt.Errorf("Max Query Limit Exceeded (%v)", QueryLimit)
@@ -27,4 +63,16 @@ var (
 	ErrEmoteSlotLimitReached = func(count int32) error {
 		return fmt.Errorf("Channel Emote Slots Limit Reached (%d)", count)
 	}
+	ErrEmoteNameConflict = func(name string) error {
+		return fmt.Errorf("Another Channel Emote Is Named %v", name)
+	}
+	ErrInvalidBatch = func(max int) error {
+		return fmt.Errorf("Invalid Batch (1 To %d Operations)", max)
+	}
+	ErrBatchOperation = func(index int, err error) error {
+		return fmt.Errorf("Operation %d: %w", index, err)
+	}
+	ErrImportItem = func(index int, err error) error {
+		return fmt.Errorf("Item %d: %w", index, err)
+	}
 )
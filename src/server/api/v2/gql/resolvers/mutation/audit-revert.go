@@ -0,0 +1,147 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The most entries a single revert may cover
+const MAX_REVERT_SIZE = 500
+
+//
+// REVERT AUDIT LOGS
+//
+func (*MutationResolver) RevertAuditLogs(ctx context.Context, args struct {
+	IDs     *[]string
+	ActorID *string
+	After   *string
+	Before  *string
+	DryRun  *bool
+	Reason  *string
+}) ([]*query_resolvers.AuditRevertResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	var logs []*datastructure.AuditLog
+	var err error
+	switch {
+	case args.IDs != nil && len(*args.IDs) > 0:
+		if len(*args.IDs) > MAX_REVERT_SIZE {
+			return nil, resolvers.ErrInvalidUpdate
+		}
+		ids := make([]primitive.ObjectID, len(*args.IDs))
+		for i, s := range *args.IDs {
+			if ids[i], err = primitive.ObjectIDFromHex(s); err != nil {
+				return nil, resolvers.ErrUnknownAuditLog
+			}
+		}
+
+		cur, err := mongo.Collection(mongo.CollectionNameAudit).Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err == nil {
+			err = cur.All(ctx, &logs)
+		}
+		if err != nil {
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
+		}
+		if len(logs) != len(ids) {
+			return nil, resolvers.ErrUnknownAuditLog
+		}
+	case args.ActorID != nil:
+		actorID, err := primitive.ObjectIDFromHex(*args.ActorID)
+		if err != nil {
+			return nil, resolvers.ErrUnknownUser
+		}
+		q := actions.AuditQuery{
+			ActorID: &actorID,
+			Types:   actions.RevertibleAuditLogTypes(),
+			Limit:   MAX_REVERT_SIZE + 1,
+		}
+		for _, t := range []struct {
+			arg *string
+			dst **time.Time
+		}{{args.After, &q.After}, {args.Before, &q.Before}} {
+			if t.arg == nil {
+				continue
+			}
+			v, err := time.Parse(time.RFC3339, *t.arg)
+			if err != nil {
+				return nil, resolvers.ErrInvalidTimestamp
+			}
+			*t.dst = &v
+		}
+
+		if logs, err = actions.Audit.Find(ctx, q); err != nil {
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
+		}
+		// A range is reverted whole or not at all
+		if len(logs) > MAX_REVERT_SIZE {
+			return nil, resolvers.ErrRevertTooLarge
+		}
+	default:
+		return nil, resolvers.ErrInvalidUpdate
+	}
+
+	// Undo the most recent changes first, so that each entry is reverted against the state it left behind
+	// Entries from before the chain have no sequence number, and come last in the order they were created
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].Seq != logs[j].Seq {
+			return logs[i].Seq > logs[j].Seq
+		}
+		return logs[i].ID.Hex() > logs[j].ID.Hex()
+	})
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	opts := actions.RevertOptions{
+		Actor:  usr,
+		Logs:   logs,
+		DryRun: args.DryRun == nil || *args.DryRun, // Only preview, unless asked otherwise
+		CanRevert: func(l *datastructure.AuditLog) bool {
+			switch l.Target.Type {
+			case string(mongo.CollectionNameEmotes):
+				return usr.HasPermission(datastructure.RolePermissionEmoteEditAll)
+			case string(mongo.CollectionNameUsers):
+				return usr.HasPermission(datastructure.RolePermissionManageUsers)
+			}
+			return false
+		},
+	}
+	if args.Reason != nil {
+		opts.Reason = *args.Reason
+	}
+
+	results, err := actions.Audit.Revert(ctx, opts)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	result := make([]*query_resolvers.AuditRevertResolver, len(results))
+	for i, r := range results {
+		if result[i], err = query_resolvers.GenerateAuditRevertResolver(ctx, r, field.Children); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
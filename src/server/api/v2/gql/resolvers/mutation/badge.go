@@ -0,0 +1,195 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// CREATE BADGE
+//
+func (*MutationResolver) CreateBadge(ctx context.Context, args struct {
+	Data  query_resolvers.BadgeInput
+	Image string
+}) (*query_resolvers.BadgeResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageCosmetics) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	badge := &datastructure.Badge{ID: primitive.NewObjectID()}
+	args.Data.Apply(badge)
+	if badge.Name == "" {
+		return nil, resolvers.ErrInvalidName
+	}
+
+	image, err := base64.StdEncoding.DecodeString(args.Image)
+	if err != nil {
+		return nil, resolvers.ErrInvalidBadgeImage
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	// Upload the images first, so that the badge is never listed without them
+	if err := actions.Badges.UploadImage(badge.ID, image); err != nil {
+		if err == actions.ErrInvalidBadgeImage {
+			return nil, resolvers.ErrInvalidBadgeImage
+		}
+		log.WithError(err).Error("aws")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppBadgeCreate,
+		Target: &datastructure.Target{ID: &badge.ID, Type: "badges"},
+		After:  badge,
+		Apply: func(ctx context.Context) error {
+			_, err := mongo.Collection(mongo.CollectionNameBadges).InsertOne(ctx, badge)
+			return err
+		},
+	}); err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	actions.Badges.Invalidate(ctx, badge.ID)
+
+	return query_resolvers.GenerateBadgeResolver(ctx, badge, field.Children)
+}
+
+//
+// EDIT BADGE
+//
+func (*MutationResolver) EditBadge(ctx context.Context, args struct {
+	ID    string
+	Data  query_resolvers.BadgeInput
+	Image *string
+}) (*query_resolvers.BadgeResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageCosmetics) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownBadge
+	}
+
+	badge := &datastructure.Badge{}
+	if err := mongo.Collection(mongo.CollectionNameBadges).FindOne(ctx, bson.M{"_id": id}).Decode(badge); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownBadge
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	old := *badge
+	args.Data.Apply(badge)
+	if badge.Name == "" {
+		return nil, resolvers.ErrInvalidName
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	if args.Image != nil {
+		image, err := base64.StdEncoding.DecodeString(*args.Image)
+		if err != nil {
+			return nil, resolvers.ErrInvalidBadgeImage
+		}
+		if err := actions.Badges.UploadImage(badge.ID, image); err != nil {
+			if err == actions.ErrInvalidBadgeImage {
+				return nil, resolvers.ErrInvalidBadgeImage
+			}
+			log.WithError(err).Error("aws")
+			return nil, resolvers.ErrInternalServer
+		}
+	}
+
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppBadgeEdit,
+		Target: &datastructure.Target{ID: &badge.ID, Type: "badges"},
+		Before: &old,
+		After:  badge,
+		Apply: func(ctx context.Context) error {
+			// Patch the badge as it is now, so that a concurrent edit isn't overwritten
+			if err := mongo.Collection(mongo.CollectionNameBadges).FindOne(ctx, bson.M{"_id": id}).Decode(&old); err != nil {
+				return err
+			}
+			*badge = old
+			args.Data.Apply(badge)
+
+			_, err := mongo.Collection(mongo.CollectionNameBadges).UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+				"$set": bson.M{
+					"name":    badge.Name,
+					"tooltip": badge.Tooltip,
+					"misc":    badge.Misc,
+				},
+			})
+			return err
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownBadge
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	actions.Badges.Invalidate(ctx, badge.ID)
+
+	return query_resolvers.GenerateBadgeResolver(ctx, badge, field.Children)
+}
+
+//
+// SELECT BADGE
+//
+func (*MutationResolver) SelectBadge(ctx context.Context, args struct {
+	BadgeID string
+}) (*response, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	badgeID, err := primitive.ObjectIDFromHex(args.BadgeID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownBadge
+	}
+
+	if err := actions.Badges.Select(ctx, usr, badgeID); err != nil {
+		if err == actions.ErrNotEntitled {
+			return nil, resolvers.ErrNotEntitled
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "Badge selected",
+	}, nil
+}
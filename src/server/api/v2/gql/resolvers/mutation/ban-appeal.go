@@ -0,0 +1,207 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// SUBMIT BAN APPEAL
+//
+func (*MutationResolver) SubmitBanAppeal(ctx context.Context, args struct {
+	BanID   string
+	Message string
+}) (*query_resolvers.BanAppealResolver, error) {
+	usr, ok := query_resolvers.GetAppealingUser(ctx)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	if len(args.Message) < 10 || len(args.Message) > 2000 {
+		return nil, resolvers.ErrInvalidUpdate
+	}
+
+	banID, err := primitive.ObjectIDFromHex(args.BanID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownBan
+	}
+
+	ban := &datastructure.Ban{}
+	if err := mongo.Collection(mongo.CollectionNameBans).FindOne(ctx, bson.M{
+		"_id":     banID,
+		"user_id": usr.ID,
+	}).Decode(ban); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownBan
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if !ban.IsActive() {
+		return nil, resolvers.ErrUserNotBanned
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	appeal := &datastructure.BanAppeal{
+		BanID:   ban.ID,
+		UserID:  usr.ID,
+		Message: args.Message,
+		Status:  datastructure.BanAppealStatusPending,
+	}
+	appeal.ID = primitive.NewObjectID()
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeUserBanAppeal,
+		Target: &datastructure.Target{ID: &usr.ID, Type: "users"},
+		Before: bson.M{},
+		After:  bson.M{"ban_appeals": appeal},
+		Apply: func(ctx context.Context) error {
+			_, err := mongo.Collection(mongo.CollectionNameBanAppeals).InsertOne(ctx, appeal)
+			return err
+		},
+	}); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, resolvers.ErrBanAlreadyAppealed
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return query_resolvers.GenerateBanAppealResolver(ctx, appeal, field.Children)
+}
+
+//
+// REVIEW BAN APPEAL
+//
+func (*MutationResolver) ReviewBanAppeal(ctx context.Context, args struct {
+	ID     string
+	Accept bool
+	Note   *string
+}) (*query_resolvers.BanAppealResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionBanUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	appealID, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownAppeal
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	now := time.Now()
+	update := bson.M{
+		"status":      utils.Ternary(args.Accept, datastructure.BanAppealStatusAccepted, datastructure.BanAppealStatusRejected),
+		"reviewer_id": usr.ID,
+		"reviewed_at": now,
+	}
+	if args.Note != nil {
+		update["reviewer_note"] = *args.Note
+	}
+
+	// Only pending appeals may be reviewed, so concurrent reviews can't both succeed
+	pending := &datastructure.BanAppeal{}
+	appeal := &datastructure.BanAppeal{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeUserBanAppealReview,
+		Target: &datastructure.Target{ID: &pending.UserID, Type: "users"},
+		Reason: args.Note,
+		Before: pending,
+		After:  appeal,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameBanAppeals, bson.M{
+				"_id":    appealID,
+				"status": datastructure.BanAppealStatusPending,
+			}, bson.M{
+				"$set": update,
+			}, pending, appeal)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownAppeal
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	if args.Accept {
+		// Lifting the ban notifies the user
+		if _, err := actions.Bans.Lift(ctx, actions.LiftBanOptions{
+			Actor:  usr,
+			UserID: appeal.UserID,
+			BanIDs: []primitive.ObjectID{appeal.BanID},
+			Reason: "Appeal accepted",
+		}); err != nil {
+			log.WithError(err).Error("mongo")
+
+			// Leave the appeal pending, so that it may be reviewed again
+			reason := "The ban could not be lifted"
+			reopened := &datastructure.BanAppeal{}
+			if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+				Actor:  usr,
+				Type:   datastructure.AuditLogTypeUserBanAppealReview,
+				Target: &datastructure.Target{ID: &appeal.UserID, Type: "users"},
+				Reason: &reason,
+				Before: appeal,
+				After:  reopened,
+				Apply: func(ctx context.Context) error {
+					return actions.Audit.UpdateOne(ctx, mongo.CollectionNameBanAppeals, bson.M{
+						"_id":    appeal.ID,
+						"status": datastructure.BanAppealStatusAccepted,
+					}, bson.M{
+						"$set": bson.M{
+							"status":        datastructure.BanAppealStatusPending,
+							"reviewer_id":   nil,
+							"reviewed_at":   nil,
+							"reviewer_note": "",
+						},
+					}, appeal, reopened)
+				},
+			}); err != nil {
+				log.WithError(err).WithField("appeal_id", appeal.ID).Error("mongo, could not reopen the appeal")
+			}
+			return nil, resolvers.ErrInternalServer
+		}
+	}
+
+	if !args.Accept {
+		go func() {
+			n := actions.Notifications.Create().
+				SetTitle("Your Ban Appeal Was Rejected").
+				AddTargetUsers(appeal.UserID).
+				AddTextMessagePart("Your ban appeal was rejected by ").
+				AddUserMentionPart(usr.ID).
+				AddTextMessagePart(".")
+			if appeal.ReviewerNote != "" {
+				n = n.AddTextMessagePart(" Note: " + appeal.ReviewerNote)
+			}
+			if err := n.Write(context.Background()); err != nil {
+				log.WithError(err).Error("failed to create notification")
+			}
+		}()
+	}
+
+	return query_resolvers.GenerateBanAppealResolver(ctx, appeal, field.Children)
+}
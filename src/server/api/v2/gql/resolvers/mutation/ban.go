@@ -7,6 +7,7 @@ import (
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
 	"github.com/SevenTV/ServerGo/src/utils"
 	log "github.com/sirupsen/logrus"
@@ -21,6 +22,7 @@ func (*MutationResolver) BanUser(ctx context.Context, args struct {
 	VictimID string
 	ExpireAt *string
 	Reason   *string
+	Scope    *int32
 }) (*response, error) {
 	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
 	if !ok {
@@ -43,6 +45,14 @@ func (*MutationResolver) BanUser(ctx context.Context, args struct {
 		return nil, resolvers.ErrYourself
 	}
 
+	scope := int64(0)
+	if args.Scope != nil {
+		scope = int64(*args.Scope)
+		if scope < 0 || utils.BitField.RemoveBits(scope, datastructure.BanScopeAll) != 0 {
+			return nil, resolvers.ErrInvalidBanScope
+		}
+	}
+
 	// Check if ban already exists on victim
 	_, err = redis.Client.HGet(ctx, "user:bans", id.Hex()).Result()
 	if err != nil && err != redis.ErrNil {
@@ -88,37 +98,17 @@ func (*MutationResolver) BanUser(ctx context.Context, args struct {
 		expireAt, _ = time.Parse("2006-01-02T15:04:05.999Z07:00", *args.ExpireAt)
 	}
 
-	ban := &datastructure.Ban{
-		UserID:     &user.ID,
-		Reason:     reasonN,
-		IssuedByID: &usr.ID,
-		ExpireAt:   expireAt,
-	}
-
-	_, err = mongo.Collection(mongo.CollectionNameBans).InsertOne(ctx, ban)
-	if err != nil {
-		log.Errorf("mongo, err=%v", err)
-		return nil, resolvers.ErrInternalServer
-	}
-
-	_, err = redis.Client.HSet(ctx, "user:bans", id.Hex(), reasonN).Result()
-	if err != nil {
-		log.Errorf("redis, err=%v", err)
+	if _, err := actions.Bans.Create(ctx, actions.CreateBanOptions{
+		Actor:    usr,
+		VictimID: user.ID,
+		Reason:   reasonN,
+		ExpireAt: expireAt,
+		Scope:    scope,
+	}); err != nil {
+		log.WithError(err).Error("ban")
 		return nil, resolvers.ErrInternalServer
 	}
 
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeUserBan,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &id, Type: "users"},
-		Changes:   nil,
-		Reason:    args.Reason,
-	})
-
-	if err != nil {
-		log.Errorf("mongo, err=%v", err)
-	}
-
 	return &response{
 		OK:      true,
 		Status:  200,
@@ -152,15 +142,6 @@ func (*MutationResolver) UnbanUser(ctx context.Context, args struct {
 		return nil, resolvers.ErrYourself
 	}
 
-	_, err = redis.Client.HGet(ctx, "user:bans", id.Hex()).Result()
-	if err != nil {
-		if err != redis.ErrNil {
-			return nil, resolvers.ErrUserNotBanned
-		}
-		log.Errorf("redis, err=%v", err)
-		return nil, resolvers.ErrInternalServer
-	}
-
 	res := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 		"_id": id,
 	})
@@ -181,35 +162,22 @@ func (*MutationResolver) UnbanUser(ctx context.Context, args struct {
 		return nil, resolvers.ErrInternalServer
 	}
 
-	_, err = mongo.Collection(mongo.CollectionNameBans).UpdateMany(ctx, bson.M{
-		"user_id":   user.ID,
-		"expire_at": bson.M{"$not": bson.M{"$eq": time.Time{}}},
-	}, bson.M{
-		"$set": bson.M{
-			"expire_at": time.Time{},
-		},
-	})
-	if err != nil {
-		log.Errorf("mongo, err=%v", err)
-		return nil, resolvers.ErrInternalServer
+	reasonN := "no reason"
+	if args.Reason != nil {
+		reasonN = *args.Reason
 	}
 
-	_, err = redis.Client.HDel(ctx, "user:bans", id.Hex()).Result()
+	lifted, err := actions.Bans.Lift(ctx, actions.LiftBanOptions{
+		Actor:  usr,
+		UserID: user.ID,
+		Reason: reasonN,
+	})
 	if err != nil {
-		log.Errorf("redis, err=%v", err)
+		log.WithError(err).Error("unban")
 		return nil, resolvers.ErrInternalServer
 	}
-
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeUserUnban,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &id, Type: "users"},
-		Changes:   nil,
-		Reason:    args.Reason,
-	})
-
-	if err != nil {
-		log.Errorf("mongo, err=%v", err)
+	if len(lifted) == 0 {
+		return nil, resolvers.ErrUserNotBanned
 	}
 
 	return &response{
@@ -218,3 +186,16 @@ func (*MutationResolver) UnbanUser(ctx context.Context, args struct {
 		Message: "success",
 	}, nil
 }
+
+// Reject the actor if they are banned from performing an action
+func checkBanScope(ctx context.Context, usr *datastructure.User, scope int64) error {
+	restricted, err := actions.Bans.IsRestricted(ctx, usr.ID, scope)
+	if err != nil {
+		log.WithError(err).Error("redis")
+		return resolvers.ErrInternalServer
+	}
+	if restricted {
+		return resolvers.ErrUserBanned
+	}
+	return nil
+}
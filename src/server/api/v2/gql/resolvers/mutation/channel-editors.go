@@ -2,10 +2,14 @@ package mutation_resolvers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/SevenTV/ServerGo/src/configure"
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
 	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
 	"github.com/SevenTV/ServerGo/src/utils"
@@ -19,9 +23,10 @@ import (
 // ADD CHANNEL EDITOR
 //
 func (*MutationResolver) AddChannelEditor(ctx context.Context, args struct {
-	ChannelID string
-	EditorID  string
-	Reason    *string
+	ChannelID   string
+	EditorID    string
+	Permissions *int32
+	Reason      *string
 }) (*query_resolvers.UserResolver, error) {
 	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
 	if !ok {
@@ -38,6 +43,14 @@ func (*MutationResolver) AddChannelEditor(ctx context.Context, args struct {
 		return nil, resolvers.ErrUnknownChannel
 	}
 
+	permissions := datastructure.UserEditorPermissionDefault
+	if args.Permissions != nil {
+		permissions = int64(*args.Permissions)
+		if permissions <= 0 || utils.BitField.RemoveBits(permissions, datastructure.UserEditorPermissionAll) != 0 {
+			return nil, resolvers.ErrInvalidPermissions
+		}
+	}
+
 	// Can't add self as editor...
 	if editorID.Hex() == channelID.Hex() {
 		return nil, resolvers.ErrYourself
@@ -53,6 +66,10 @@ func (*MutationResolver) AddChannelEditor(ctx context.Context, args struct {
 		return nil, resolvers.ErrUserBanned
 	}
 
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
 	_, err = redis.Client.HGet(ctx, "user:bans", editorID.Hex()).Result()
 	if err != nil && err != redis.ErrNil {
 		log.WithError(err).Error("redis")
@@ -82,8 +99,12 @@ func (*MutationResolver) AddChannelEditor(ctx context.Context, args struct {
 		return nil, resolvers.ErrInternalServer
 	}
 
-	if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
-		if channel.ID.Hex() != usr.ID.Hex() {
+	if !channel.CanEditChannel(usr, datastructure.UserEditorPermissionManageEditors) {
+		return nil, resolvers.ErrAccessDenied
+	}
+	// Editors may not grant permissions they do not hold themselves
+	if held, ok := channel.GetEditorPermissions(usr.ID); ok && !usr.HasPermission(datastructure.RolePermissionManageUsers) {
+		if !utils.BitField.HasBits(held, permissions) {
 			return nil, resolvers.ErrAccessDenied
 		}
 	}
@@ -93,39 +114,143 @@ func (*MutationResolver) AddChannelEditor(ctx context.Context, args struct {
 		return nil, resolvers.ErrDepth
 	}
 
-	var newChannel *datastructure.User
-	after := options.After
-	doc := mongo.Collection(mongo.CollectionNameUsers).FindOneAndUpdate(ctx, bson.M{
-		"_id": channelID,
-	}, bson.M{
-		"$addToSet": bson.M{
-			"editors": editorID,
-		},
-	}, &options.FindOneAndUpdateOptions{
-		ReturnDocument: &after,
-	})
-	if err := doc.Decode(&newChannel); err != nil {
-		return nil, err
+	// Existing editors only have their permissions updated, anyone else must accept an invitation first
+	if _, isEditor := channel.GetEditorPermissions(editorID); isEditor {
+		if !channel.CanManageEditor(usr, editorID, permissions) {
+			return nil, resolvers.ErrAccessDenied
+		}
+
+		newChannel, err := setChannelEditor(ctx, usr, channel, editorID, permissions, nil, args.Reason)
+		if err != nil {
+			return nil, err
+		}
+
+		return query_resolvers.GenerateUserResolver(ctx, newChannel, &newChannel.ID, field.Children)
 	}
 
-	if err != nil {
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": editorID}).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownUser
+		}
 		log.WithError(err).Error("mongo")
 		return nil, resolvers.ErrInternalServer
 	}
 
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeUserChannelEditorAdd,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &channelID, Type: "users"},
-		Changes: []*datastructure.AuditLogChange{
-			{Key: "editors", OldValue: nil, NewValue: editorID},
-		},
+	ttl := configure.Config.GetDuration("limits.meta.editor_invitation_ttl")
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+
+	// Create the invitation, or refresh a pending one
+	upsert := true
+	after := options.After
+	invitation := &datastructure.EditorInvitation{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeUserChannelEditorInvite,
+		Target: &datastructure.Target{ID: &channelID, Type: "users"},
 		Reason: args.Reason,
-	})
-	if err != nil {
+		Before: bson.M{"editor_invitations": nil},
+		After:  bson.M{"editor_invitations": &invitation.ID},
+		Apply: func(ctx context.Context) error {
+			return mongo.Collection(mongo.CollectionNameEditorInvitations).FindOneAndUpdate(ctx, bson.M{
+				"channel_id": channelID,
+				"editor_id":  editorID,
+				"status":     datastructure.EditorInvitationStatusPending,
+			}, bson.M{
+				"$set": bson.M{
+					"invited_by_id": usr.ID,
+					"permissions":   permissions,
+					"expire_at":     time.Now().Add(ttl),
+				},
+			}, &options.FindOneAndUpdateOptions{
+				Upsert:         &upsert,
+				ReturnDocument: &after,
+			}).Decode(invitation)
+		},
+	}); err != nil {
 		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
 	}
-	return query_resolvers.GenerateUserResolver(ctx, newChannel, &newChannel.ID, field.Children)
+
+	// Let the invitee know
+	go func() {
+		if err := actions.Notifications.Create().
+			SetTitle("You Were Invited To Become An Editor").
+			AddTargetUsers(editorID).
+			AddUserMentionPart(usr.ID).
+			AddTextMessagePart(" invited you to become an editor of ").
+			AddUserMentionPart(channelID).
+			AddTextMessagePart(fmt.Sprintf("'s channel. The invitation expires on %v.", invitation.ExpireAt.Format("January 2, 2006"))).
+			Write(context.Background()); err != nil {
+			log.WithError(err).Error("failed to create notification")
+		}
+	}()
+
+	return query_resolvers.GenerateUserResolver(ctx, channel, &channel.ID, field.Children)
+}
+
+// Add an editor to a channel, or update the permissions of an existing editor.
+// When the editor accepted an invitation, it is marked accepted in the same transaction
+func setChannelEditor(ctx context.Context, actor *datastructure.User, channel *datastructure.User, editorID primitive.ObjectID, permissions int64, invitation *datastructure.EditorInvitation, reason *string) (*datastructure.User, error) {
+	set := bson.M{}
+	if len(channel.EditorPermissions) == 0 {
+		set["editor_permissions"] = bson.M{
+			editorID.Hex(): permissions,
+		}
+	} else {
+		set[fmt.Sprintf("editor_permissions.%v", editorID.Hex())] = permissions
+	}
+
+	before := &datastructure.User{}
+	newChannel := &datastructure.User{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  actor,
+		Type:   datastructure.AuditLogTypeUserChannelEditorAdd,
+		Target: &datastructure.Target{ID: &channel.ID, Type: "users"},
+		Reason: reason,
+		Before: before,
+		After:  newChannel,
+		Apply: func(ctx context.Context) error {
+			if err := actions.Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id": channel.ID,
+			}, bson.M{
+				"$addToSet": bson.M{
+					"editors": editorID,
+				},
+				"$set": set,
+			}, before, newChannel); err != nil {
+				return err
+			}
+			if invitation == nil {
+				return nil
+			}
+
+			res, err := mongo.Collection(mongo.CollectionNameEditorInvitations).UpdateOne(ctx, bson.M{
+				"_id":    invitation.ID,
+				"status": datastructure.EditorInvitationStatusPending,
+			}, bson.M{
+				"$set": bson.M{"status": datastructure.EditorInvitationStatusAccepted},
+			})
+			if err != nil {
+				return err
+			}
+			if res.ModifiedCount == 0 { // Answered concurrently
+				return resolvers.ErrUnknownInvitation
+			}
+
+			invitation.Status = datastructure.EditorInvitationStatusAccepted
+			return nil
+		},
+	}); err != nil {
+		if err == resolvers.ErrUnknownInvitation {
+			return nil, err
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return newChannel, nil
 }
 
 //
@@ -151,14 +276,23 @@ func (*MutationResolver) RemoveChannelEditor(ctx context.Context, args struct {
 		return nil, resolvers.ErrUnknownChannel
 	}
 
-	_, err = redis.Client.HGet(ctx, "user:bans", channelID.Hex()).Result()
-	if err != nil && err != redis.ErrNil {
-		log.WithError(err).Error("redis")
-		return nil, resolvers.ErrInternalServer
-	}
+	// Editors may always remove themselves from a channel
+	leaving := editorID == usr.ID
 
-	if err == nil {
-		return nil, resolvers.ErrUserBanned
+	if !leaving {
+		_, err = redis.Client.HGet(ctx, "user:bans", channelID.Hex()).Result()
+		if err != nil && err != redis.ErrNil {
+			log.WithError(err).Error("redis")
+			return nil, resolvers.ErrInternalServer
+		}
+
+		if err == nil {
+			return nil, resolvers.ErrUserBanned
+		}
+
+		if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+			return nil, err
+		}
 	}
 
 	res := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
@@ -180,10 +314,8 @@ func (*MutationResolver) RemoveChannelEditor(ctx context.Context, args struct {
 		return nil, resolvers.ErrInternalServer
 	}
 
-	if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
-		if channel.ID.Hex() != usr.ID.Hex() {
-			return nil, resolvers.ErrAccessDenied
-		}
+	if !leaving && !channel.CanManageEditor(usr, editorID, 0) {
+		return nil, resolvers.ErrAccessDenied
 	}
 
 	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
@@ -191,38 +323,43 @@ func (*MutationResolver) RemoveChannelEditor(ctx context.Context, args struct {
 		return nil, resolvers.ErrDepth
 	}
 
-	var newChannel *datastructure.User
-	after := options.After
-	doc := mongo.Collection(mongo.CollectionNameUsers).FindOneAndUpdate(ctx, bson.M{
-		"_id": channelID,
-	}, bson.M{
-		"$pull": bson.M{
-			"editors": editorID,
+	before := &datastructure.User{}
+	newChannel := &datastructure.User{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeUserChannelEditorRemove,
+		Target: &datastructure.Target{ID: &channelID, Type: "users"},
+		Reason: args.Reason,
+		Before: before,
+		After:  newChannel,
+		Apply: func(ctx context.Context) error {
+			if err := actions.Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id": channelID,
+			}, bson.M{
+				"$pull": bson.M{
+					"editors": editorID,
+				},
+				"$unset": bson.M{
+					fmt.Sprintf("editor_permissions.%v", editorID.Hex()): "",
+				},
+			}, before, newChannel); err != nil {
+				return err
+			}
+
+			// Revoke any invitation still pending for this user
+			_, err := mongo.Collection(mongo.CollectionNameEditorInvitations).UpdateMany(ctx, bson.M{
+				"channel_id": channelID,
+				"editor_id":  editorID,
+				"status":     datastructure.EditorInvitationStatusPending,
+			}, bson.M{
+				"$set": bson.M{"status": datastructure.EditorInvitationStatusRevoked},
+			})
+			return err
 		},
-	}, &options.FindOneAndUpdateOptions{
-		ReturnDocument: &after,
-	})
-	if err := doc.Decode(&newChannel); err != nil {
-		return nil, err
-	}
-
-	if err != nil {
+	}); err != nil {
 		log.WithError(err).Error("mongo")
 		return nil, resolvers.ErrInternalServer
 	}
 
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeUserChannelEditorRemove,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &channelID, Type: "users"},
-		Changes: []*datastructure.AuditLogChange{
-			{Key: "editors", OldValue: nil, NewValue: editorID},
-		},
-		Reason: args.Reason,
-	})
-	if err != nil {
-		log.WithError(err).Error("mongo")
-	}
-
 	return query_resolvers.GenerateUserResolver(ctx, newChannel, &newChannel.ID, field.Children)
 }
@@ -0,0 +1,136 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// BATCH CHANNEL EMOTES
+//
+func (*MutationResolver) BatchChannelEmotes(ctx context.Context, args struct {
+	ChannelID  string
+	Operations []struct {
+		Action  actions.ChannelEmoteAction
+		EmoteID string
+		Alias   *string
+	}
+	Reason *string
+}) (*query_resolvers.UserResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if len(args.Operations) == 0 || len(args.Operations) > actions.MAX_CHANNEL_EMOTE_BATCH {
+		return nil, resolvers.ErrInvalidBatch(actions.MAX_CHANNEL_EMOTE_BATCH)
+	}
+
+	channelID, err := primitive.ObjectIDFromHex(args.ChannelID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownChannel
+	}
+
+	operations := make([]actions.ChannelEmoteOperation, len(args.Operations))
+	for i, op := range args.Operations {
+		emoteID, err := primitive.ObjectIDFromHex(op.EmoteID)
+		if err != nil {
+			return nil, resolvers.ErrBatchOperation(i, resolvers.ErrUnknownEmote)
+		}
+		operations[i] = actions.ChannelEmoteOperation{
+			Action:  op.Action,
+			EmoteID: emoteID,
+			Alias:   op.Alias,
+		}
+	}
+
+	_, err = redis.Client.HGet(ctx, "user:bans", channelID.Hex()).Result()
+	if err != nil && err != redis.ErrNil {
+		log.WithError(err).Error("redis")
+		return nil, resolvers.ErrInternalServer
+	}
+	if err == nil {
+		return nil, resolvers.ErrUserBanned
+	}
+
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
+	channel := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": channelID}).Decode(channel); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownChannel
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	batch := actions.ChannelEmoteBatch{
+		Actor:      usr,
+		Channel:    channel,
+		Operations: operations,
+		Reason:     args.Reason,
+	}
+	if len(operations) > 1 {
+		batch.RestorePoint = utils.StringPointer("Before a batch of emote changes")
+	}
+	updated, err := actions.Emotes.ApplyChannelBatch(ctx, batch)
+	if err != nil {
+		return nil, channelEmoteBatchError(err)
+	}
+
+	return query_resolvers.GenerateUserResolver(ctx, updated, &channelID, field.Children)
+}
+
+// Get the error returned for a batch which failed
+func channelEmoteBatchError(err error) error {
+	if errors.Is(err, actions.ErrChannelChanged) {
+		return resolvers.ErrChannelChanged
+	}
+
+	batchErr := &actions.ChannelEmoteBatchError{}
+	if !errors.As(err, &batchErr) {
+		log.WithError(err).Error("mongo")
+		return resolvers.ErrInternalServer
+	}
+
+	var result error
+	switch batchErr.Err {
+	case actions.ErrBatchInvalid:
+		return resolvers.ErrInvalidBatch(actions.MAX_CHANNEL_EMOTE_BATCH)
+	case actions.ErrBatchSlotLimit:
+		return resolvers.ErrEmoteSlotLimitReached(batchErr.Slots)
+	case actions.ErrBatchNameConflict:
+		result = resolvers.ErrEmoteNameConflict(batchErr.Name)
+	case actions.ErrBatchUnknownEmote:
+		result = resolvers.ErrUnknownEmote
+	case actions.ErrBatchAccessDenied:
+		result = resolvers.ErrAccessDenied
+	case actions.ErrBatchInvalidAlias:
+		result = resolvers.ErrInvalidName
+	case actions.ErrBatchFiltered:
+		result = resolvers.ErrContentFiltered
+	default:
+		result = resolvers.ErrInvalidUpdate
+	}
+	if batchErr.Index < 0 {
+		return result
+	}
+	return resolvers.ErrBatchOperation(batchErr.Index, result)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
 	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
 	"github.com/SevenTV/ServerGo/src/utils"
@@ -14,7 +15,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Mutate Emote - Add to Channel
@@ -48,6 +48,10 @@ func (*MutationResolver) AddChannelEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrUserBanned
 	}
 
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
 	res := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 		"_id": channelID,
 	})
@@ -67,22 +71,18 @@ func (*MutationResolver) AddChannelEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrInternalServer
 	}
 
+	if !channel.CanEditChannel(usr, datastructure.UserEditorPermissionAddEmotes) {
+		return nil, resolvers.ErrAccessDenied
+	}
 	if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
-		if channel.ID.Hex() != usr.ID.Hex() {
-			found := false
-			for _, e := range channel.EditorIDs {
-				if e.Hex() == usr.ID.Hex() {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil, resolvers.ErrAccessDenied
-			}
+		// A channel over its limit keeps its emotes, but can't add more
+		slots, err := actions.Users.EmoteSlots(ctx, channel)
+		if err != nil {
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
 		}
-
-		if (len(channel.EmoteIDs) + 1) > int(channel.GetEmoteSlots()) {
-			return nil, resolvers.ErrEmoteSlotLimitReached(channel.GetEmoteSlots())
+		if !slots.CanAdd(1) {
+			return nil, resolvers.ErrEmoteSlotLimitReached(slots.Total)
 		}
 	}
 
@@ -131,90 +131,38 @@ func (*MutationResolver) AddChannelEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrAccessDenied
 	}
 
-	emoteIDs := append(channel.EmoteIDs, emoteID)
-	after := options.After
-	doc := mongo.Collection(mongo.CollectionNameUsers).FindOneAndUpdate(ctx, bson.M{
-		"_id": channelID,
-	}, bson.M{
-		"$set": bson.M{
-			"emotes": emoteIDs,
-		},
-	}, &options.FindOneAndUpdateOptions{
-		ReturnDocument: &after,
-	})
-	if err := doc.Decode(channel); err != nil {
-		return nil, err
-	}
-
-	if doc.Err() != nil {
-		log.WithError(err).Error("mongo")
-		return nil, resolvers.ErrInternalServer
+	name := emote.Name
+	if v, ok := channel.EmoteAlias[emoteID.Hex()]; ok {
+		name = v
 	}
 
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeUserChannelEmoteAdd,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &channelID, Type: "users"},
-		Changes: []*datastructure.AuditLogChange{
-			{Key: "emotes", OldValue: nil, NewValue: emoteID},
-		},
+	before := &datastructure.User{}
+	updated := &datastructure.User{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeUserChannelEmoteAdd,
+		Target: &datastructure.Target{ID: &channelID, Type: "users"},
 		Reason: args.Reason,
-	})
-	if err != nil {
+		Before: before,
+		After:  updated,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id": channelID,
+			}, bson.M{
+				"$addToSet": bson.M{
+					"emotes": emoteID,
+				},
+			}, before, updated)
+		},
+		Events: func(changes []*datastructure.AuditLogChange) []actions.AuditedEvent {
+			return actions.Emotes.ChannelEvents(updated, emote, name, "ADD", usr)
+		},
+	}); err != nil {
 		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
 	}
 
-	// Push event to redis
-	go func() {
-		_ = redis.Publish(context.Background(), fmt.Sprintf("users:%v:emotes", channel.Login), redis.PubSubPayloadUserEmotes{
-			Removed: false,
-			ID:      emoteID.Hex(),
-			Actor:   usr.DisplayName,
-		})
-
-		name := emote.Name
-		if v, ok := channel.EmoteAlias[emoteID.Hex()]; ok {
-			name = v
-		}
-
-		ownerRes := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
-			"_id": emote.OwnerID,
-		})
-
-		owner := datastructure.User{}
-		err = ownerRes.Err()
-		if err == nil {
-			err = ownerRes.Decode(&owner)
-		}
-		if err != nil {
-			log.WithError(err).Error("mongo")
-		}
-
-		_ = redis.Publish(context.Background(), fmt.Sprintf("events-v1:channel-emotes:%s", channel.Login), redis.EventApiV1ChannelEmotes{
-			Channel: channel.Login,
-			EmoteID: emoteID.Hex(),
-			Name:    name,
-			Action:  "ADD",
-			Actor:   usr.DisplayName,
-			Emote: &redis.EventApiV1ChannelEmotesEmote{
-				Name:       emote.Name,
-				Visibility: emote.Visibility,
-				MIME:       emote.Mime,
-				Tags:       emote.Tags,
-				Width:      emote.Width,
-				Height:     emote.Height,
-				Animated:   emote.Animated,
-				URLs:       datastructure.GetEmoteURLs(*emote),
-				Owner: redis.EventApiV1ChannelEmotesEmoteOwner{
-					ID:          emote.OwnerID.Hex(),
-					TwitchID:    owner.TwitchID,
-					DisplayName: owner.DisplayName,
-					Login:       owner.Login,
-				},
-			},
-		})
-	}()
-	return query_resolvers.GenerateUserResolver(ctx, channel, &channelID, field.Children)
+	return query_resolvers.GenerateUserResolver(ctx, updated, &channelID, field.Children)
 }
 
 // Mutate Emote
@@ -251,6 +199,10 @@ func (*MutationResolver) EditChannelEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrUserBanned
 	}
 
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
 	res := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 		"_id": channelID,
 	})
@@ -286,25 +238,13 @@ func (*MutationResolver) EditChannelEmote(ctx context.Context, args struct {
 	}
 
 	// Check permissions
-	if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
-		if channel.ID.Hex() != usr.ID.Hex() {
-			found := false
-			for _, e := range channel.EditorIDs {
-				if e.Hex() == usr.ID.Hex() {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil, resolvers.ErrAccessDenied
-			}
-		}
+	if !channel.CanEditChannel(usr, datastructure.UserEditorPermissionAliasEmotes) {
+		return nil, resolvers.ErrAccessDenied
 	}
 
 	update := bson.M{}
 	set := bson.M{}
 	unset := bson.M{}
-	logChanges := []*datastructure.AuditLogChange{}
 	if args.Data.Alias != nil {
 		alias := *args.Data.Alias
 		if alias == "" {
@@ -316,6 +256,26 @@ func (*MutationResolver) EditChannelEmote(ctx context.Context, args struct {
 				return nil, resolvers.ErrInvalidName
 			}
 
+			if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+				match, err := actions.Filter.Check(ctx, alias)
+				if err != nil {
+					log.WithError(err).Error("mongo")
+					return nil, resolvers.ErrInternalServer
+				}
+				if match != nil {
+					if match.Rule.Action == datastructure.FilterActionReject {
+						return nil, resolvers.ErrContentFiltered
+					}
+
+					// Aliases can't be held back, so moderators are alerted instead
+					go func() {
+						if err := actions.Filter.Flag(context.Background(), &datastructure.Target{ID: &channel.ID, Type: "users"}, match); err != nil {
+							log.WithError(err).Error("mongo")
+						}
+					}()
+				}
+			}
+
 			if len(channel.EmoteAlias) == 0 {
 				set["emote_alias"] = bson.M{
 					emoteID.Hex(): alias,
@@ -324,10 +284,6 @@ func (*MutationResolver) EditChannelEmote(ctx context.Context, args struct {
 				set[fmt.Sprintf("emote_alias.%v", emoteID.Hex())] = alias
 			}
 		}
-
-		logChanges = append(logChanges, &datastructure.AuditLogChange{
-			Key: "emote_alias", OldValue: channel.EmoteAlias[emoteID.Hex()], NewValue: alias,
-		})
 	}
 
 	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
@@ -342,84 +298,38 @@ func (*MutationResolver) EditChannelEmote(ctx context.Context, args struct {
 	if len(unset) > 0 {
 		update["$unset"] = unset
 	}
-
-	after := options.After
-	doc := mongo.Collection(mongo.CollectionNameUsers).FindOneAndUpdate(ctx, bson.M{
-		"_id": channelID,
-	}, update, &options.FindOneAndUpdateOptions{
-		ReturnDocument: &after,
-	})
-	if err := doc.Decode(channel); err != nil {
-		return nil, err
+	if len(update) == 0 {
+		return nil, resolvers.ErrInvalidUpdate
 	}
 
-	if doc.Err() != nil {
-		log.WithError(err).Error("mongo")
-		return nil, resolvers.ErrInternalServer
+	newName := emote.Name
+	if args.Data.Alias != nil && *args.Data.Alias != "" {
+		newName = *args.Data.Alias
 	}
 
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeUserChannelEmoteEdit,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &channelID, Type: "users"},
-		Changes:   logChanges,
-		Reason:    args.Reason,
-	})
-	if err != nil {
+	before := &datastructure.User{}
+	updated := &datastructure.User{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeUserChannelEmoteEdit,
+		Target: &datastructure.Target{ID: &channelID, Type: "users"},
+		Reason: args.Reason,
+		Before: before,
+		After:  updated,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id": channelID,
+			}, update, before, updated)
+		},
+		Events: func(changes []*datastructure.AuditLogChange) []actions.AuditedEvent {
+			return actions.Emotes.ChannelEvents(updated, emote, newName, "UPDATE", usr)
+		},
+	}); err != nil {
 		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
 	}
 
-	// Push event to redis
-	go func() {
-		_ = redis.Publish(context.Background(), fmt.Sprintf("users:%v:emotes", channel.Login), redis.PubSubPayloadUserEmotes{
-			Removed: false,
-			ID:      emoteID.Hex(),
-			Actor:   usr.DisplayName,
-		})
-
-		newName := emote.Name
-		if args.Data.Alias != nil && *args.Data.Alias != "" {
-			newName = *args.Data.Alias
-		}
-
-		ownerRes := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
-			"_id": emote.OwnerID,
-		})
-
-		owner := datastructure.User{}
-		err = ownerRes.Err()
-		if err == nil {
-			err = ownerRes.Decode(&owner)
-		}
-		if err != nil {
-			log.WithError(err).Error("mongo")
-		}
-
-		_ = redis.Publish(context.Background(), fmt.Sprintf("events-v1:channel-emotes:%s", channel.Login), redis.EventApiV1ChannelEmotes{
-			Channel: channel.Login,
-			EmoteID: emoteID.Hex(),
-			Name:    newName,
-			Action:  "UPDATE",
-			Actor:   usr.DisplayName,
-			Emote: &redis.EventApiV1ChannelEmotesEmote{
-				Name:       emote.Name,
-				Visibility: emote.Visibility,
-				MIME:       emote.Mime,
-				Tags:       emote.Tags,
-				Width:      emote.Width,
-				Height:     emote.Height,
-				Animated:   emote.Animated,
-				URLs:       datastructure.GetEmoteURLs(*emote),
-				Owner: redis.EventApiV1ChannelEmotesEmoteOwner{
-					ID:          emote.OwnerID.Hex(),
-					TwitchID:    owner.TwitchID,
-					DisplayName: owner.DisplayName,
-					Login:       owner.Login,
-				},
-			},
-		})
-	}()
-	return query_resolvers.GenerateUserResolver(ctx, channel, &channelID, field.Children)
+	return query_resolvers.GenerateUserResolver(ctx, updated, &channelID, field.Children)
 }
 
 // Mutate Emote - Remove from Channel
@@ -453,6 +363,10 @@ func (*MutationResolver) RemoveChannelEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrUserBanned
 	}
 
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
 	res := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 		"_id": channelID,
 	})
@@ -489,30 +403,15 @@ func (*MutationResolver) RemoveChannelEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrInternalServer
 	}
 
-	if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
-		if channel.ID.Hex() != usr.ID.Hex() {
-			found := false
-			for _, e := range channel.EditorIDs {
-				if e.Hex() == usr.ID.Hex() {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil, resolvers.ErrAccessDenied
-			}
-		}
+	if !channel.CanEditChannel(usr, datastructure.UserEditorPermissionRemoveEmotes) {
+		return nil, resolvers.ErrAccessDenied
 	}
 
 	found := false
-
-	newIds := []primitive.ObjectID{}
-
 	for _, eID := range channel.EmoteIDs {
 		if eID.Hex() == emoteID.Hex() {
 			found = true
-		} else {
-			newIds = append(newIds, eID)
+			break
 		}
 	}
 
@@ -525,58 +424,36 @@ func (*MutationResolver) RemoveChannelEmote(ctx context.Context, args struct {
 		return query_resolvers.GenerateUserResolver(ctx, channel, &channelID, field.Children)
 	}
 
-	after := options.After
-	doc := mongo.Collection(mongo.CollectionNameUsers).FindOneAndUpdate(ctx, bson.M{
-		"_id": channelID,
-	}, bson.M{
-		"$set": bson.M{
-			"emotes": newIds,
-		},
-	}, &options.FindOneAndUpdateOptions{
-		ReturnDocument: &after,
-	})
-	if err := doc.Decode(channel); err != nil {
-		return nil, err
+	oldName := emote.Name
+	if v, ok := channel.EmoteAlias[emoteID.Hex()]; ok {
+		oldName = v
 	}
 
-	if doc.Err() != nil {
-		log.WithError(err).Error("mongo")
-		return nil, resolvers.ErrInternalServer
-	}
-
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeUserChannelEmoteRemove,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &channelID, Type: "users"},
-		Changes: []*datastructure.AuditLogChange{
-			{Key: "emotes", OldValue: nil, NewValue: emoteID},
-		},
+	before := &datastructure.User{}
+	updated := &datastructure.User{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeUserChannelEmoteRemove,
+		Target: &datastructure.Target{ID: &channelID, Type: "users"},
 		Reason: args.Reason,
-	})
-	if err != nil {
+		Before: before,
+		After:  updated,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+				"_id": channelID,
+			}, bson.M{
+				"$pull": bson.M{
+					"emotes": emoteID,
+				},
+			}, before, updated)
+		},
+		Events: func(changes []*datastructure.AuditLogChange) []actions.AuditedEvent {
+			return actions.Emotes.ChannelEvents(updated, emote, oldName, "REMOVE", usr)
+		},
+	}); err != nil {
 		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
 	}
 
-	// Push event to redis
-	go func() {
-		_ = redis.Publish(context.Background(), fmt.Sprintf("users:%v:emotes", channel.Login), redis.PubSubPayloadUserEmotes{
-			Removed: true,
-			ID:      emoteID.Hex(),
-			Actor:   usr.DisplayName,
-		})
-
-		oldName := emote.Name
-		if v, ok := channel.EmoteAlias[emoteID.Hex()]; ok {
-			oldName = v
-		}
-
-		_ = redis.Publish(context.Background(), fmt.Sprintf("events-v1:channel-emotes:%s", channel.Login), redis.EventApiV1ChannelEmotes{
-			Channel: channel.Login,
-			EmoteID: emoteID.Hex(),
-			Name:    oldName,
-			Action:  "REMOVE",
-			Actor:   usr.DisplayName,
-		})
-	}()
-	return query_resolvers.GenerateUserResolver(ctx, channel, &channelID, field.Children)
+	return query_resolvers.GenerateUserResolver(ctx, updated, &channelID, field.Children)
 }
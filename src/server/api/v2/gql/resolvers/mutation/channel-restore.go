@@ -0,0 +1,96 @@
+package mutation_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// RESTORE CHANNEL
+//
+func (*MutationResolver) RestoreChannel(ctx context.Context, args struct {
+	ChannelID      string
+	RestorePointID string
+	IncludeEditors *bool
+	Preview        *bool
+	Reason         *string
+}) (*query_resolvers.SnapshotImportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	channelID, err := primitive.ObjectIDFromHex(args.ChannelID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownChannel
+	}
+	restorePointID, err := primitive.ObjectIDFromHex(args.RestorePointID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownRestorePoint
+	}
+
+	_, err = redis.Client.HGet(ctx, "user:bans", channelID.Hex()).Result()
+	if err != nil && err != redis.ErrNil {
+		log.WithError(err).Error("redis")
+		return nil, resolvers.ErrInternalServer
+	}
+	if err == nil {
+		return nil, resolvers.ErrUserBanned
+	}
+
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
+	channel := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": channelID}).Decode(channel); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownChannel
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if _, isEditor := channel.GetEditorPermissions(usr.ID); !isEditor && usr.ID != channel.ID && !usr.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	snapshot, err := actions.Users.RestorePoint(ctx, channelID, restorePointID)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if snapshot == nil {
+		return nil, resolvers.ErrUnknownRestorePoint
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result, err := actions.Users.ImportSnapshot(ctx, actions.SnapshotImportOptions{
+		Actor:          usr,
+		Channel:        channel,
+		Snapshot:       snapshot,
+		IncludeEditors: args.IncludeEditors != nil && *args.IncludeEditors,
+		Preview:        args.Preview != nil && *args.Preview,
+		Reason:         args.Reason,
+	})
+	if err == actions.ErrSnapshotAccessDenied {
+		return nil, resolvers.ErrAccessDenied
+	} else if err != nil {
+		return nil, channelEmoteBatchError(err)
+	}
+
+	return query_resolvers.GenerateSnapshotImportResolver(ctx, result, field.Children), nil
+}
@@ -0,0 +1,171 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// ACCEPT EDITOR INVITATION
+//
+func (*MutationResolver) AcceptEditorInvitation(ctx context.Context, args struct {
+	ID string
+}) (*query_resolvers.UserResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	invitation, err := getPendingEditorInvitation(ctx, usr, args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = redis.Client.HGet(ctx, "user:bans", invitation.ChannelID.Hex()).Result()
+	if err != nil && err != redis.ErrNil {
+		log.WithError(err).Error("redis")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	if err == nil {
+		return nil, resolvers.ErrUserBanned
+	}
+
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
+	channel := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
+		"_id": invitation.ChannelID,
+	}).Decode(channel); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownChannel
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	// The sender must still be allowed to grant what they offered
+	inviter := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
+		"_id": invitation.InvitedByID,
+	}).Decode(inviter); err != nil && err != mongo.ErrNoDocuments {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	} else if err == mongo.ErrNoDocuments || !channel.CanManageEditor(inviter, usr.ID, invitation.Permissions) {
+		if err := setEditorInvitationStatus(ctx, invitation, datastructure.EditorInvitationStatusRevoked); err != nil {
+			return nil, err
+		}
+		return nil, resolvers.ErrUnknownInvitation
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	newChannel, err := setChannelEditor(ctx, usr, channel, usr.ID, invitation.Permissions, invitation, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	go notifyEditorInvitationAnswer(invitation, true)
+	return query_resolvers.GenerateUserResolver(ctx, newChannel, &newChannel.ID, field.Children)
+}
+
+//
+// DECLINE EDITOR INVITATION
+//
+func (*MutationResolver) DeclineEditorInvitation(ctx context.Context, args struct {
+	ID string
+}) (*response, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	invitation, err := getPendingEditorInvitation(ctx, usr, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := setEditorInvitationStatus(ctx, invitation, datastructure.EditorInvitationStatusDeclined); err != nil {
+		return nil, err
+	}
+
+	go notifyEditorInvitationAnswer(invitation, false)
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "Invitation declined",
+	}, nil
+}
+
+// Get a pending invitation addressed to the user
+func getPendingEditorInvitation(ctx context.Context, usr *datastructure.User, id string) (*datastructure.EditorInvitation, error) {
+	invitationID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, resolvers.ErrUnknownInvitation
+	}
+
+	invitation := &datastructure.EditorInvitation{}
+	if err := mongo.Collection(mongo.CollectionNameEditorInvitations).FindOne(ctx, bson.M{
+		"_id":       invitationID,
+		"editor_id": usr.ID,
+		"status":    datastructure.EditorInvitationStatusPending,
+		"expire_at": bson.M{"$gt": time.Now()},
+	}).Decode(invitation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownInvitation
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return invitation, nil
+}
+
+// Mark a pending invitation as answered
+func setEditorInvitationStatus(ctx context.Context, invitation *datastructure.EditorInvitation, status int32) error {
+	res, err := mongo.Collection(mongo.CollectionNameEditorInvitations).UpdateOne(ctx, bson.M{
+		"_id":    invitation.ID,
+		"status": datastructure.EditorInvitationStatusPending,
+	}, bson.M{
+		"$set": bson.M{"status": status},
+	})
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return resolvers.ErrInternalServer
+	}
+	if res.ModifiedCount == 0 { // Answered concurrently
+		return resolvers.ErrUnknownInvitation
+	}
+
+	invitation.Status = status
+	return nil
+}
+
+// Let the sender of an invitation know it was answered
+func notifyEditorInvitationAnswer(invitation *datastructure.EditorInvitation, accepted bool) {
+	if err := actions.Notifications.Create().
+		SetTitle(utils.Ternary(accepted, "Editor Invitation Accepted", "Editor Invitation Declined").(string)).
+		AddTargetUsers(invitation.InvitedByID).
+		AddUserMentionPart(invitation.EditorID).
+		AddTextMessagePart(utils.Ternary(accepted, " has accepted", " has declined").(string) + " the invitation to become an editor of ").
+		AddUserMentionPart(invitation.ChannelID).
+		AddTextMessagePart("'s channel.").
+		Write(context.Background()); err != nil {
+		log.WithError(err).Error("failed to create notification")
+	}
+}
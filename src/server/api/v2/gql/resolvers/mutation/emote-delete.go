@@ -29,6 +29,9 @@ func (*MutationResolver) DeleteEmote(ctx context.Context, args struct {
 	if !ok {
 		return nil, resolvers.ErrLoginRequired
 	}
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
 
 	id, err := primitive.ObjectIDFromHex(args.ID)
 	if err != nil {
@@ -59,36 +62,50 @@ func (*MutationResolver) DeleteEmote(ctx context.Context, args struct {
 
 	if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
 		if emote.OwnerID.Hex() != usr.ID.Hex() {
+			owner := &datastructure.User{}
 			if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 				"_id":     emote.OwnerID,
 				"editors": usr.ID,
-			}).Err(); err != nil {
+			}).Decode(owner); err != nil {
 				if err == mongo.ErrNoDocuments {
 					return nil, resolvers.ErrAccessDenied
 				}
 				log.WithError(err).Error("mongo")
 				return nil, resolvers.ErrInternalServer
 			}
+			if p, _ := owner.GetEditorPermissions(usr.ID); !utils.BitField.HasBits(p, datastructure.UserEditorPermissionManageOwnedEmotes) {
+				return nil, resolvers.ErrAccessDenied
+			}
 		}
 	}
 
-	err = actions.Emotes.Delete(ctx, emote)
-	if err != nil {
-		log.WithError(err).Error("mongo")
-		return nil, resolvers.ErrInternalServer
-	}
+	before := &datastructure.Emote{}
+	deleted := &datastructure.Emote{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeEmoteDelete,
+		Target: &datastructure.Target{ID: &id, Type: "emotes"},
+		Reason: &args.Reason,
+		Before: before,
+		After:  deleted,
+		Apply: func(ctx context.Context) error {
+			if err := mongo.Collection(mongo.CollectionNameEmotes).FindOne(ctx, bson.M{"_id": id}).Decode(before); err != nil {
+				return err
+			}
 
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeEmoteDelete,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &id, Type: "emotes"},
-		Changes: []*datastructure.AuditLogChange{
-			{Key: "status", OldValue: emote.Status, NewValue: datastructure.EmoteStatusDeleted},
+			*deleted = *before
+			deleted.Status = datastructure.EmoteStatusDeleted
+			return actions.Emotes.Delete(ctx, before)
 		},
-		Reason: &args.Reason,
-	})
-	if err != nil {
+		Discord: func(changes []*datastructure.AuditLogChange) {
+			discord.SendEmoteDelete(*emote, *usr, args.Reason)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownEmote
+		}
 		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
 	}
 
 	// Send a notification to the emote owner if it was deleted by a user other than themselve
@@ -110,7 +127,6 @@ func (*MutationResolver) DeleteEmote(ctx context.Context, args struct {
 		}()
 	}
 
-	go discord.SendEmoteDelete(*emote, *usr, args.Reason)
 	success = true
 	return &success, nil
 }
@@ -15,7 +15,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 //
@@ -29,9 +28,11 @@ func (*MutationResolver) EditEmote(ctx context.Context, args struct {
 	if !ok {
 		return nil, resolvers.ErrLoginRequired
 	}
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
 
 	update := bson.M{}
-	logChanges := []*datastructure.AuditLogChange{}
 	req := args.Emote
 
 	if req.Name != nil {
@@ -93,46 +94,52 @@ func (*MutationResolver) EditEmote(ctx context.Context, args struct {
 
 	if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
 		if emote.OwnerID.Hex() != usr.ID.Hex() {
+			owner := &datastructure.User{}
 			if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 				"_id":     emote.OwnerID,
 				"editors": usr.ID,
-			}).Err(); err != nil {
+			}).Decode(owner); err != nil {
 				if err == mongo.ErrNoDocuments {
 					return nil, resolvers.ErrAccessDenied
 				}
 				log.WithError(err).Error("mongo")
 				return nil, resolvers.ErrInternalServer
 			}
+			if p, _ := owner.GetEditorPermissions(usr.ID); !utils.BitField.HasBits(p, datastructure.UserEditorPermissionManageOwnedEmotes) {
+				return nil, resolvers.ErrAccessDenied
+			}
 		}
 	}
 
-	if req.Name != nil {
-		if emote.Name != update["name"] {
-			logChanges = append(logChanges, &datastructure.AuditLogChange{
-				Key:      "name",
-				OldValue: emote.Name,
-				NewValue: update["name"],
-			})
+	// Run new names and tags through the content filter
+	filtered := false
+	if (req.Name != nil || req.Tags != nil) && !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+		values := []string{}
+		if req.Name != nil {
+			values = append(values, *req.Name)
 		}
-	}
-	if req.OwnerID != nil {
-		if emote.OwnerID != update["owner"] {
-			logChanges = append(logChanges, &datastructure.AuditLogChange{
-				Key:      "owner",
-				OldValue: emote.OwnerID,
-				NewValue: update["owner"],
-			})
+		if req.Tags != nil {
+			values = append(values, *req.Tags...)
 		}
-	}
-	if req.Tags != nil {
-		if utils.DifferentArray(emote.Tags, update["tags"].([]string)) {
-			logChanges = append(logChanges, &datastructure.AuditLogChange{
-				Key:      "tags",
-				OldValue: emote.Tags,
-				NewValue: update["tags"],
-			})
+
+		match, err := actions.Filter.Check(ctx, values...)
+		if err != nil {
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
+		}
+		if match != nil {
+			if match.Rule.Action == datastructure.FilterActionReject {
+				return nil, resolvers.ErrContentFiltered
+			}
+
+			// Live emotes are taken down until a moderator approves the change
+			if emote.Status == datastructure.EmoteStatusLive {
+				update["status"] = datastructure.EmoteStatusPending
+				filtered = true
+			}
 		}
 	}
+
 	if req.Visibility != nil {
 		if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
 			// User tries to make the emote global but lacks permission
@@ -148,14 +155,6 @@ func (*MutationResolver) EditEmote(ctx context.Context, args struct {
 				return nil, resolvers.ErrAccessDenied
 			}
 		}
-
-		if emote.Visibility != update["visibility"] {
-			logChanges = append(logChanges, &datastructure.AuditLogChange{
-				Key:      "visibility",
-				OldValue: emote.Visibility,
-				NewValue: update["visibility"],
-			})
-		}
 	}
 
 	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
@@ -163,43 +162,33 @@ func (*MutationResolver) EditEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrDepth
 	}
 
-	if len(logChanges) > 0 {
-		update["last_modified_date"] = time.Now()
-
-		oldVisibility := emote.Visibility
-		after := options.After
-		doc := mongo.Collection(mongo.CollectionNameEmotes).FindOneAndUpdate(ctx, bson.M{
-			"_id": id,
-		}, bson.M{
-			"$set": update,
-		}, &options.FindOneAndUpdateOptions{
-			ReturnDocument: &after,
-		})
-		if err := doc.Decode(emote); err != nil {
-			return nil, err
-		}
-
-		err = doc.Err()
-		if err != nil {
-			log.WithError(err).WithField("id", id).Error("mongo")
-			return nil, resolvers.ErrInternalServer
-		}
-
-		_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-			Type:      datastructure.AuditLogTypeEmoteEdit,
-			CreatedBy: usr.ID,
-			Target:    &datastructure.Target{ID: &id, Type: "emotes"},
-			Changes:   logChanges,
-			Reason:    args.Reason,
-		})
-
-		if err != nil {
-			log.WithError(err).Error("mongo")
-		}
+	update["last_modified_date"] = time.Now()
+	before := &datastructure.Emote{}
+	updated := &datastructure.Emote{}
+	changes, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeEmoteEdit,
+		Target: &datastructure.Target{ID: &id, Type: "emotes"},
+		Reason: args.Reason,
+		Before: before,
+		After:  updated,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameEmotes, bson.M{"_id": id}, bson.M{"$set": update}, before, updated)
+		},
+		Discord: func(changes []*datastructure.AuditLogChange) {
+			discord.SendEmoteEdit(*updated, *usr, changes, args.Reason)
+		},
+	})
+	if err != nil {
+		log.WithError(err).WithField("id", id).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
 
+	emote = updated
+	if len(changes) > 0 {
 		// Send a notification to the emote owner if another user removed the UNLISTED flag
 		if usr.ID.Hex() != emote.OwnerID.Hex() {
-			wasUnlisted := utils.BitField.HasBits(int64(oldVisibility), int64(datastructure.EmoteVisibilityUnlisted)) &&
+			wasUnlisted := utils.BitField.HasBits(int64(before.Visibility), int64(datastructure.EmoteVisibilityUnlisted)) &&
 				!utils.BitField.HasBits(int64(emote.Visibility), int64(datastructure.EmoteVisibilityUnlisted))
 
 			if req.Visibility != nil && wasUnlisted {
@@ -219,11 +208,21 @@ func (*MutationResolver) EditEmote(ctx context.Context, args struct {
 					}
 				}()
 			}
-
 		}
 
-		go discord.SendEmoteEdit(*emote, *usr, logChanges, args.Reason)
-		return query_resolvers.GenerateEmoteResolver(ctx, emote, &emote.ID, field.Children)
+		if filtered {
+			go func() {
+				if err := actions.Notifications.Create().
+					SetTitle("Emote Awaiting Review").
+					AddTargetUsers(emote.OwnerID).
+					AddTextMessagePart("Your emote ").
+					AddEmoteMentionPart(emote.ID).
+					AddTextMessagePart(" will be unavailable until a moderator approves the latest changes.").
+					Write(context.Background()); err != nil {
+					log.WithError(err).Error("failed to create notification")
+				}
+			}()
+		}
 	}
 
 	return query_resolvers.GenerateEmoteResolver(ctx, emote, &emote.ID, field.Children)
@@ -0,0 +1,185 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// CREATE EMOTE IMPORT
+//
+func (*MutationResolver) CreateEmoteImport(ctx context.Context, args struct {
+	ChannelID string
+	Providers []string
+}) (*query_resolvers.EmoteImportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	channel, err := emoteImportChannel(ctx, usr, args.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	imp, err := actions.Emotes.CreateImport(ctx, usr, channel, args.Providers)
+	switch err {
+	case nil:
+	case actions.ErrImportAccessDenied:
+		return nil, resolvers.ErrAccessDenied
+	case actions.ErrImportUnknownProvider:
+		return nil, resolvers.ErrInvalidProvider
+	case actions.ErrImportInProgress:
+		return nil, resolvers.ErrEmoteImportInProgress
+	default:
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return query_resolvers.GenerateEmoteImportResolver(ctx, imp, field.Children), nil
+}
+
+//
+// CONFIRM EMOTE IMPORT
+//
+func (*MutationResolver) ConfirmEmoteImport(ctx context.Context, args struct {
+	ID     string
+	Items  []int32
+	Rehost *bool
+	Reason *string
+}) (*query_resolvers.EmoteImportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownEmoteImport
+	}
+	imp, err := actions.Emotes.Import(ctx, id)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if imp == nil {
+		return nil, resolvers.ErrUnknownEmoteImport
+	}
+
+	channel, err := emoteImportChannel(ctx, usr, imp.ChannelID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	rehost := args.Rehost != nil && *args.Rehost
+	if rehost {
+		if !usr.HasPermission(datastructure.RolePermissionEmoteCreate) {
+			return nil, resolvers.ErrAccessDenied
+		}
+		if err := checkBanScope(ctx, usr, datastructure.BanScopeUpload); err != nil {
+			return nil, err
+		}
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	items := make([]int, len(args.Items))
+	for i, v := range args.Items {
+		items[i] = int(v)
+	}
+	result, err := actions.Emotes.ConfirmImport(ctx, actions.EmoteImportConfirmation{
+		Actor:   usr,
+		Channel: channel,
+		Import:  imp,
+		Items:   items,
+		Rehost:  rehost,
+		Reason:  args.Reason,
+	})
+	if err != nil {
+		return nil, emoteImportError(err)
+	}
+
+	return query_resolvers.GenerateEmoteImportResolver(ctx, result, field.Children), nil
+}
+
+// Find the channel of an emote import, which the actor must be allowed to edit
+func emoteImportChannel(ctx context.Context, usr *datastructure.User, channelID string) (*datastructure.User, error) {
+	id, err := primitive.ObjectIDFromHex(channelID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownChannel
+	}
+
+	_, err = redis.Client.HGet(ctx, "user:bans", id.Hex()).Result()
+	if err != nil && err != redis.ErrNil {
+		log.WithError(err).Error("redis")
+		return nil, resolvers.ErrInternalServer
+	}
+	if err == nil {
+		return nil, resolvers.ErrUserBanned
+	}
+
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
+
+	channel := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": id}).Decode(channel); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownChannel
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return channel, nil
+}
+
+// Get the error returned for a confirmation which failed
+func emoteImportError(err error) error {
+	switch err {
+	case actions.ErrImportAccessDenied:
+		return resolvers.ErrAccessDenied
+	case actions.ErrImportNotReady:
+		return resolvers.ErrEmoteImportNotReady
+	}
+
+	itemErr := &actions.EmoteImportItemError{}
+	if !errors.As(err, &itemErr) {
+		return channelEmoteBatchError(err)
+	}
+
+	var result error
+	batchErr := &actions.ChannelEmoteBatchError{}
+	switch {
+	case errors.As(itemErr.Err, &batchErr):
+		result = channelEmoteBatchError(batchErr)
+	case itemErr.Err == actions.ErrImportItemUnacceptable:
+		result = resolvers.ErrInvalidImportItem
+	case itemErr.Err == actions.ErrImportItemFiltered:
+		result = resolvers.ErrContentFiltered
+	case itemErr.Err == actions.ErrInvalidEmoteImage:
+		result = resolvers.ErrInvalidEmoteImage
+	default:
+		log.WithError(itemErr.Err).Error("emote import")
+		result = resolvers.ErrInternalServer
+	}
+	return resolvers.ErrImportItem(itemErr.Index, result)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/SevenTV/ServerGo/src/configure"
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
 	"github.com/SevenTV/ServerGo/src/utils"
 	log "github.com/sirupsen/logrus"
@@ -25,6 +26,9 @@ func (*MutationResolver) RestoreEmote(ctx context.Context, args struct {
 	if !ok {
 		return nil, resolvers.ErrLoginRequired
 	}
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeChannelEdit); err != nil {
+		return nil, err
+	}
 
 	id, err := primitive.ObjectIDFromHex(args.ID)
 	if err != nil {
@@ -53,16 +57,20 @@ func (*MutationResolver) RestoreEmote(ctx context.Context, args struct {
 
 	if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
 		if emote.OwnerID.Hex() != usr.ID.Hex() {
+			owner := &datastructure.User{}
 			if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 				"_id":     emote.OwnerID,
 				"editors": usr.ID,
-			}).Err(); err != nil {
+			}).Decode(owner); err != nil {
 				if err == mongo.ErrNoDocuments {
 					return nil, resolvers.ErrAccessDenied
 				}
 				log.WithError(err).Error("mongo")
 				return nil, resolvers.ErrInternalServer
 			}
+			if p, _ := owner.GetEditorPermissions(usr.ID); !utils.BitField.HasBits(p, datastructure.UserEditorPermissionManageOwnedEmotes) {
+				return nil, resolvers.ErrAccessDenied
+			}
 		}
 	}
 
@@ -96,32 +104,33 @@ func (*MutationResolver) RestoreEmote(ctx context.Context, args struct {
 
 	wg.Wait()
 
-	_, err = mongo.Collection(mongo.CollectionNameEmotes).UpdateOne(ctx, bson.M{
-		"_id": id,
-	}, bson.M{
-		"$set": bson.M{
-			"status":             datastructure.EmoteStatusLive,
-			"last_modified_date": time.Now(),
+	before := &datastructure.Emote{}
+	restored := &datastructure.Emote{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeEmoteUndoDelete,
+		Target: &datastructure.Target{ID: &id, Type: "emotes"},
+		Reason: args.Reason,
+		Before: before,
+		After:  restored,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameEmotes, bson.M{
+				"_id": id,
+			}, bson.M{
+				"$set": bson.M{
+					"status":             datastructure.EmoteStatusLive,
+					"last_modified_date": time.Now(),
+				},
+			}, before, restored)
 		},
-	})
-	if err != nil {
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownEmote
+		}
 		log.WithError(err).Error("mongo")
 		return nil, resolvers.ErrInternalServer
 	}
 
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeEmoteUndoDelete,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &id, Type: "emotes"},
-		Changes: []*datastructure.AuditLogChange{
-			{Key: "status", OldValue: emote.Status, NewValue: datastructure.EmoteStatusLive},
-		},
-		Reason: args.Reason,
-	})
-	if err != nil {
-		log.WithError(err).Error("mongo")
-	}
-
 	return &response{
 		OK:      true,
 		Status:  200,
@@ -0,0 +1,187 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/discord"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// APPROVE EMOTE
+//
+func (*MutationResolver) ApproveEmote(ctx context.Context, args struct {
+	ID     string
+	Reason *string
+}) (*query_resolvers.EmoteResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownEmote
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	// Only pending emotes may be approved, so concurrent reviews can't both succeed
+	pending := &datastructure.Emote{}
+	emote := &datastructure.Emote{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeEmoteApprove,
+		Target: &datastructure.Target{ID: &id, Type: "emotes"},
+		Reason: args.Reason,
+		Before: pending,
+		After:  emote,
+		Apply: func(ctx context.Context) error {
+			if err := mongo.Collection(mongo.CollectionNameEmotes).FindOneAndUpdate(ctx, bson.M{
+				"_id":    id,
+				"status": datastructure.EmoteStatusPending,
+			}, bson.M{
+				"$set": bson.M{
+					"status":             datastructure.EmoteStatusLive,
+					"last_modified_date": time.Now(),
+				},
+			}).Decode(pending); err != nil {
+				return err
+			}
+
+			*emote = *pending
+			emote.Status = datastructure.EmoteStatusLive
+			return nil
+		},
+		Discord: func(changes []*datastructure.AuditLogChange) {
+			// The upload was held, so it is announced now
+			owner := datastructure.User{}
+			if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(context.Background(), bson.M{
+				"_id": emote.OwnerID,
+			}).Decode(&owner); err != nil {
+				log.WithError(err).Error("mongo")
+				return
+			}
+			discord.SendEmoteCreate(*emote, owner)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownEmote
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	go func() {
+		if err := actions.Notifications.Create().
+			SetTitle("Emote Approved").
+			AddTargetUsers(emote.OwnerID).
+			AddTextMessagePart("Your emote ").
+			AddEmoteMentionPart(emote.ID).
+			AddTextMessagePart(" was approved and can now be added to channels.").
+			Write(context.Background()); err != nil {
+			log.WithError(err).Error("failed to create notification")
+		}
+	}()
+
+	return query_resolvers.GenerateEmoteResolver(ctx, emote, nil, field.Children)
+}
+
+//
+// REJECT EMOTE
+//
+func (*MutationResolver) RejectEmote(ctx context.Context, args struct {
+	ID     string
+	Reason string
+}) (*response, error) {
+	if args.Reason == "" {
+		return nil, resolvers.ErrNoReason
+	}
+
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownEmote
+	}
+
+	emote := &datastructure.Emote{}
+	if err := mongo.Collection(mongo.CollectionNameEmotes).FindOne(ctx, bson.M{
+		"_id":    id,
+		"status": datastructure.EmoteStatusPending,
+	}).Decode(emote); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownEmote
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	// Rejected emotes are deleted. The emote is read again in the transaction, so a concurrent approval can't be undone
+	pending := &datastructure.Emote{}
+	rejected := &datastructure.Emote{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeEmoteReject,
+		Target: &datastructure.Target{ID: &id, Type: "emotes"},
+		Reason: &args.Reason,
+		Before: pending,
+		After:  rejected,
+		Apply: func(ctx context.Context) error {
+			if err := mongo.Collection(mongo.CollectionNameEmotes).FindOne(ctx, bson.M{
+				"_id":    id,
+				"status": datastructure.EmoteStatusPending,
+			}).Decode(pending); err != nil {
+				return err
+			}
+
+			*rejected = *pending
+			rejected.Status = datastructure.EmoteStatusDeleted
+			return actions.Emotes.Delete(ctx, pending)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownEmote
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	go func() {
+		if err := actions.Notifications.Create().
+			SetTitle("Emote Rejected").
+			AddTargetUsers(emote.OwnerID).
+			AddTextMessagePart(fmt.Sprintf("Your emote \"%v\" was rejected by a moderator with the reason: \"%v\".", emote.Name, args.Reason)).
+			Write(context.Background()); err != nil {
+			log.WithError(err).Error("failed to create notification")
+		}
+	}()
+
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "Emote rejected",
+	}, nil
+}
@@ -3,11 +3,13 @@ package mutation_resolvers
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/configure"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
 	"github.com/SevenTV/ServerGo/src/utils"
 	"github.com/gofiber/fiber/v2"
 	log "github.com/sirupsen/logrus"
@@ -15,8 +17,12 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// The most users an entitlement may be granted to or revoked from at once
+const MAX_BULK_ENTITLEMENT_USERS = 1000
+
 func (*MutationResolver) DeleteEntitlement(ctx context.Context, args struct {
-	ID string
+	ID     string
+	Reason *string
 }) (*response, error) {
 	// Get actor reference
 	actor, ok := ctx.Value(utils.UserKey).(*datastructure.User)
@@ -36,8 +42,10 @@ func (*MutationResolver) DeleteEntitlement(ctx context.Context, args struct {
 	}
 
 	// Delete the entitlement
-	if _, err = mongo.Collection(mongo.CollectionNameEntitlements).DeleteOne(ctx, bson.M{
-		"_id": eID,
+	if _, err = actions.Entitlements.Revoke(ctx, actions.RevokeEntitlementOptions{
+		Actor:  actor,
+		Filter: bson.M{"_id": eID},
+		Reason: args.Reason,
 	}); err != nil {
 		log.WithError(err).Error("mongo")
 		return nil, resolvers.ErrInternalServer
@@ -55,6 +63,9 @@ func (*MutationResolver) CreateEntitlement(ctx context.Context, args struct {
 	Data     entitlementCreateInput
 	UserID   string
 	Disabled *bool
+	StartsAt *string
+	EndsAt   *string
+	Reason   *string
 }) (*response, error) {
 	// Get actor reference
 	actor, ok := ctx.Value(utils.UserKey).(*datastructure.User)
@@ -73,25 +84,187 @@ func (*MutationResolver) CreateEntitlement(ctx context.Context, args struct {
 		return nil, err
 	}
 
-	// Create an entitlement builder and assign kind+user ID
-	builder := actions.Entitlements.Create(ctx).
-		SetKind(args.Kind).
-		SetUserID(userID)
+	ent, err := buildEntitlement(ctx, args.Kind, args.Data, args.StartsAt, args.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write to DB
+	granted, err := actions.Entitlements.Grant(ctx, actions.GrantEntitlementOptions{
+		Actor:       actor,
+		Entitlement: ent,
+		UserIDs:     []primitive.ObjectID{userID},
+		Reason:      args.Reason,
+	})
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if len(granted) == 0 {
+		return nil, resolvers.ErrAlreadyEntitled
+	}
+
+	// Add the X-Created-ID header specifying the ID of the entitlement created
+	f, ok := ctx.Value(utils.RequestCtxKey).(*fiber.Ctx) // Fiber context
+	if ok {
+		f.Set("X-Created-ID", granted[0].ID.Hex())
+	}
 
-	// Initiate a new notification to be sent to the entitled user
-	notify := actions.Notifications.Create().
-		AddTargetUsers(userID)
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "Entitlement Created",
+	}, nil
+}
+
+//
+// GRANT ENTITLEMENTS
+//
+func (*MutationResolver) GrantEntitlements(ctx context.Context, args struct {
+	Kind     datastructure.EntitlementKind
+	Data     entitlementCreateInput
+	UserIDs  []string
+	StartsAt *string
+	EndsAt   *string
+	Reason   *string
+}) ([]*query_resolvers.EntitlementResolver, error) {
+	actor, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !actor.HasPermission(datastructure.RolePermissionManageEntitlements) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	userIDs, err := parseEntitlementIDs(args.UserIDs, resolvers.ErrUnknownUser)
+	if err != nil {
+		return nil, err
+	}
+
+	ent, err := buildEntitlement(ctx, args.Kind, args.Data, args.StartsAt, args.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	granted, err := actions.Entitlements.Grant(ctx, actions.GrantEntitlementOptions{
+		Actor:       actor,
+		Entitlement: ent,
+		UserIDs:     userIDs,
+		Reason:      args.Reason,
+	})
+	if err != nil {
+		log.WithError(err).WithField("granted", len(granted)).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return generateEntitlementResolvers(ctx, granted, field.Children)
+}
+
+//
+// REVOKE ENTITLEMENTS
+//
+func (*MutationResolver) RevokeEntitlements(ctx context.Context, args struct {
+	IDs     *[]string
+	Kind    *datastructure.EntitlementKind
+	RefID   *string
+	UserIDs *[]string
+	Reason  *string
+}) ([]*query_resolvers.EntitlementResolver, error) {
+	actor, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !actor.HasPermission(datastructure.RolePermissionManageEntitlements) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	// Entitlements are revoked either by ID, or by what they grant to a list of users
+	filter := bson.M{}
+	if args.IDs != nil {
+		ids, err := parseEntitlementIDs(*args.IDs, resolvers.ErrInvalidEntitlement)
+		if err != nil {
+			return nil, err
+		}
+		filter["_id"] = bson.M{"$in": ids}
+	} else {
+		if args.UserIDs == nil || args.Kind == nil {
+			return nil, resolvers.ErrInvalidUpdate
+		}
+		userIDs, err := parseEntitlementIDs(*args.UserIDs, resolvers.ErrUnknownUser)
+		if err != nil {
+			return nil, err
+		}
+		filter["user_id"] = bson.M{"$in": userIDs}
+		filter["kind"] = *args.Kind
+		if args.RefID != nil {
+			refID, err := primitive.ObjectIDFromHex(*args.RefID)
+			if err != nil {
+				return nil, resolvers.ErrInvalidUpdate
+			}
+			filter["data.ref"] = refID
+		}
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	revoked, err := actions.Entitlements.Revoke(ctx, actions.RevokeEntitlementOptions{
+		Actor:  actor,
+		Filter: filter,
+		Reason: args.Reason,
+	})
+	if err != nil {
+		log.WithError(err).WithField("revoked", len(revoked)).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return generateEntitlementResolvers(ctx, revoked, field.Children)
+}
+
+// Build the entitlement granted by a create or grant mutation, without its user
+func buildEntitlement(ctx context.Context, kind datastructure.EntitlementKind, data entitlementCreateInput, startsAtArg *string, endsAtArg *string) (datastructure.Entitlement, error) {
+	// Parse the period in which the entitlement is in effect
+	var startsAt, endsAt *time.Time
+	for _, t := range []struct {
+		arg *string
+		dst **time.Time
+	}{{startsAtArg, &startsAt}, {endsAtArg, &endsAt}} {
+		if t.arg == nil {
+			continue
+		}
+		v, err := time.Parse(time.RFC3339, *t.arg)
+		if err != nil {
+			return datastructure.Entitlement{}, resolvers.ErrInvalidTimestamp
+		}
+		*t.dst = &v
+	}
+	if endsAt != nil && (!endsAt.After(time.Now()) || (startsAt != nil && !endsAt.After(*startsAt))) {
+		return datastructure.Entitlement{}, resolvers.ErrInvalidPeriod
+	}
+
+	// Create an entitlement builder and assign kind
+	builder := actions.Entitlements.Create(ctx).
+		SetKind(kind).
+		SetPeriod(startsAt, endsAt)
 
 	// Assign typed data based on kind
 	var itemID primitive.ObjectID
-	switch args.Kind {
+	var err error
+	switch kind {
 	case datastructure.EntitlementKindSubscription:
-		if args.Data.Subscription == nil {
-			return nil, fmt.Errorf("Missing Subscription Data")
+		if data.Subscription == nil {
+			return datastructure.Entitlement{}, fmt.Errorf("Missing Subscription Data")
 		}
-		itemID, err = primitive.ObjectIDFromHex(args.Data.Subscription.ID)
+		itemID, err = primitive.ObjectIDFromHex(data.Subscription.ID)
 		if err != nil {
-			return nil, err
+			return datastructure.Entitlement{}, err
 		}
 
 		// Set Subscription Data to builder
@@ -99,65 +272,103 @@ func (*MutationResolver) CreateEntitlement(ctx context.Context, args struct {
 			ObjectReference: itemID,
 		})
 	case datastructure.EntitlementKindBadge:
-		if args.Data.Badge == nil {
-			return nil, fmt.Errorf("Missing Badge Data")
+		if data.Badge == nil {
+			return datastructure.Entitlement{}, fmt.Errorf("Missing Badge Data")
 		}
-		itemID, err = primitive.ObjectIDFromHex(args.Data.Badge.ID)
+		itemID, err = primitive.ObjectIDFromHex(data.Badge.ID)
 		if err != nil {
-			return nil, err
+			return datastructure.Entitlement{}, err
 		}
 
 		builder = builder.SetBadgeData(datastructure.EntitledBadge{
 			ObjectReference: itemID,
-			Selected:        args.Data.Badge.Selected,
+			Selected:        data.Badge.Selected,
 		})
+	case datastructure.EntitlementKindPaint:
+		if data.Paint == nil {
+			return datastructure.Entitlement{}, fmt.Errorf("Missing Paint Data")
+		}
+		itemID, err = primitive.ObjectIDFromHex(data.Paint.ID)
+		if err != nil {
+			return datastructure.Entitlement{}, err
+		}
+
+		builder = builder.SetPaintData(datastructure.EntitledPaint{
+			ObjectReference: itemID,
+			Selected:        data.Paint.Selected,
+		})
+	case datastructure.EntitlementKindEmoteSet:
+		if data.EmoteSet == nil {
+			return datastructure.Entitlement{}, fmt.Errorf("Missing Emote Set Data")
+		}
+		itemID, err = primitive.ObjectIDFromHex(data.EmoteSet.ID)
+		if err != nil {
+			return datastructure.Entitlement{}, err
+		}
+
+		set := datastructure.EntitledEmoteSet{
+			ObjectReference: itemID,
+			UnicodeTag:      data.EmoteSet.UnicodeTag,
+			EmoteIDs:        make([]primitive.ObjectID, len(data.EmoteSet.EmoteIDs)),
+		}
+		if data.EmoteSet.Slots != nil {
+			if *data.EmoteSet.Slots <= 0 || *data.EmoteSet.Slots > configure.Config.GetInt32("limits.meta.channel_emote_slots") {
+				return datastructure.Entitlement{}, resolvers.ErrInvalidUpdate
+			}
+			set.Slots = *data.EmoteSet.Slots
+		}
+		for i, s := range data.EmoteSet.EmoteIDs {
+			if set.EmoteIDs[i], err = primitive.ObjectIDFromHex(s); err != nil {
+				return datastructure.Entitlement{}, resolvers.ErrUnknownEmote
+			}
+		}
+		if len(set.EmoteIDs) > int(set.GetSlots()) {
+			return datastructure.Entitlement{}, resolvers.ErrEmoteSlotLimitReached(set.GetSlots())
+		}
+
+		builder = builder.SetEmoteSetData(set)
 	case datastructure.EntitlementKindRole:
-		if args.Data.Role == nil {
-			return nil, fmt.Errorf("Missing Role Data")
+		if data.Role == nil {
+			return datastructure.Entitlement{}, fmt.Errorf("Missing Role Data")
 		}
-		itemID, err = primitive.ObjectIDFromHex(args.Data.Role.ID)
+		itemID, err = primitive.ObjectIDFromHex(data.Role.ID)
 		if err != nil {
-			return nil, err
+			return datastructure.Entitlement{}, err
 		}
 
 		// Set Role Data to builder
 		builder = builder.SetRoleData(datastructure.EntitledRole{
 			ObjectReference: itemID,
 		})
-
-		role := datastructure.GetRole(&itemID)
-		notify = notify.SetTitle("Global Role Granted").
-			AddTextMessagePart("You've been granted the role").
-			AddRoleMentionPart(role.ID).
-			AddTextMessagePart("by").
-			AddUserMentionPart(actor.ID).
-			AddTextMessagePart(".")
 	}
 
-	// Write to DB
-	if builder, err = builder.Write(); err != nil {
-		log.WithError(err).Error(err)
-		return nil, resolvers.ErrInternalServer
-	}
+	return builder.Entitlement, nil
+}
 
-	// Add the X-Created-ID header specifying the ID of the entitlement created
-	f, ok := ctx.Value(utils.RequestCtxKey).(*fiber.Ctx) // Fiber context
-	if ok {
-		f.Set("X-Created-ID", builder.Entitlement.ID.Hex())
+// Parse a list of object IDs, answering invalid ones with errInvalid
+func parseEntitlementIDs(s []string, errInvalid error) ([]primitive.ObjectID, error) {
+	if len(s) == 0 || len(s) > MAX_BULK_ENTITLEMENT_USERS {
+		return nil, resolvers.ErrInvalidUpdate
 	}
 
-	// Send the notification
-	if notify.Notification.Title != "" {
-		go func() {
-			if err := notify.Write(ctx); err != nil {
-				log.WithError(err).Error("notifications")
-			}
-		}()
+	ids := make([]primitive.ObjectID, len(s))
+	for i, v := range s {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return nil, errInvalid
+		}
+		ids[i] = id
 	}
+	return ids, nil
+}
 
-	return &response{
-		OK:      true,
-		Status:  200,
-		Message: "Entitlement Created",
-	}, nil
+func generateEntitlementResolvers(ctx context.Context, ents []*datastructure.Entitlement, fields map[string]*query_resolvers.SelectedField) ([]*query_resolvers.EntitlementResolver, error) {
+	result := make([]*query_resolvers.EntitlementResolver, len(ents))
+	var err error
+	for i, e := range ents {
+		if result[i], err = query_resolvers.GenerateEntitlementResolver(ctx, e, fields); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
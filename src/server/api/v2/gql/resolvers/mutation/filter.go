@@ -0,0 +1,178 @@
+package mutation_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// CREATE FILTER RULE
+//
+func (*MutationResolver) CreateFilterRule(ctx context.Context, args struct {
+	Rule query_resolvers.FilterRuleInput
+}) (*query_resolvers.FilterRuleResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageFilters) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	rule := args.Rule.ToFilterRule()
+	rule.CreatedByID = usr.ID
+	if !actions.ValidateFilterRule(rule) {
+		return nil, resolvers.ErrInvalidFilterRule
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	rule.ID = primitive.NewObjectID()
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppFilterRuleCreate,
+		Target: &datastructure.Target{ID: &rule.ID, Type: "filter_rules"},
+		After:  rule,
+		Apply: func(ctx context.Context) error {
+			_, err := mongo.Collection(mongo.CollectionNameFilterRules).InsertOne(ctx, rule)
+			return err
+		},
+	}); err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	actions.Filter.Invalidate(ctx)
+
+	return query_resolvers.GenerateFilterRuleResolver(ctx, rule, field.Children)
+}
+
+//
+// EDIT FILTER RULE
+//
+func (*MutationResolver) EditFilterRule(ctx context.Context, args struct {
+	ID   string
+	Rule query_resolvers.FilterRuleInput
+}) (*query_resolvers.FilterRuleResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageFilters) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownFilterRule
+	}
+
+	rule := &datastructure.FilterRule{}
+	if err := mongo.Collection(mongo.CollectionNameFilterRules).FindOne(ctx, bson.M{"_id": id}).Decode(rule); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownFilterRule
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	old := *rule
+	args.Rule.Apply(rule)
+	if !actions.ValidateFilterRule(rule) {
+		return nil, resolvers.ErrInvalidFilterRule
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppFilterRuleEdit,
+		Target: &datastructure.Target{ID: &rule.ID, Type: "filter_rules"},
+		Before: &old,
+		After:  rule,
+		Apply: func(ctx context.Context) error {
+			// Patch the rule as it is now, so that a concurrent edit isn't overwritten
+			if err := mongo.Collection(mongo.CollectionNameFilterRules).FindOne(ctx, bson.M{"_id": id}).Decode(&old); err != nil {
+				return err
+			}
+			*rule = old
+			args.Rule.Apply(rule)
+			if !actions.ValidateFilterRule(rule) {
+				return resolvers.ErrInvalidFilterRule
+			}
+
+			_, err := mongo.Collection(mongo.CollectionNameFilterRules).ReplaceOne(ctx, bson.M{"_id": id}, rule)
+			return err
+		},
+	}); err != nil {
+		switch err {
+		case mongo.ErrNoDocuments:
+			return nil, resolvers.ErrUnknownFilterRule
+		case resolvers.ErrInvalidFilterRule:
+			return nil, err
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	actions.Filter.Invalidate(ctx)
+
+	return query_resolvers.GenerateFilterRuleResolver(ctx, rule, field.Children)
+}
+
+//
+// DELETE FILTER RULE
+//
+func (*MutationResolver) DeleteFilterRule(ctx context.Context, args struct {
+	ID string
+}) (*response, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageFilters) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownFilterRule
+	}
+
+	rule := &datastructure.FilterRule{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppFilterRuleDelete,
+		Target: &datastructure.Target{ID: &id, Type: "filter_rules"},
+		Before: rule,
+		Apply: func(ctx context.Context) error {
+			return mongo.Collection(mongo.CollectionNameFilterRules).FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(rule)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownFilterRule
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	actions.Filter.Invalidate(ctx)
+
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "Filter rule deleted",
+	}, nil
+}
@@ -0,0 +1,129 @@
+package mutation_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// LOCKDOWN USER
+//
+func (*MutationResolver) LockdownUser(ctx context.Context, args struct {
+	UserID string
+	Reason string
+}) (*response, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+	if args.Reason == "" {
+		return nil, resolvers.ErrNoReason
+	}
+
+	user, err := getLockdownTarget(ctx, usr, args.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := actions.Lockdowns.Create(ctx, actions.CreateLockdownOptions{
+		Actor:  usr,
+		UserID: user.ID,
+		Reason: args.Reason,
+	}); err != nil {
+		if err == actions.ErrLockedDown {
+			return nil, resolvers.ErrUserLockedDown
+		}
+		log.WithError(err).Error("lockdown")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "success",
+	}, nil
+}
+
+//
+// LIFT USER LOCKDOWN
+//
+func (*MutationResolver) LiftUserLockdown(ctx context.Context, args struct {
+	UserID string
+	Reason *string
+}) (*response, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	user, err := getLockdownTarget(ctx, usr, args.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := "no reason"
+	if args.Reason != nil {
+		reason = *args.Reason
+	}
+
+	if err := actions.Lockdowns.Lift(ctx, actions.LiftLockdownOptions{
+		Actor:  usr,
+		UserID: user.ID,
+		Reason: reason,
+	}); err != nil {
+		switch err {
+		case actions.ErrNotLockedDown:
+			return nil, resolvers.ErrUserNotLockedDown
+		case actions.ErrLockdownNotReauthenticated:
+			return nil, resolvers.ErrNotReauthenticated
+		}
+		log.WithError(err).Error("lockdown")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "success",
+	}, nil
+}
+
+// Find the user targeted by a lockdown action, which the actor must outrank
+func getLockdownTarget(ctx context.Context, usr *datastructure.User, id string) (*datastructure.User, error) {
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, resolvers.ErrUnknownUser
+	}
+	if userID == usr.ID {
+		return nil, resolvers.ErrYourself
+	}
+
+	user := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": userID}).Decode(user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownUser
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	role := datastructure.GetRole(user.RoleID)
+	if role.Position >= usr.Role.Position {
+		return nil, resolvers.ErrAccessDenied
+	}
+	return user, nil
+}
@@ -28,5 +28,13 @@ type entitlementCreateInput struct {
 	Subscription *datastructure.EntitledSubscription `json:"subscription"`
 	Badge        *datastructure.EntitledBadge        `json:"badge"`
 	Role         *datastructure.EntitledRole         `json:"role"`
-	EmoteSet     *datastructure.EntitledEmoteSet     `json:"emote_set"`
+	EmoteSet     *entitledEmoteSetInput              `json:"emote_set"`
+	Paint        *datastructure.EntitledPaint        `json:"paint"`
+}
+
+type entitledEmoteSetInput struct {
+	ID         string   `json:"id"`
+	UnicodeTag string   `json:"unicode_tag"`
+	EmoteIDs   []string `json:"emote_ids"`
+	Slots      *int32   `json:"slots"`
 }
@@ -0,0 +1,168 @@
+package mutation_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// CREATE PAINT
+//
+func (*MutationResolver) CreatePaint(ctx context.Context, args struct {
+	Data query_resolvers.PaintInput
+}) (*query_resolvers.PaintResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageCosmetics) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	paint := &datastructure.Paint{
+		ID:      primitive.NewObjectID(),
+		Stops:   []datastructure.PaintStop{},
+		Shadows: []datastructure.PaintShadow{},
+	}
+	args.Data.Apply(paint)
+	if !actions.ValidatePaint(paint) {
+		return nil, resolvers.ErrInvalidPaint
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppPaintCreate,
+		Target: &datastructure.Target{ID: &paint.ID, Type: "paints"},
+		After:  paint,
+		Apply: func(ctx context.Context) error {
+			_, err := mongo.Collection(mongo.CollectionNamePaints).InsertOne(ctx, paint)
+			return err
+		},
+	}); err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	actions.Paints.Invalidate(ctx, paint.ID)
+
+	return query_resolvers.GeneratePaintResolver(ctx, paint, field.Children)
+}
+
+//
+// EDIT PAINT
+//
+func (*MutationResolver) EditPaint(ctx context.Context, args struct {
+	ID   string
+	Data query_resolvers.PaintInput
+}) (*query_resolvers.PaintResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageCosmetics) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownPaint
+	}
+
+	paint := &datastructure.Paint{}
+	if err := mongo.Collection(mongo.CollectionNamePaints).FindOne(ctx, bson.M{"_id": id}).Decode(paint); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownPaint
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	old := *paint
+	args.Data.Apply(paint)
+	if !actions.ValidatePaint(paint) {
+		return nil, resolvers.ErrInvalidPaint
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppPaintEdit,
+		Target: &datastructure.Target{ID: &paint.ID, Type: "paints"},
+		Before: &old,
+		After:  paint,
+		Apply: func(ctx context.Context) error {
+			// Patch the paint as it is now, so that a concurrent edit isn't overwritten
+			if err := mongo.Collection(mongo.CollectionNamePaints).FindOne(ctx, bson.M{"_id": id}).Decode(&old); err != nil {
+				return err
+			}
+			*paint = old
+			args.Data.Apply(paint)
+			if !actions.ValidatePaint(paint) {
+				return resolvers.ErrInvalidPaint
+			}
+
+			_, err := mongo.Collection(mongo.CollectionNamePaints).ReplaceOne(ctx, bson.M{"_id": id}, paint)
+			return err
+		},
+	}); err != nil {
+		switch err {
+		case mongo.ErrNoDocuments:
+			return nil, resolvers.ErrUnknownPaint
+		case resolvers.ErrInvalidPaint:
+			return nil, err
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	actions.Paints.Invalidate(ctx, paint.ID)
+
+	return query_resolvers.GeneratePaintResolver(ctx, paint, field.Children)
+}
+
+//
+// SELECT PAINT
+//
+func (*MutationResolver) SelectPaint(ctx context.Context, args struct {
+	PaintID string
+}) (*response, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	paintID, err := primitive.ObjectIDFromHex(args.PaintID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownPaint
+	}
+
+	if err := actions.Paints.Select(ctx, usr, paintID); err != nil {
+		if err == actions.ErrNotEntitled {
+			return nil, resolvers.ErrNotEntitled
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return &response{
+		OK:      true,
+		Status:  200,
+		Message: "Paint selected",
+	}, nil
+}
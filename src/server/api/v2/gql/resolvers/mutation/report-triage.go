@@ -0,0 +1,507 @@
+package mutation_resolvers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/discord"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//
+// ASSIGN REPORT
+//
+func (*MutationResolver) AssignReport(ctx context.Context, args struct {
+	ID         string
+	AssigneeID *string
+}) (*query_resolvers.ReportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	reportID, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownReport
+	}
+
+	// Moderators assign themselves unless told otherwise
+	assigneeID := usr.ID
+	if args.AssigneeID != nil {
+		if assigneeID, err = primitive.ObjectIDFromHex(*args.AssigneeID); err != nil {
+			return nil, resolvers.ErrUnknownUser
+		}
+
+		assignee := &datastructure.User{}
+		if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": assigneeID}).Decode(assignee); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, resolvers.ErrUnknownUser
+			}
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
+		}
+		if !assignee.HasPermission(datastructure.RolePermissionManageReports) {
+			return nil, resolvers.ErrAccessDenied
+		}
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	before := &datastructure.Report{}
+	report := &datastructure.Report{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeReportAssign,
+		Target: &datastructure.Target{ID: &reportID, Type: "reports"},
+		Before: before,
+		After:  report,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameReports, bson.M{
+				"_id":     reportID,
+				"cleared": false,
+			}, bson.M{
+				"$set": bson.M{
+					"assignee_id": assigneeID,
+					"status":      datastructure.ReportStatusAssigned,
+				},
+			}, before, report)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownReport
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return query_resolvers.GenerateReportResolver(ctx, report, field.Children)
+}
+
+//
+// ADD REPORT NOTE
+//
+func (*MutationResolver) AddReportNote(ctx context.Context, args struct {
+	ID      string
+	Content string
+}) (*query_resolvers.ReportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	if len(args.Content) == 0 || len(args.Content) > 2000 {
+		return nil, resolvers.ErrInvalidUpdate
+	}
+
+	reportID, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownReport
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	report := &datastructure.Report{}
+	if err := mongo.Collection(mongo.CollectionNameReports).FindOneAndUpdate(ctx, bson.M{
+		"_id": reportID,
+	}, bson.M{
+		"$push": bson.M{
+			"notes": &datastructure.ReportNote{
+				AuthorID:  usr.ID,
+				Content:   args.Content,
+				CreatedAt: time.Now(),
+			},
+		},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(report); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownReport
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return query_resolvers.GenerateReportResolver(ctx, report, field.Children)
+}
+
+//
+// RESOLVE REPORT
+//
+func (*MutationResolver) ResolveReport(ctx context.Context, args struct {
+	ID          string
+	Action      string
+	Reason      string
+	BanExpireAt *string
+	BanScope    *int32
+}) (*query_resolvers.ReportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return nil, resolvers.ErrAccessDenied
+	}
+	if args.Reason == "" {
+		return nil, resolvers.ErrNoReason
+	}
+
+	report, err := getOpenReport(ctx, args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the action can be carried out before closing the report
+	var apply func() error
+	switch args.Action {
+	case datastructure.ReportResolutionActionNone:
+	case datastructure.ReportResolutionActionEmoteDelete:
+		if apply, err = reportEmoteDeleteAction(ctx, usr, report, args.Reason); err != nil {
+			return nil, err
+		}
+	case datastructure.ReportResolutionActionUserBan:
+		if apply, err = reportUserBanAction(ctx, usr, report, args.Reason, args.BanExpireAt, args.BanScope); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, resolvers.ErrInvalidReportAction
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	if err := closeReport(ctx, usr, report, datastructure.ReportStatusResolved, args.Action, args.Reason); err != nil {
+		return nil, err
+	}
+
+	if apply != nil {
+		if err := apply(); err != nil {
+			log.WithError(err).WithField("action", args.Action).Error("report resolution")
+
+			// Reopen the report so the action can be retried
+			reopenReport(ctx, usr, report, "The resolution action failed")
+			return nil, resolvers.ErrInternalServer
+		}
+	}
+
+	go notifyReporter(report, true)
+	return query_resolvers.GenerateReportResolver(ctx, report, field.Children)
+}
+
+//
+// REJECT REPORT
+//
+func (*MutationResolver) RejectReport(ctx context.Context, args struct {
+	ID     string
+	Reason string
+}) (*query_resolvers.ReportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return nil, resolvers.ErrAccessDenied
+	}
+	if args.Reason == "" {
+		return nil, resolvers.ErrNoReason
+	}
+
+	report, err := getOpenReport(ctx, args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	if err := closeReport(ctx, usr, report, datastructure.ReportStatusRejected, datastructure.ReportResolutionActionNone, args.Reason); err != nil {
+		return nil, err
+	}
+
+	go notifyReporter(report, false)
+	return query_resolvers.GenerateReportResolver(ctx, report, field.Children)
+}
+
+// Get a report which is yet to be resolved or rejected
+func getOpenReport(ctx context.Context, id string) (*datastructure.Report, error) {
+	reportID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, resolvers.ErrUnknownReport
+	}
+
+	report := &datastructure.Report{}
+	if err := mongo.Collection(mongo.CollectionNameReports).FindOne(ctx, bson.M{
+		"_id":     reportID,
+		"cleared": false,
+	}).Decode(report); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownReport
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if report.Target == nil || report.Target.ID == nil {
+		return nil, resolvers.ErrUnknownReport
+	}
+
+	return report, nil
+}
+
+// Mark a report as resolved or rejected
+func closeReport(ctx context.Context, usr *datastructure.User, report *datastructure.Report, status int32, action string, reason string) error {
+	resolution := &datastructure.ReportResolution{
+		Action:       action,
+		ResolvedByID: usr.ID,
+		ResolvedAt:   time.Now(),
+		Reason:       reason,
+	}
+
+	before := &datastructure.Report{}
+	after := &datastructure.Report{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeReportClear,
+		Target: &datastructure.Target{ID: &report.ID, Type: "reports"},
+		Reason: &reason,
+		Before: before,
+		After:  after,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameReports, bson.M{
+				"_id":     report.ID,
+				"cleared": false,
+			}, bson.M{
+				"$set": bson.M{
+					"cleared":    true,
+					"status":     status,
+					"resolution": resolution,
+				},
+			}, before, after)
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments { // Closed concurrently
+			return resolvers.ErrUnknownReport
+		}
+		log.WithError(err).Error("mongo")
+		return resolvers.ErrInternalServer
+	}
+
+	*report = *after
+	return nil
+}
+
+// Reopen a report which was closed, recording it in the audit log
+func reopenReport(ctx context.Context, usr *datastructure.User, report *datastructure.Report, reason string) {
+	status := utils.Ternary(report.AssigneeID != nil, datastructure.ReportStatusAssigned, datastructure.ReportStatusOpen).(int32)
+	before := &datastructure.Report{}
+	after := &datastructure.Report{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeReportReopen,
+		Target: &datastructure.Target{ID: &report.ID, Type: "reports"},
+		Reason: &reason,
+		Before: before,
+		After:  after,
+		Apply: func(ctx context.Context) error {
+			return actions.Audit.UpdateOne(ctx, mongo.CollectionNameReports, bson.M{"_id": report.ID}, bson.M{
+				"$set":   bson.M{"cleared": false, "status": status},
+				"$unset": bson.M{"resolution": 1},
+			}, before, after)
+		},
+	}); err != nil {
+		log.WithError(err).WithField("report_id", report.ID).Error("mongo, could not reopen the report")
+		return
+	}
+
+	*report = *after
+}
+
+// Prepare the deletion of a reported emote
+func reportEmoteDeleteAction(ctx context.Context, usr *datastructure.User, report *datastructure.Report, reason string) (func() error, error) {
+	if report.Target.Type != "emotes" {
+		return nil, resolvers.ErrInvalidReportAction
+	}
+	if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	emote := &datastructure.Emote{}
+	if err := mongo.Collection(mongo.CollectionNameEmotes).FindOne(ctx, bson.M{
+		"_id":    report.Target.ID,
+		"status": bson.M{"$ne": datastructure.EmoteStatusDeleted},
+	}).Decode(emote); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownEmote
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return func() error {
+		before := &datastructure.Emote{}
+		deleted := &datastructure.Emote{}
+		if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+			Actor:  usr,
+			Type:   datastructure.AuditLogTypeEmoteDelete,
+			Target: &datastructure.Target{ID: &emote.ID, Type: "emotes"},
+			Reason: &reason,
+			Before: before,
+			After:  deleted,
+			Apply: func(ctx context.Context) error {
+				if err := mongo.Collection(mongo.CollectionNameEmotes).FindOne(ctx, bson.M{"_id": emote.ID}).Decode(before); err != nil {
+					return err
+				}
+
+				*deleted = *before
+				deleted.Status = datastructure.EmoteStatusDeleted
+				return actions.Emotes.Delete(ctx, before)
+			},
+			Discord: func(changes []*datastructure.AuditLogChange) {
+				discord.SendEmoteDelete(*emote, *usr, reason)
+			},
+		}); err != nil {
+			return err
+		}
+
+		go func() {
+			if err := actions.Notifications.Create().
+				SetTitle("Emote Deleted").
+				AddTargetUsers(emote.OwnerID).
+				AddTextMessagePart("Your emote ").
+				AddEmoteMentionPart(emote.ID).
+				AddTextMessagePart("was deleted by ").
+				AddUserMentionPart(usr.ID).
+				AddTextMessagePart(fmt.Sprintf("with the reason: \"%v\".", reason)).
+				Write(context.Background()); err != nil {
+				log.WithError(err).Error("failed to create notification")
+			}
+		}()
+
+		return nil
+	}, nil
+}
+
+// Prepare a ban against a reported user, or the owner of a reported emote
+func reportUserBanAction(ctx context.Context, usr *datastructure.User, report *datastructure.Report, reason string, expireAt *string, scope *int32) (func() error, error) {
+	if !usr.HasPermission(datastructure.RolePermissionBanUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	victimID := *report.Target.ID
+	switch report.Target.Type {
+	case "users":
+	case "emotes":
+		emote := &datastructure.Emote{}
+		if err := mongo.Collection(mongo.CollectionNameEmotes).FindOne(ctx, bson.M{"_id": report.Target.ID}).Decode(emote); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, resolvers.ErrUnknownEmote
+			}
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
+		}
+		victimID = emote.OwnerID
+	default:
+		return nil, resolvers.ErrInvalidReportAction
+	}
+
+	if victimID == usr.ID {
+		return nil, resolvers.ErrYourself
+	}
+
+	victim := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": victimID}).Decode(victim); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownUser
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	role := datastructure.GetRole(victim.RoleID)
+	victim.Role = &role
+
+	// Check if actor has a higher role than victim
+	if victim.Role.Position >= usr.Role.Position {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	opts := actions.CreateBanOptions{
+		Actor:    usr,
+		VictimID: victim.ID,
+		Reason:   reason,
+	}
+	if expireAt != nil {
+		opts.ExpireAt, _ = time.Parse("2006-01-02T15:04:05.999Z07:00", *expireAt)
+	}
+	if scope != nil {
+		opts.Scope = int64(*scope)
+		if opts.Scope < 0 || utils.BitField.RemoveBits(opts.Scope, datastructure.BanScopeAll) != 0 {
+			return nil, resolvers.ErrInvalidBanScope
+		}
+	}
+
+	// Check if the victim is banned already
+	banned, err := actions.Bans.IsRestricted(ctx, victim.ID, datastructure.BanScopeAll)
+	if err != nil {
+		log.WithError(err).Error("redis")
+		return nil, resolvers.ErrInternalServer
+	}
+	if banned {
+		return nil, resolvers.ErrUserBanned
+	}
+
+	return func() error {
+		_, err := actions.Bans.Create(ctx, opts)
+		return err
+	}, nil
+}
+
+// Let the reporter know their report was looked at
+func notifyReporter(report *datastructure.Report, resolved bool) {
+	if report.ReporterID == nil {
+		return
+	}
+
+	n := actions.Notifications.Create().
+		SetTitle(utils.Ternary(resolved, "Your Report Was Resolved", "Your Report Was Rejected").(string)).
+		AddTargetUsers(*report.ReporterID).
+		AddTextMessagePart("Your report of ")
+	if report.Target.Type == "emotes" {
+		n = n.AddEmoteMentionPart(*report.Target.ID)
+	} else {
+		n = n.AddUserMentionPart(*report.Target.ID)
+	}
+	switch {
+	case !resolved:
+		n = n.AddTextMessagePart(" was reviewed, but no action was deemed necessary.")
+	case report.Resolution != nil && report.Resolution.Action == datastructure.ReportResolutionActionNone:
+		n = n.AddTextMessagePart(" was reviewed and resolved. Thank you!")
+	default:
+		n = n.AddTextMessagePart(" was reviewed and action was taken. Thank you!")
+	}
+
+	if err := n.Write(context.Background()); err != nil {
+		log.WithError(err).Error("failed to create notification")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
 	"github.com/SevenTV/ServerGo/src/utils"
 	log "github.com/sirupsen/logrus"
@@ -26,6 +27,10 @@ func (*MutationResolver) ReportEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrLoginRequired
 	}
 
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeReport); err != nil {
+		return nil, err
+	}
+
 	id, err := primitive.ObjectIDFromHex(args.EmoteID)
 	if err != nil {
 		return nil, resolvers.ErrUnknownEmote
@@ -51,36 +56,37 @@ func (*MutationResolver) ReportEmote(ctx context.Context, args struct {
 		return nil, resolvers.ErrInternalServer
 	}
 
-	opts := options.Update().SetUpsert(true)
-
-	_, err = mongo.Collection(mongo.CollectionNameReports).UpdateOne(ctx, bson.M{
-		"target.id":   emote.ID,
-		"target.type": "emotes",
-		"cleared":     false,
-		"reporter_id": usr.ID,
-	}, bson.M{
-		"$set": bson.M{
-			"target.id":   emote.ID,
-			"target.type": "emotes",
-			"cleared":     false,
-			"reporter_id": usr.ID,
-			"reason":      args.Reason,
+	// A reporter has one open report per target, which is updated when they report it again
+	report := &datastructure.Report{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeReport,
+		Target: &datastructure.Target{ID: &id, Type: "emotes"},
+		Reason: args.Reason,
+		Before: bson.M{},
+		After:  bson.M{"reports": &report.ID},
+		Apply: func(ctx context.Context) error {
+			return mongo.Collection(mongo.CollectionNameReports).FindOneAndUpdate(ctx, bson.M{
+				"target.id":   emote.ID,
+				"target.type": "emotes",
+				"cleared":     false,
+				"reporter_id": usr.ID,
+			}, bson.M{
+				"$set": bson.M{
+					"target.id":   emote.ID,
+					"target.type": "emotes",
+					"cleared":     false,
+					"reporter_id": usr.ID,
+					"reason":      args.Reason,
+				},
+				"$setOnInsert": bson.M{
+					"status": datastructure.ReportStatusOpen,
+				},
+			}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(report)
 		},
-	}, opts)
-
-	if err != nil {
-		return nil, resolvers.ErrInternalServer
-	}
-
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeReport,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &id, Type: "emotes"},
-		Changes:   nil,
-		Reason:    args.Reason,
-	})
-	if err != nil {
+	}); err != nil {
 		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
 	}
 
 	return &response{
@@ -102,6 +108,10 @@ func (*MutationResolver) ReportUser(ctx context.Context, args struct {
 		return nil, resolvers.ErrLoginRequired
 	}
 
+	if err := checkBanScope(ctx, usr, datastructure.BanScopeReport); err != nil {
+		return nil, err
+	}
+
 	id, err := primitive.ObjectIDFromHex(args.UserID)
 	if err != nil {
 		return nil, resolvers.ErrUnknownUser
@@ -121,7 +131,7 @@ func (*MutationResolver) ReportUser(ctx context.Context, args struct {
 		return nil, resolvers.ErrUserBanned
 	}
 
-	res := mongo.Database.Collection("user").FindOne(ctx, bson.M{
+	res := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{
 		"_id": id,
 	})
 
@@ -141,36 +151,37 @@ func (*MutationResolver) ReportUser(ctx context.Context, args struct {
 		return nil, resolvers.ErrInternalServer
 	}
 
-	opts := options.Update().SetUpsert(true)
-
-	_, err = mongo.Collection(mongo.CollectionNameReports).UpdateOne(ctx, bson.M{
-		"target.id":   user.ID,
-		"target.type": "users",
-		"cleared":     false,
-		"reporter_id": usr.ID,
-	}, bson.M{
-		"$set": bson.M{
-			"target.id":   user.ID,
-			"target.type": "users",
-			"cleared":     false,
-			"reporter_id": usr.ID,
-			"reason":      args.Reason,
+	// A reporter has one open report per target, which is updated when they report it again
+	report := &datastructure.Report{}
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeReport,
+		Target: &datastructure.Target{ID: &id, Type: "users"},
+		Reason: args.Reason,
+		Before: bson.M{},
+		After:  bson.M{"reports": &report.ID},
+		Apply: func(ctx context.Context) error {
+			return mongo.Collection(mongo.CollectionNameReports).FindOneAndUpdate(ctx, bson.M{
+				"target.id":   user.ID,
+				"target.type": "users",
+				"cleared":     false,
+				"reporter_id": usr.ID,
+			}, bson.M{
+				"$set": bson.M{
+					"target.id":   user.ID,
+					"target.type": "users",
+					"cleared":     false,
+					"reporter_id": usr.ID,
+					"reason":      args.Reason,
+				},
+				"$setOnInsert": bson.M{
+					"status": datastructure.ReportStatusOpen,
+				},
+			}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(report)
 		},
-	}, opts)
-
-	if err != nil {
-		return nil, resolvers.ErrInternalServer
-	}
-
-	_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-		Type:      datastructure.AuditLogTypeReport,
-		CreatedBy: usr.ID,
-		Target:    &datastructure.Target{ID: &id, Type: "emotes"},
-		Changes:   nil,
-		Reason:    args.Reason,
-	})
-	if err != nil {
+	}); err != nil {
 		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
 	}
 
 	return &response{
@@ -0,0 +1,136 @@
+package mutation_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	query_resolvers "github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers/query"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//
+// CREATE SUBSCRIPTION PLAN
+//
+func (*MutationResolver) CreateSubscriptionPlan(ctx context.Context, args struct {
+	Data query_resolvers.SubscriptionPlanInput
+}) (*query_resolvers.SubscriptionPlanResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageEntitlements) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	plan := &datastructure.SubscriptionPlan{ID: primitive.NewObjectID()}
+	if err := args.Data.Apply(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppPlanCreate,
+		Target: &datastructure.Target{ID: &plan.ID, Type: string(mongo.CollectionNamePlans)},
+		After:  plan,
+		Apply: func(ctx context.Context) error {
+			_, err := mongo.Collection(mongo.CollectionNamePlans).InsertOne(ctx, plan)
+			return err
+		},
+	}); err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return query_resolvers.GenerateSubscriptionPlanResolver(ctx, plan, field.Children)
+}
+
+//
+// EDIT SUBSCRIPTION PLAN
+//
+func (*MutationResolver) EditSubscriptionPlan(ctx context.Context, args struct {
+	ID   string
+	Data query_resolvers.SubscriptionPlanInput
+}) (*query_resolvers.SubscriptionPlanResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if !usr.HasPermission(datastructure.RolePermissionManageEntitlements) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownPlan
+	}
+
+	plan := &datastructure.SubscriptionPlan{}
+	if err := mongo.Collection(mongo.CollectionNamePlans).FindOne(ctx, bson.M{"_id": id}).Decode(plan); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownPlan
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	old := *plan
+	if err := args.Data.Apply(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	field, failed := query_resolvers.GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	var invalid error
+	if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+		Actor:  usr,
+		Type:   datastructure.AuditLogTypeAppPlanEdit,
+		Target: &datastructure.Target{ID: &plan.ID, Type: string(mongo.CollectionNamePlans)},
+		Before: &old,
+		After:  plan,
+		Apply: func(ctx context.Context) error {
+			// Patch the plan as it is now, so that a concurrent edit isn't overwritten
+			if err := mongo.Collection(mongo.CollectionNamePlans).FindOne(ctx, bson.M{"_id": id}).Decode(&old); err != nil {
+				return err
+			}
+			*plan = old
+			if invalid = args.Data.Apply(ctx, plan); invalid != nil {
+				return invalid
+			}
+
+			_, err := mongo.Collection(mongo.CollectionNamePlans).ReplaceOne(ctx, bson.M{"_id": id}, plan)
+			return err
+		},
+	}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownPlan
+		}
+		if err == invalid {
+			return nil, err
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	// Give the subscribers the plan's perks, in case they changed
+	go func() {
+		if err := actions.Subscriptions.SyncPlan(context.Background(), plan.ID); err != nil {
+			log.WithError(err).Error("subscriptions")
+		}
+	}()
+
+	return query_resolvers.GenerateSubscriptionPlanResolver(ctx, plan, field.Children)
+}
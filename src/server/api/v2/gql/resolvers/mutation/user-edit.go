@@ -14,7 +14,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func (*MutationResolver) EditUser(ctx context.Context, args struct {
@@ -41,7 +40,6 @@ func (*MutationResolver) EditUser(ctx context.Context, args struct {
 	target.Role = &targetRole
 
 	update := bson.M{}
-	logChanges := []*datastructure.AuditLogChange{}
 	notifications := []actions.NotificationBuilder{}
 	req := args.User
 
@@ -60,11 +58,6 @@ func (*MutationResolver) EditUser(ctx context.Context, args struct {
 		// Role is empty?
 		if *req.RoleID == "" {
 			update["role"] = nil
-			logChanges = append(logChanges, &datastructure.AuditLogChange{
-				Key:      "role",
-				OldValue: target.RoleID,
-				NewValue: nil,
-			})
 		} else {
 			// Find role with ID
 			roleID, err := primitive.ObjectIDFromHex(*req.RoleID)
@@ -82,11 +75,6 @@ func (*MutationResolver) EditUser(ctx context.Context, args struct {
 
 			// Update role
 			update["role"] = role.ID
-			logChanges = append(logChanges, &datastructure.AuditLogChange{
-				Key:      "role",
-				OldValue: target.RoleID,
-				NewValue: role.ID,
-			})
 			notifications = append(notifications, actions.Notifications.Create().
 				SetTitle("Role Changed").
 				AddTargetUsers(targetID).
@@ -103,9 +91,12 @@ func (*MutationResolver) EditUser(ctx context.Context, args struct {
 	// Update: Channel Emote Slots
 	if req.EmoteSlots != nil {
 		slots := *req.EmoteSlots
+		if slots < 0 {
+			return nil, resolvers.ErrInvalidUpdate
+		}
 
-		// If amount of slots requested is higher than the configured default:
-		// Check actor can manage users
+		// The override replaces the slots of the default and the user's roles.
+		// If it is higher than the configured default: Check actor can manage users
 		if slots > configure.Config.GetInt32("limits.meta.channel_emote_slots") {
 			if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
 				return nil, resolvers.ErrAccessDenied
@@ -113,11 +104,6 @@ func (*MutationResolver) EditUser(ctx context.Context, args struct {
 		}
 
 		update["emote_slots"] = slots
-		logChanges = append(logChanges, &datastructure.AuditLogChange{
-			Key:      "emote_slots",
-			OldValue: target.EmoteSlots,
-			NewValue: slots,
-		})
 		notifications = append(notifications, actions.Notifications.Create().
 			SetTitle("Maximum Channel Emote Slots Changed").
 			AddTargetUsers(targetID).
@@ -134,29 +120,26 @@ func (*MutationResolver) EditUser(ctx context.Context, args struct {
 		return nil, resolvers.ErrDepth
 	}
 
-	var user *datastructure.User
-	if len(logChanges) > 0 {
-		after := options.After
-		doc := mongo.Collection(mongo.CollectionNameUsers).FindOneAndUpdate(ctx, bson.M{
-			"_id": targetID,
-		}, bson.M{
-			"$set": update,
-		}, &options.FindOneAndUpdateOptions{
-			ReturnDocument: &after,
-		})
-		if err := doc.Decode(&user); err != nil {
-			return nil, resolvers.ErrInternalServer
-		}
-
-		_, err := mongo.Collection(mongo.CollectionNameAudit).InsertOne(ctx, &datastructure.AuditLog{
-			Type:      datastructure.AuditLogTypeUserEdit,
-			CreatedBy: usr.ID,
-			Target:    &datastructure.Target{ID: &targetID, Type: "users"},
-			Changes:   logChanges,
-			Reason:    args.Reason,
-		})
-		if err != nil {
+	if len(update) > 0 {
+		before := &datastructure.User{}
+		user := &datastructure.User{}
+		if _, err := actions.Audit.Mutate(ctx, actions.AuditedMutation{
+			Actor:  usr,
+			Type:   datastructure.AuditLogTypeUserEdit,
+			Target: &datastructure.Target{ID: &targetID, Type: "users"},
+			Reason: args.Reason,
+			Before: before,
+			After:  user,
+			Apply: func(ctx context.Context) error {
+				return actions.Audit.UpdateOne(ctx, mongo.CollectionNameUsers, bson.M{
+					"_id": targetID,
+				}, bson.M{
+					"$set": update,
+				}, before, user)
+			},
+		}); err != nil {
 			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
 		}
 
 		// Send notifications
@@ -6,6 +6,7 @@ import (
 
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
 	"github.com/SevenTV/ServerGo/src/utils"
 	"github.com/hashicorp/go-multierror"
@@ -46,7 +47,7 @@ func (r *auditResolver) ActionUserID() string {
 }
 
 func (r *auditResolver) ActionUser() (*UserResolver, error) {
-	resolver, err := GenerateUserResolver(r.ctx, nil, &r.v.CreatedBy, r.fields)
+	resolver, err := GenerateUserResolver(r.ctx, nil, &r.v.CreatedBy, r.fields["action_user"].Children)
 	if err != nil {
 		return nil, err
 	}
@@ -69,8 +70,8 @@ func (r *auditResolver) Target() (*auditTarget, error) {
 }
 
 func (r *auditResolver) Changes() []*auditChange {
-	changes := make([]*auditChange, len(r.v.Changes))
-	for i, c := range r.v.Changes {
+	changes := make([]*auditChange, 0, len(r.v.Changes))
+	for _, c := range r.v.Changes {
 		// Handle legacy Malformatted logs
 		if skip := shouldSkipLegacyChangeStructure(r.v.Type, c); skip {
 			c.OldValue = nil
@@ -84,13 +85,13 @@ func (r *auditResolver) Changes() []*auditChange {
 			continue
 		}
 
-		changes[i] = &auditChange{
+		changes = append(changes, &auditChange{
 			Key: c.Key,
 			Values: []string{
 				utils.Ternary(c.OldValue != nil, old, "").(string),
 				utils.Ternary(c.NewValue != nil, new, "").(string),
 			},
-		}
+		})
 	}
 
 	return changes
@@ -180,3 +181,65 @@ type auditTargetEmote struct {
 	ID   primitive.ObjectID `bson:"_id" id:"id"`
 	Name string             `bson:"name" json:"name"`
 }
+
+// Parse an optional hex ID argument
+func parseObjectIDArg(s *string) (*primitive.ObjectID, error) {
+	if s == nil {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(*s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// Parse an optional RFC3339 timestamp argument
+func parseTimeArg(s *string) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+type AuditRevertResolver struct {
+	ctx context.Context
+	v   *actions.RevertResult
+
+	fields map[string]*SelectedField
+}
+
+func GenerateAuditRevertResolver(ctx context.Context, result *actions.RevertResult, fields map[string]*SelectedField) (*AuditRevertResolver, error) {
+	return &AuditRevertResolver{
+		ctx:    ctx,
+		v:      result,
+		fields: fields,
+	}, nil
+}
+
+func (r *AuditRevertResolver) Log() (*auditResolver, error) {
+	return GenerateAuditResolver(r.ctx, r.v.Log, r.fields["log"].Children)
+}
+
+func (r *AuditRevertResolver) Reverted() bool {
+	return r.v.Reverted
+}
+
+func (r *AuditRevertResolver) Skipped() *string {
+	if r.v.Skipped == "" {
+		return nil
+	}
+	return &r.v.Skipped
+}
+
+func (r *AuditRevertResolver) Changes() []*auditChange {
+	// The changes are those of the entry the revert would write
+	return (&auditResolver{v: &datastructure.AuditLog{
+		Type:    r.v.Log.Type,
+		Changes: r.v.Changes,
+	}}).Changes()
+}
@@ -0,0 +1,116 @@
+package query_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BadgeResolver struct {
+	ctx context.Context
+	v   *datastructure.Badge
+
+	fields map[string]*SelectedField
+}
+
+func GenerateBadgeResolver(ctx context.Context, badge *datastructure.Badge, fields map[string]*SelectedField) (*BadgeResolver, error) {
+	return &BadgeResolver{
+		ctx:    ctx,
+		v:      badge,
+		fields: fields,
+	}, nil
+}
+
+func (r *BadgeResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *BadgeResolver) Name() string {
+	return r.v.Name
+}
+
+func (r *BadgeResolver) Tooltip() string {
+	return r.v.Tooltip
+}
+
+func (r *BadgeResolver) Misc() bool {
+	return r.v.Misc
+}
+
+func (r *BadgeResolver) Urls() []string {
+	urls := make([]string, 3)
+	for i := range urls {
+		urls[i] = utils.GetBadgeCdnURL(r.v.ID.Hex(), int8(i+1))
+	}
+	return urls
+}
+
+type BadgeInput struct {
+	Name    string
+	Tooltip string
+	Misc    *bool
+}
+
+// Apply the input onto a badge
+func (in *BadgeInput) Apply(badge *datastructure.Badge) {
+	badge.Name = in.Name
+	badge.Tooltip = in.Tooltip
+	if in.Misc != nil {
+		badge.Misc = *in.Misc
+	}
+}
+
+func (*QueryResolver) Badges(ctx context.Context) ([]*BadgeResolver, error) {
+	badges := []*datastructure.Badge{}
+	cur, err := mongo.Collection(mongo.CollectionNameBadges).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err == nil {
+		err = cur.All(ctx, &badges)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*BadgeResolver, len(badges))
+	for i, b := range badges {
+		if result[i], err = GenerateBadgeResolver(ctx, b, field.Children); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// The badge the user displays, out of those they are entitled to
+func (r *UserResolver) Badge() (*BadgeResolver, error) {
+	badgeID, err := actions.Badges.Displayed(r.ctx, r.v.ID)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if badgeID == nil {
+		return nil, nil
+	}
+
+	badge := &datastructure.Badge{}
+	if err := mongo.Collection(mongo.CollectionNameBadges).FindOne(r.ctx, bson.M{"_id": badgeID}).Decode(badge); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return GenerateBadgeResolver(r.ctx, badge, r.fields["badge"].Children)
+}
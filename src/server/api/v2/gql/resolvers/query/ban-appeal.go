@@ -0,0 +1,167 @@
+package query_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BanAppealResolver struct {
+	ctx context.Context
+	v   *datastructure.BanAppeal
+
+	fields map[string]*SelectedField
+}
+
+func GenerateBanAppealResolver(ctx context.Context, appeal *datastructure.BanAppeal, fields map[string]*SelectedField) (*BanAppealResolver, error) {
+	return &BanAppealResolver{
+		ctx:    ctx,
+		v:      appeal,
+		fields: fields,
+	}, nil
+}
+
+// Get the user making a request, including users who are banned.
+// Only appeal endpoints may act on behalf of banned users
+func GetAppealingUser(ctx context.Context) (*datastructure.User, bool) {
+	if usr, ok := ctx.Value(utils.BannedUserKey).(*datastructure.User); ok {
+		return usr, true
+	}
+
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	return usr, ok
+}
+
+func (r *BanAppealResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *BanAppealResolver) BanID() string {
+	return r.v.BanID.Hex()
+}
+
+func (r *BanAppealResolver) User() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.UserID, r.fields["user"].Children)
+}
+
+func (r *BanAppealResolver) Message() string {
+	return r.v.Message
+}
+
+func (r *BanAppealResolver) Status() int32 {
+	return r.v.Status
+}
+
+func (r *BanAppealResolver) Reviewer() (*UserResolver, error) {
+	if r.v.ReviewerID == nil {
+		return nil, nil
+	}
+	return GenerateUserResolver(r.ctx, nil, r.v.ReviewerID, r.fields["reviewer"].Children)
+}
+
+func (r *BanAppealResolver) ReviewerNote() string {
+	return r.v.ReviewerNote
+}
+
+func (r *BanAppealResolver) CreatedAt() string {
+	return r.v.ID.Timestamp().Format(time.RFC3339)
+}
+
+func (r *BanAppealResolver) ReviewedAt() *string {
+	if r.v.ReviewedAt == nil {
+		return nil
+	}
+	s := r.v.ReviewedAt.Format(time.RFC3339)
+	return &s
+}
+
+func (*QueryResolver) BanAppeals(ctx context.Context, args struct {
+	Status *int32
+	Page   *int32
+	Limit  *int32
+}) ([]*BanAppealResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionBanUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	page := int32(1)
+	if args.Page != nil && *args.Page > 1 {
+		page = *args.Page
+	}
+	limit := int32(20)
+	if args.Limit != nil {
+		limit = *args.Limit
+		if limit < 1 || limit > 250 {
+			limit = 250
+		}
+	}
+
+	query := bson.M{}
+	if args.Status != nil {
+		query["status"] = *args.Status
+	}
+
+	appeals := []*datastructure.BanAppeal{}
+	cur, err := mongo.Collection(mongo.CollectionNameBanAppeals).Find(ctx, query, options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)),
+	)
+	if err == nil {
+		err = cur.All(ctx, &appeals)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*BanAppealResolver, len(appeals))
+	for i, a := range appeals {
+		if result[i], err = GenerateBanAppealResolver(ctx, a, field.Children); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (*QueryResolver) ActiveBans(ctx context.Context) ([]*banResolver, error) {
+	usr, ok := GetAppealingUser(ctx)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	bans, err := actions.Bans.GetActive(ctx, usr.ID)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*banResolver, len(bans))
+	for i, b := range bans {
+		if result[i], err = GenerateBanResolver(ctx, b, field.Children); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
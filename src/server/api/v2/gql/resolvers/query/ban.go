@@ -4,7 +4,11 @@ import (
 	"context"
 	"time"
 
+	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type banResolver struct {
@@ -39,7 +43,37 @@ func (r *banResolver) Reason() string {
 }
 
 func (r *banResolver) Active() bool {
-	return r.v.ExpireAt.After(time.Now())
+	return r.v.IsActive()
+}
+
+func (r *banResolver) Scope() int32 {
+	return int32(r.v.Scope)
+}
+
+func (r *banResolver) ExpireAt() *string {
+	if r.v.ExpireAt.IsZero() {
+		return nil
+	}
+	s := r.v.ExpireAt.Format(time.RFC3339)
+	return &s
+}
+
+func (r *banResolver) Appeal() (*BanAppealResolver, error) {
+	usr, ok := GetAppealingUser(r.ctx)
+	if !ok || (r.v.UserID == nil || *r.v.UserID != usr.ID) && !usr.HasPermission(datastructure.RolePermissionBanUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	appeal := &datastructure.BanAppeal{}
+	if err := mongo.Collection(mongo.CollectionNameBanAppeals).FindOne(r.ctx, bson.M{"ban_id": r.v.ID}).Decode(appeal); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return GenerateBanAppealResolver(r.ctx, appeal, r.fields["appeal"].Children)
 }
 
 func (r *banResolver) IssuedByID() *string {
@@ -0,0 +1,118 @@
+package query_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/emoteresolution"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	log "github.com/sirupsen/logrus"
+)
+
+func (r *UserResolver) EffectiveEmotes(args struct{ Precedence *[]string }) (*effectiveEmotesResolver, error) {
+	precedence := actions.EmotePrecedence()
+	if args.Precedence != nil {
+		p, err := emoteresolution.ParsePrecedence(*args.Precedence)
+		if err != nil {
+			return nil, resolvers.ErrInvalidPrecedence
+		}
+		precedence = p
+	}
+
+	result := &actions.ResolvedChannelEmotes{Precedence: precedence}
+	if !r.ub.IsBanned() { // Omit if user is banned
+		resolved, err := actions.Emotes.ResolveChannel(r.ctx, r.v, precedence)
+		if err != nil {
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
+		}
+		result = resolved
+	}
+
+	return &effectiveEmotesResolver{r.ctx, result, r.fields["effective_emotes"].Children}, nil
+}
+
+type effectiveEmotesResolver struct {
+	ctx context.Context
+	v   *actions.ResolvedChannelEmotes
+
+	fields map[string]*SelectedField
+}
+
+func (r *effectiveEmotesResolver) Precedence() []string {
+	return emoteSourceStrings(r.v.Precedence)
+}
+
+func (r *effectiveEmotesResolver) Emotes() []*effectiveEmoteResolver {
+	result := make([]*effectiveEmoteResolver, len(r.v.Emotes))
+	for i, e := range r.v.Emotes {
+		result[i] = &effectiveEmoteResolver{r.ctx, e, r.fields["emotes"].Children}
+	}
+	return result
+}
+
+func (r *effectiveEmotesResolver) Unavailable() []string {
+	return emoteSourceStrings(r.v.Unavailable)
+}
+
+type effectiveEmoteResolver struct {
+	ctx context.Context
+	v   *emoteresolution.ResolvedEmote
+
+	fields map[string]*SelectedField
+}
+
+func (r *effectiveEmoteResolver) Name() string {
+	return r.v.Emote.Name
+}
+
+func (r *effectiveEmoteResolver) Provider() string {
+	return r.v.Source.Provider()
+}
+
+func (r *effectiveEmoteResolver) Source() string {
+	return string(r.v.Source)
+}
+
+func (r *effectiveEmoteResolver) Emote() (*EmoteResolver, error) {
+	return GenerateEmoteResolver(r.ctx, r.v.Emote, nil, r.fields["emote"].Children)
+}
+
+func (r *effectiveEmoteResolver) Shadowed() []*shadowedEmoteResolver {
+	result := make([]*shadowedEmoteResolver, len(r.v.Shadowed))
+	for i := range r.v.Shadowed {
+		result[i] = &shadowedEmoteResolver{r.ctx, &r.v.Shadowed[i], r.fields["shadowed"].Children}
+	}
+	return result
+}
+
+type shadowedEmoteResolver struct {
+	ctx context.Context
+	v   *emoteresolution.ShadowedEmote
+
+	fields map[string]*SelectedField
+}
+
+func (r *shadowedEmoteResolver) Provider() string {
+	return r.v.Source.Provider()
+}
+
+func (r *shadowedEmoteResolver) Source() string {
+	return string(r.v.Source)
+}
+
+func (r *shadowedEmoteResolver) Emote() (*EmoteResolver, error) {
+	return GenerateEmoteResolver(r.ctx, r.v.Emote, nil, r.fields["emote"].Children)
+}
+
+func (r *shadowedEmoteResolver) Overridden() bool {
+	return r.v.Overridden
+}
+
+func emoteSourceStrings(sources []emoteresolution.Source) []string {
+	result := make([]string, len(sources))
+	for i, s := range sources {
+		result[i] = string(s)
+	}
+	return result
+}
@@ -0,0 +1,164 @@
+package query_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The restore points of the channel, the most recent first
+func (r *UserResolver) RestorePoints() ([]*restorePointResolver, error) {
+	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if _, isEditor := r.v.GetEditorPermissions(u.ID); !isEditor && u.ID != r.v.ID && !u.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	snapshots, err := actions.Users.RestorePoints(r.ctx, r.v.ID)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	result := make([]*restorePointResolver, len(snapshots))
+	for i, s := range snapshots {
+		result[i] = &restorePointResolver{r.ctx, s, r.fields["restore_points"].Children}
+	}
+	return result, nil
+}
+
+type restorePointResolver struct {
+	ctx context.Context
+	v   *datastructure.ChannelSnapshot
+
+	fields map[string]*SelectedField
+}
+
+func (r *restorePointResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *restorePointResolver) Reason() string {
+	return r.v.Reason
+}
+
+func (r *restorePointResolver) CreatedBy() (*UserResolver, error) {
+	if r.v.CreatedBy == nil {
+		return nil, nil
+	}
+
+	return GenerateUserResolver(r.ctx, nil, r.v.CreatedBy, r.fields["created_by"].Children)
+}
+
+func (r *restorePointResolver) CreatedAt() string {
+	return r.v.CreatedAt.Format(time.RFC3339)
+}
+
+func (r *restorePointResolver) Emotes() []*snapshotEmoteResolver {
+	result := make([]*snapshotEmoteResolver, len(r.v.Emotes))
+	for i := range r.v.Emotes {
+		result[i] = &snapshotEmoteResolver{&r.v.Emotes[i]}
+	}
+	return result
+}
+
+func (r *restorePointResolver) EditorCount() int32 {
+	return int32(len(r.v.Editors))
+}
+
+type snapshotEmoteResolver struct {
+	v *datastructure.ChannelSnapshotEmote
+}
+
+func (r *snapshotEmoteResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *snapshotEmoteResolver) Name() string {
+	return r.v.Name
+}
+
+func (r *snapshotEmoteResolver) Alias() *string {
+	if r.v.Alias == "" {
+		return nil
+	}
+	return &r.v.Alias
+}
+
+type SnapshotImportResolver struct {
+	ctx context.Context
+	v   *actions.SnapshotImport
+
+	fields map[string]*SelectedField
+}
+
+func GenerateSnapshotImportResolver(ctx context.Context, result *actions.SnapshotImport, fields map[string]*SelectedField) *SnapshotImportResolver {
+	return &SnapshotImportResolver{ctx, result, fields}
+}
+
+func (r *SnapshotImportResolver) Channel() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, r.v.Channel, &r.v.Channel.ID, r.fields["channel"].Children)
+}
+
+func (r *SnapshotImportResolver) Added() []string {
+	return hexIDs(r.v.Added)
+}
+
+func (r *SnapshotImportResolver) Removed() []string {
+	return hexIDs(r.v.Removed)
+}
+
+func (r *SnapshotImportResolver) Renamed() []string {
+	return hexIDs(r.v.Renamed)
+}
+
+func (r *SnapshotImportResolver) EditorsUpdated() []string {
+	return hexIDs(r.v.EditorsUpdated)
+}
+
+func (r *SnapshotImportResolver) EditorsRemoved() []string {
+	return hexIDs(r.v.EditorsRemoved)
+}
+
+func (r *SnapshotImportResolver) Conflicts() []*snapshotConflictResolver {
+	result := make([]*snapshotConflictResolver, len(r.v.Conflicts))
+	for i, c := range r.v.Conflicts {
+		result[i] = &snapshotConflictResolver{c}
+	}
+	return result
+}
+
+type snapshotConflictResolver struct {
+	v *actions.SnapshotConflict
+}
+
+func (r *snapshotConflictResolver) Kind() string {
+	return string(r.v.Kind)
+}
+
+func (r *snapshotConflictResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *snapshotConflictResolver) Name() *string {
+	if r.v.Name == "" {
+		return nil
+	}
+	return &r.v.Name
+}
+
+func hexIDs(ids []primitive.ObjectID) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = id.Hex()
+	}
+	return result
+}
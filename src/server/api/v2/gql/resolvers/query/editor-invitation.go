@@ -0,0 +1,47 @@
+package query_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+)
+
+type editorInvitationResolver struct {
+	ctx context.Context
+	v   *datastructure.EditorInvitation
+
+	fields map[string]*SelectedField
+}
+
+func GenerateEditorInvitationResolver(ctx context.Context, invitation *datastructure.EditorInvitation, fields map[string]*SelectedField) (*editorInvitationResolver, error) {
+	return &editorInvitationResolver{
+		ctx:    ctx,
+		v:      invitation,
+		fields: fields,
+	}, nil
+}
+
+func (r *editorInvitationResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *editorInvitationResolver) Channel() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.ChannelID, r.fields["channel"].Children)
+}
+
+func (r *editorInvitationResolver) Editor() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.EditorID, r.fields["editor"].Children)
+}
+
+func (r *editorInvitationResolver) InvitedBy() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.InvitedByID, r.fields["invited_by"].Children)
+}
+
+func (r *editorInvitationResolver) Permissions() int32 {
+	return int32(r.v.Permissions)
+}
+
+func (r *editorInvitationResolver) ExpireAt() string {
+	return r.v.ExpireAt.Format(time.RFC3339)
+}
@@ -0,0 +1,187 @@
+package query_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (*QueryResolver) EmoteImport(ctx context.Context, args struct{ ID string }) (*EmoteImportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+
+	id, err := primitive.ObjectIDFromHex(args.ID)
+	if err != nil {
+		return nil, resolvers.ErrUnknownEmoteImport
+	}
+	imp, err := actions.Emotes.Import(ctx, id)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if imp == nil {
+		return nil, resolvers.ErrUnknownEmoteImport
+	}
+
+	channel := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(ctx, bson.M{"_id": imp.ChannelID}).Decode(channel); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, resolvers.ErrUnknownEmoteImport
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if _, isEditor := channel.GetEditorPermissions(usr.ID); !isEditor && usr.ID != channel.ID && !usr.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, resolvers.ErrUnknownEmoteImport
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	return GenerateEmoteImportResolver(ctx, imp, field.Children), nil
+}
+
+// The most recent emote imports of the channel
+func (r *UserResolver) EmoteImports() ([]*EmoteImportResolver, error) {
+	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok {
+		return nil, resolvers.ErrLoginRequired
+	}
+	if _, isEditor := r.v.GetEditorPermissions(u.ID); !isEditor && u.ID != r.v.ID && !u.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	imports, err := actions.Emotes.Imports(r.ctx, r.v.ID)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	result := make([]*EmoteImportResolver, len(imports))
+	for i, imp := range imports {
+		result[i] = GenerateEmoteImportResolver(r.ctx, imp, r.fields["emote_imports"].Children)
+	}
+	return result, nil
+}
+
+type EmoteImportResolver struct {
+	ctx context.Context
+	v   *datastructure.EmoteImport
+
+	fields map[string]*SelectedField
+}
+
+func GenerateEmoteImportResolver(ctx context.Context, imp *datastructure.EmoteImport, fields map[string]*SelectedField) *EmoteImportResolver {
+	return &EmoteImportResolver{ctx, imp, fields}
+}
+
+func (r *EmoteImportResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *EmoteImportResolver) Channel() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.ChannelID, r.fields["channel"].Children)
+}
+
+func (r *EmoteImportResolver) CreatedBy() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.CreatedBy, r.fields["created_by"].Children)
+}
+
+func (r *EmoteImportResolver) Providers() []string {
+	return r.v.Providers
+}
+
+func (r *EmoteImportResolver) Status() string {
+	return string(r.v.Status)
+}
+
+func (r *EmoteImportResolver) Error() *string {
+	if r.v.Error == "" {
+		return nil
+	}
+	return &r.v.Error
+}
+
+func (r *EmoteImportResolver) CreatedAt() string {
+	return r.v.CreatedAt.Format(time.RFC3339)
+}
+
+func (r *EmoteImportResolver) Items() []*emoteImportItemResolver {
+	result := make([]*emoteImportItemResolver, len(r.v.Items))
+	for i := range r.v.Items {
+		result[i] = &emoteImportItemResolver{r.ctx, &r.v.Items[i], r.fields["items"].Children}
+	}
+	return result
+}
+
+type emoteImportItemResolver struct {
+	ctx context.Context
+	v   *datastructure.EmoteImportItem
+
+	fields map[string]*SelectedField
+}
+
+func (r *emoteImportItemResolver) Provider() string {
+	return r.v.Provider
+}
+
+func (r *emoteImportItemResolver) ProviderID() string {
+	return r.v.ProviderID
+}
+
+func (r *emoteImportItemResolver) Name() string {
+	return r.v.Name
+}
+
+func (r *emoteImportItemResolver) ImageURL() string {
+	return r.v.ImageURL
+}
+
+func (r *emoteImportItemResolver) AuthorLogin() *string {
+	if r.v.AuthorLogin == "" {
+		return nil
+	}
+	return &r.v.AuthorLogin
+}
+
+func (r *emoteImportItemResolver) AuthorDisplayName() *string {
+	if r.v.AuthorDisplayName == "" {
+		return nil
+	}
+	return &r.v.AuthorDisplayName
+}
+
+func (r *emoteImportItemResolver) Proposal() string {
+	return string(r.v.Proposal)
+}
+
+func (r *emoteImportItemResolver) Emote() (*EmoteResolver, error) {
+	if r.v.EmoteID == nil {
+		return nil, nil
+	}
+
+	return GenerateEmoteResolver(r.ctx, nil, r.v.EmoteID, r.fields["emote"].Children)
+}
+
+func (r *emoteImportItemResolver) Similarity() *float64 {
+	if r.v.EmoteID == nil {
+		return nil
+	}
+	return &r.v.Similarity
+}
+
+func (r *emoteImportItemResolver) Accepted() bool {
+	return r.v.Accepted
+}
@@ -0,0 +1,66 @@
+package query_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (*QueryResolver) PendingEmotes(ctx context.Context, args struct {
+	Page  *int32
+	Limit *int32
+}) ([]*EmoteResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	page := int32(1)
+	if args.Page != nil && *args.Page > 1 {
+		page = *args.Page
+	}
+	limit := int32(20)
+	if args.Limit != nil {
+		limit = *args.Limit
+		if limit < 1 || limit > 250 {
+			limit = 250
+		}
+	}
+
+	// Oldest uploads are reviewed first
+	emotes := []*datastructure.Emote{}
+	cur, err := mongo.Collection(mongo.CollectionNameEmotes).Find(ctx, bson.M{
+		"status": datastructure.EmoteStatusPending,
+	}, options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)),
+	)
+	if err == nil {
+		err = cur.All(ctx, &emotes)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*EmoteResolver, len(emotes))
+	for i, e := range emotes {
+		if result[i], err = GenerateEmoteResolver(ctx, e, nil, field.Children); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -77,7 +77,7 @@ func GenerateEmoteResolver(ctx context.Context, emote *datastructure.Emote, emot
 	}
 
 	usr, usrValid := ctx.Value(utils.UserKey).(*datastructure.User)
-	if v, ok := fields["reports"]; ok && usrValid && (usr.Rank != datastructure.UserRankAdmin && usr.Rank != datastructure.UserRankModerator) && emote.Reports == nil {
+	if v, ok := fields["reports"]; ok && usrValid && usr.HasPermission(datastructure.RolePermissionManageReports) && emote.Reports == nil {
 		emote.Reports = &[]*datastructure.Report{}
 		if err := cache.Find(ctx, "reports", fmt.Sprintf("reports:%s", emote.ID.Hex()), bson.M{
 			"target.id":   emote.ID,
@@ -320,9 +320,9 @@ func (r *EmoteResolver) ChannelCount() int32 {
 	return *r.v.ChannelCount
 }
 
-func (r *EmoteResolver) Reports() (*[]*reportResolver, error) {
+func (r *EmoteResolver) Reports() (*[]*ReportResolver, error) {
 	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
-	if !ok || (u.Rank != datastructure.UserRankAdmin && u.Rank != datastructure.UserRankModerator) {
+	if !ok || !u.HasPermission(datastructure.RolePermissionManageReports) {
 		return nil, resolvers.ErrAccessDenied
 	}
 
@@ -331,7 +331,7 @@ func (r *EmoteResolver) Reports() (*[]*reportResolver, error) {
 	}
 
 	e := *r.v.Reports
-	reports := make([]*reportResolver, len(e))
+	reports := make([]*ReportResolver, len(e))
 	var err error
 	for i, l := range e {
 		reports[i], err = GenerateReportResolver(r.ctx, l, r.fields["reports"].Children)
@@ -387,3 +387,39 @@ func (r *EmoteResolver) Height() []int32 {
 
 	return result
 }
+
+// The origin of an emote re-hosted from another provider
+func (r *EmoteResolver) Attribution() *emoteAttributionResolver {
+	if r.v.Attribution == nil {
+		return nil
+	}
+
+	return &emoteAttributionResolver{r.v.Attribution}
+}
+
+type emoteAttributionResolver struct {
+	v *datastructure.EmoteAttribution
+}
+
+func (r *emoteAttributionResolver) Provider() string {
+	return r.v.Provider
+}
+
+func (r *emoteAttributionResolver) ProviderID() string {
+	return r.v.ProviderID
+}
+
+func (r *emoteAttributionResolver) AuthorLogin() string {
+	return r.v.AuthorLogin
+}
+
+func (r *emoteAttributionResolver) AuthorDisplayName() *string {
+	if r.v.AuthorDisplayName == "" {
+		return nil
+	}
+	return &r.v.AuthorDisplayName
+}
+
+func (r *emoteAttributionResolver) ImportedAt() string {
+	return r.v.ImportedAt.Format(time.RFC3339)
+}
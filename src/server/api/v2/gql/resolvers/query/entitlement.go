@@ -0,0 +1,214 @@
+package query_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type EntitlementResolver struct {
+	ctx context.Context
+	v   *datastructure.Entitlement
+
+	fields map[string]*SelectedField
+}
+
+func GenerateEntitlementResolver(ctx context.Context, ent *datastructure.Entitlement, fields map[string]*SelectedField) (*EntitlementResolver, error) {
+	return &EntitlementResolver{
+		ctx:    ctx,
+		v:      ent,
+		fields: fields,
+	}, nil
+}
+
+func (r *EntitlementResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *EntitlementResolver) Kind() string {
+	return string(r.v.Kind)
+}
+
+func (r *EntitlementResolver) UserID() string {
+	return r.v.UserID.Hex()
+}
+
+func (r *EntitlementResolver) User() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.UserID, r.fields["user"].Children)
+}
+
+// The ID of the entitled item
+func (r *EntitlementResolver) RefID() *string {
+	v, err := r.v.Data.LookupErr("ref")
+	if err != nil {
+		return nil
+	}
+	id, ok := v.ObjectIDOK()
+	if !ok {
+		return nil
+	}
+	hex := id.Hex()
+	return &hex
+}
+
+func (r *EntitlementResolver) Data() string {
+	if len(r.v.Data) == 0 {
+		return "{}"
+	}
+	b, err := bson.MarshalExtJSON(r.v.Data, false, false)
+	if err != nil {
+		log.WithError(err).Error("bson")
+		return "{}"
+	}
+	return string(b)
+}
+
+func (r *EntitlementResolver) Active() bool {
+	return r.v.IsActive(time.Now())
+}
+
+func (r *EntitlementResolver) Disabled() bool {
+	return r.v.Disabled
+}
+
+func (r *EntitlementResolver) Expired() bool {
+	return r.v.Expired
+}
+
+func (r *EntitlementResolver) StartsAt() *string {
+	if r.v.StartsAt == nil {
+		return nil
+	}
+	s := r.v.StartsAt.Format(time.RFC3339)
+	return &s
+}
+
+func (r *EntitlementResolver) EndsAt() *string {
+	if r.v.EndsAt == nil {
+		return nil
+	}
+	s := r.v.EndsAt.Format(time.RFC3339)
+	return &s
+}
+
+// The most entitlements returned by a single query
+const MAX_ENTITLEMENT_QUERY_SIZE = 250
+
+// Find entitlements by kind, entitled item or user, most recent first. Requires permission
+func (*QueryResolver) Entitlements(ctx context.Context, args struct {
+	Kind   *datastructure.EntitlementKind
+	RefID  *string
+	UserID *string
+	Active *bool
+	Limit  *int32
+	Cursor *string
+}) ([]*EntitlementResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionManageEntitlements) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	filter := bson.M{}
+	if args.Active != nil && *args.Active {
+		filter = actions.ActiveEntitlementFilter()
+	}
+	if args.Kind != nil {
+		filter["kind"] = *args.Kind
+	}
+
+	var err error
+	var refID, userID, cursor *primitive.ObjectID
+	if refID, err = parseObjectIDArg(args.RefID); err != nil {
+		return nil, resolvers.ErrInvalidUpdate
+	}
+	if userID, err = parseObjectIDArg(args.UserID); err != nil {
+		return nil, resolvers.ErrUnknownUser
+	}
+	if cursor, err = parseObjectIDArg(args.Cursor); err != nil {
+		return nil, resolvers.ErrInvalidUpdate
+	}
+	if refID != nil {
+		filter["data.ref"] = *refID
+	}
+	if userID != nil {
+		filter["user_id"] = *userID
+	}
+	if cursor != nil {
+		filter["_id"] = bson.M{"$lt": *cursor}
+	}
+
+	var limit int64 = MAX_ENTITLEMENT_QUERY_SIZE
+	if args.Limit != nil && *args.Limit > 0 && *args.Limit < MAX_ENTITLEMENT_QUERY_SIZE {
+		limit = int64(*args.Limit)
+	}
+
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}).SetLimit(limit))
+	if err == nil {
+		err = cur.All(ctx, &ents)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*EntitlementResolver, len(ents))
+	for i, e := range ents {
+		if result[i], err = GenerateEntitlementResolver(ctx, e, field.Children); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Get the user's entitlements. Available to the user and to those with permission
+func (r *UserResolver) Entitlements(args struct {
+	Kind            *datastructure.EntitlementKind
+	IncludeInactive *bool
+}) ([]*EntitlementResolver, error) {
+	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || (u.ID != r.v.ID && !u.HasPermission(datastructure.RolePermissionManageEntitlements)) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	filter := bson.M{}
+	if args.IncludeInactive == nil || !*args.IncludeInactive {
+		filter = actions.ActiveEntitlementFilter()
+	}
+	filter["user_id"] = r.v.ID
+	if args.Kind != nil {
+		filter["kind"] = *args.Kind
+	}
+
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(r.ctx, filter, options.Find().SetSort(bson.M{"_id": -1}))
+	if err == nil {
+		err = cur.All(r.ctx, &ents)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	result := make([]*EntitlementResolver, len(ents))
+	for i, e := range ents {
+		if result[i], err = GenerateEntitlementResolver(r.ctx, e, r.fields["entitlements"].Children); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
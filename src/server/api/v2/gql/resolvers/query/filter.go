@@ -0,0 +1,208 @@
+package query_resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FilterRuleResolver struct {
+	ctx context.Context
+	v   *datastructure.FilterRule
+
+	fields map[string]*SelectedField
+}
+
+func GenerateFilterRuleResolver(ctx context.Context, rule *datastructure.FilterRule, fields map[string]*SelectedField) (*FilterRuleResolver, error) {
+	return &FilterRuleResolver{
+		ctx:    ctx,
+		v:      rule,
+		fields: fields,
+	}, nil
+}
+
+func (r *FilterRuleResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *FilterRuleResolver) Kind() string {
+	return r.v.Kind
+}
+
+func (r *FilterRuleResolver) Pattern() string {
+	return r.v.Pattern
+}
+
+func (r *FilterRuleResolver) Action() int32 {
+	return r.v.Action
+}
+
+func (r *FilterRuleResolver) Normalize() bool {
+	return r.v.Normalize
+}
+
+func (r *FilterRuleResolver) Enabled() bool {
+	return r.v.Enabled
+}
+
+func (r *FilterRuleResolver) Note() string {
+	return r.v.Note
+}
+
+func (r *FilterRuleResolver) CreatedBy() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.CreatedByID, r.fields["created_by"].Children)
+}
+
+func (r *FilterRuleResolver) CreatedAt() string {
+	return r.v.ID.Timestamp().Format(time.RFC3339)
+}
+
+type filterTestResolver struct {
+	ctx   context.Context
+	match *actions.FilterMatch
+
+	fields map[string]*SelectedField
+}
+
+func (r *filterTestResolver) Matched() bool {
+	return r.match != nil
+}
+
+func (r *filterTestResolver) Action() int32 {
+	if r.match == nil {
+		return datastructure.FilterActionNone
+	}
+	return r.match.Rule.Action
+}
+
+func (r *filterTestResolver) Value() *string {
+	if r.match == nil {
+		return nil
+	}
+	return &r.match.Value
+}
+
+func (r *filterTestResolver) Rule() (*FilterRuleResolver, error) {
+	if r.match == nil {
+		return nil, nil
+	}
+	return GenerateFilterRuleResolver(r.ctx, r.match.Rule, r.fields["rule"].Children)
+}
+
+type FilterRuleInput struct {
+	Kind      string
+	Pattern   string
+	Action    *int32
+	Normalize *bool
+	Enabled   *bool
+	Note      *string
+}
+
+func (*QueryResolver) FilterRules(ctx context.Context) ([]*FilterRuleResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionManageFilters) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	rules := []*datastructure.FilterRule{}
+	cur, err := mongo.Collection(mongo.CollectionNameFilterRules).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err == nil {
+		err = cur.All(ctx, &rules)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*FilterRuleResolver, len(rules))
+	for i, r := range rules {
+		if result[i], err = GenerateFilterRuleResolver(ctx, r, field.Children); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Dry-run the content filter, either against the enabled rules or a rule which is yet to be saved
+func (*QueryResolver) TestFilter(ctx context.Context, args struct {
+	Values []string
+	Rule   *FilterRuleInput
+}) (*filterTestResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionManageFilters) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	rules := []*datastructure.FilterRule{}
+	if args.Rule != nil {
+		rule := args.Rule.ToFilterRule()
+		if !actions.ValidateFilterRule(rule) {
+			return nil, resolvers.ErrInvalidFilterRule
+		}
+		rules = append(rules, rule)
+	} else {
+		cur, err := mongo.Collection(mongo.CollectionNameFilterRules).Find(ctx, bson.M{"enabled": true})
+		if err == nil {
+			err = cur.All(ctx, &rules)
+		}
+		if err != nil {
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
+		}
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	return &filterTestResolver{
+		ctx:    ctx,
+		match:  actions.CompileFilterRules(rules).Match(args.Values...),
+		fields: field.Children,
+	}, nil
+}
+
+// Create a rule from the input, applying defaults for omitted fields
+func (in *FilterRuleInput) ToFilterRule() *datastructure.FilterRule {
+	rule := &datastructure.FilterRule{
+		Kind:    in.Kind,
+		Pattern: in.Pattern,
+		Action:  datastructure.FilterActionReject,
+		Enabled: true,
+	}
+	in.Apply(rule)
+	return rule
+}
+
+// Apply the input onto an existing rule
+func (in *FilterRuleInput) Apply(rule *datastructure.FilterRule) {
+	rule.Kind = in.Kind
+	rule.Pattern = in.Pattern
+	if in.Action != nil {
+		rule.Action = *in.Action
+	}
+	if in.Normalize != nil {
+		rule.Normalize = *in.Normalize
+	}
+	if in.Enabled != nil {
+		rule.Enabled = *in.Enabled
+	}
+	if in.Note != nil {
+		rule.Note = *in.Note
+	}
+}
@@ -0,0 +1,208 @@
+package query_resolvers
+
+import (
+	"context"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PaintResolver struct {
+	ctx context.Context
+	v   *datastructure.Paint
+
+	fields map[string]*SelectedField
+}
+
+func GeneratePaintResolver(ctx context.Context, paint *datastructure.Paint, fields map[string]*SelectedField) (*PaintResolver, error) {
+	return &PaintResolver{
+		ctx:    ctx,
+		v:      paint,
+		fields: fields,
+	}, nil
+}
+
+func (r *PaintResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *PaintResolver) Name() string {
+	return r.v.Name
+}
+
+func (r *PaintResolver) Function() string {
+	return string(r.v.Function)
+}
+
+func (r *PaintResolver) Color() *int32 {
+	return r.v.Color
+}
+
+func (r *PaintResolver) Stops() []*paintStopResolver {
+	result := make([]*paintStopResolver, len(r.v.Stops))
+	for i := range r.v.Stops {
+		result[i] = &paintStopResolver{&r.v.Stops[i]}
+	}
+	return result
+}
+
+func (r *PaintResolver) Angle() int32 {
+	return r.v.Angle
+}
+
+func (r *PaintResolver) Repeat() bool {
+	return r.v.Repeat
+}
+
+func (r *PaintResolver) ImageURL() *string {
+	if r.v.ImageURL == "" {
+		return nil
+	}
+	return &r.v.ImageURL
+}
+
+func (r *PaintResolver) Shadows() []*paintShadowResolver {
+	result := make([]*paintShadowResolver, len(r.v.Shadows))
+	for i := range r.v.Shadows {
+		result[i] = &paintShadowResolver{&r.v.Shadows[i]}
+	}
+	return result
+}
+
+type paintStopResolver struct {
+	v *datastructure.PaintStop
+}
+
+func (r *paintStopResolver) At() float64 {
+	return r.v.At
+}
+
+func (r *paintStopResolver) Color() int32 {
+	return r.v.Color
+}
+
+type paintShadowResolver struct {
+	v *datastructure.PaintShadow
+}
+
+func (r *paintShadowResolver) XOffset() float64 {
+	return r.v.OffsetX
+}
+
+func (r *paintShadowResolver) YOffset() float64 {
+	return r.v.OffsetY
+}
+
+func (r *paintShadowResolver) Radius() float64 {
+	return r.v.Radius
+}
+
+func (r *paintShadowResolver) Color() int32 {
+	return r.v.Color
+}
+
+type PaintInput struct {
+	Name     string
+	Function string
+	Color    *int32
+	Stops    *[]PaintStopInput
+	Angle    *int32
+	Repeat   *bool
+	ImageURL *string
+	Shadows  *[]PaintShadowInput
+}
+
+type PaintStopInput struct {
+	At    float64
+	Color int32
+}
+
+type PaintShadowInput struct {
+	XOffset float64
+	YOffset float64
+	Radius  float64
+	Color   int32
+}
+
+// Apply the input onto a paint
+func (in *PaintInput) Apply(paint *datastructure.Paint) {
+	paint.Name = in.Name
+	paint.Function = datastructure.PaintFunction(in.Function)
+	if in.Color != nil {
+		paint.Color = in.Color
+	}
+	if in.Stops != nil {
+		paint.Stops = make([]datastructure.PaintStop, len(*in.Stops))
+		for i, s := range *in.Stops {
+			paint.Stops[i] = datastructure.PaintStop{At: s.At, Color: s.Color}
+		}
+	}
+	if in.Angle != nil {
+		paint.Angle = *in.Angle
+	}
+	if in.Repeat != nil {
+		paint.Repeat = *in.Repeat
+	}
+	if in.ImageURL != nil {
+		paint.ImageURL = *in.ImageURL
+	}
+	if in.Shadows != nil {
+		paint.Shadows = make([]datastructure.PaintShadow, len(*in.Shadows))
+		for i, s := range *in.Shadows {
+			paint.Shadows[i] = datastructure.PaintShadow{OffsetX: s.XOffset, OffsetY: s.YOffset, Radius: s.Radius, Color: s.Color}
+		}
+	}
+}
+
+func (*QueryResolver) Paints(ctx context.Context) ([]*PaintResolver, error) {
+	paints := []*datastructure.Paint{}
+	cur, err := mongo.Collection(mongo.CollectionNamePaints).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err == nil {
+		err = cur.All(ctx, &paints)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*PaintResolver, len(paints))
+	for i, p := range paints {
+		if result[i], err = GeneratePaintResolver(ctx, p, field.Children); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// The paint the user displays, out of those they are entitled to
+func (r *UserResolver) Paint() (*PaintResolver, error) {
+	paintID, err := actions.Paints.Displayed(r.ctx, r.v.ID)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	if paintID == nil {
+		return nil, nil
+	}
+
+	paint := &datastructure.Paint{}
+	if err := mongo.Collection(mongo.CollectionNamePaints).FindOne(r.ctx, bson.M{"_id": paintID}).Decode(paint); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return GeneratePaintResolver(r.ctx, paint, r.fields["paint"].Children)
+}
@@ -12,6 +12,7 @@ import (
 	mongocache "github.com/SevenTV/ServerGo/src/mongo/cache"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
 	api_proxy "github.com/SevenTV/ServerGo/src/server/api/v2/proxy"
 	"github.com/SevenTV/ServerGo/src/utils"
@@ -68,57 +69,99 @@ func GenerateSelectedFieldMap(ctx context.Context, max int) (*SelectedField, boo
 }
 
 func (*QueryResolver) AuditLogs(ctx context.Context, args struct {
-	Page  int32
-	Limit *int32
-	Types *[]int32
+	Page       *int32
+	Limit      *int32
+	Types      *[]int32
+	ActorID    *string
+	TargetID   *string
+	TargetType *string
+	TypeMin    *int32
+	TypeMax    *int32
+	After      *string
+	Before     *string
+	Reason     *string
+	Cursor     *string
 }) ([]*auditResolver, error) {
-	var logs []*datastructure.AuditLog
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return nil, resolvers.ErrAccessDenied
+	}
 
-	// Find audit logs
 	var limit int32 = 150
 	if args.Limit != nil {
 		limit = *args.Limit
+		if limit < 1 {
+			limit = 1
+		} else if limit > 250 {
+			limit = 250
+		}
+	}
+
+	q := actions.AuditQuery{
+		Limit:   int64(limit),
+		TypeMin: args.TypeMin,
+		TypeMax: args.TypeMax,
+	}
+	if args.Types != nil {
+		q.Types = *args.Types
+	}
+	if args.TargetType != nil {
+		q.TargetType = *args.TargetType
+	}
+	if args.Reason != nil {
+		q.Reason = *args.Reason
+	}
+
+	var err error
+	if q.ActorID, err = parseObjectIDArg(args.ActorID); err != nil {
+		return nil, resolvers.ErrUnknownUser
+	}
+	if q.TargetID, err = parseObjectIDArg(args.TargetID); err != nil {
+		return nil, resolvers.ErrInvalidUpdate
+	}
+	if q.Cursor, err = parseObjectIDArg(args.Cursor); err != nil {
+		return nil, resolvers.ErrInvalidUpdate
+	}
+	if q.After, err = parseTimeArg(args.After); err != nil {
+		return nil, resolvers.ErrInvalidTimestamp
+	}
+	if q.Before, err = parseTimeArg(args.Before); err != nil {
+		return nil, resolvers.ErrInvalidTimestamp
 	}
 
-	query := bson.M{}
-	if args.Types != nil && len(*args.Types) > 0 {
-		query["type"] = bson.M{
-			"$in": *args.Types,
+	// Pages are only used when not paginating by cursor
+	if q.Cursor == nil && args.Page != nil && *args.Page > 1 {
+		q.Skip = int64(*args.Page-1) * q.Limit
+	}
+
+	// A view is recorded once, rather than for every page
+	if q.Cursor == nil && q.Skip == 0 {
+		if err := actions.Audit.RecordView(ctx, usr, q, "query"); err != nil {
+			log.WithError(err).Error("mongo")
+			return nil, resolvers.ErrInternalServer
 		}
 	}
-	fmt.Println("query:", query)
 
-	if err := cache.Find(ctx, "audit", "", query, &logs, &options.FindOptions{
-		Limit: utils.Int64Pointer(int64(math.Min(250, float64(limit)))),
-		Sort: bson.M{
-			"_id": -1,
-		},
-	}); err != nil {
+	logs, err := actions.Audit.Find(ctx, q)
+	if err != nil {
 		log.WithError(err).Error("mongo")
-		return nil, err
+		return nil, resolvers.ErrInternalServer
 	}
-	fmt.Println("logs", logs)
 
 	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
 	if failed {
 		return nil, resolvers.ErrDepth
 	}
 
-	resolvers := make([]*auditResolver, len(logs))
+	result := make([]*auditResolver, len(logs))
 	for i, l := range logs {
-		resolver, err := GenerateAuditResolver(ctx, l, field.Children)
-		if err != nil {
+		if result[i], err = GenerateAuditResolver(ctx, l, field.Children); err != nil {
 			log.WithError(err).Error("GenerateAuditResolver")
 			return nil, err
 		}
-		if resolver == nil {
-			continue
-		}
-
-		resolvers[i] = resolver
 	}
 
-	return resolvers, nil
+	return result, nil
 }
 
 func (*QueryResolver) User(ctx context.Context, args struct{ ID string }) (*UserResolver, error) {
@@ -2,26 +2,37 @@ package query_resolvers
 
 import (
 	"context"
+	"time"
 
+	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type reportResolver struct {
+type ReportResolver struct {
 	ctx context.Context
 	v   *datastructure.Report
 
 	fields map[string]*SelectedField
 }
 
-func GenerateReportResolver(ctx context.Context, report *datastructure.Report, fields map[string]*SelectedField) (*reportResolver, error) {
-	return &reportResolver{
+func GenerateReportResolver(ctx context.Context, report *datastructure.Report, fields map[string]*SelectedField) (*ReportResolver, error) {
+	return &ReportResolver{
 		ctx:    ctx,
 		v:      report,
 		fields: fields,
 	}, nil
 }
 
-func (r *reportResolver) ReporterID() *string {
+func (r *ReportResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *ReportResolver) ReporterID() *string {
 	if r.v.ReporterID == nil {
 		return nil
 	}
@@ -29,7 +40,7 @@ func (r *reportResolver) ReporterID() *string {
 	return &hex
 }
 
-func (r *reportResolver) TargetID() *string {
+func (r *ReportResolver) TargetID() *string {
 	if r.v.Target.ID == nil {
 		return nil
 	}
@@ -37,40 +48,75 @@ func (r *reportResolver) TargetID() *string {
 	return &hex
 }
 
-func (r *reportResolver) TargetType() string {
+func (r *ReportResolver) TargetType() string {
 	return r.v.Target.Type
 }
 
-func (r *reportResolver) Reason() string {
+func (r *ReportResolver) Reason() string {
 	return r.v.Reason
 }
 
-func (r *reportResolver) Cleared() bool {
+func (r *ReportResolver) Cleared() bool {
 	return r.v.Cleared
 }
 
-func (r *reportResolver) UTarget() (*UserResolver, error) {
+func (r *ReportResolver) Status() int32 {
+	return r.v.GetStatus()
+}
+
+func (r *ReportResolver) CreatedAt() string {
+	return r.v.ID.Timestamp().Format(time.RFC3339)
+}
+
+func (r *ReportResolver) Assignee() (*UserResolver, error) {
+	if r.v.AssigneeID == nil {
+		return nil, nil
+	}
+	return GenerateUserResolver(r.ctx, nil, r.v.AssigneeID, r.fields["assignee"].Children)
+}
+
+func (r *ReportResolver) Notes() ([]*reportNoteResolver, error) {
+	usr, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return []*reportNoteResolver{}, nil
+	}
+
+	result := make([]*reportNoteResolver, len(r.v.Notes))
+	for i, n := range r.v.Notes {
+		result[i] = &reportNoteResolver{ctx: r.ctx, v: n, fields: r.fields["notes"].Children}
+	}
+	return result, nil
+}
+
+func (r *ReportResolver) Resolution() *reportResolutionResolver {
+	if r.v.Resolution == nil {
+		return nil
+	}
+	return &reportResolutionResolver{ctx: r.ctx, v: r.v.Resolution, fields: r.fields["resolution"].Children}
+}
+
+func (r *ReportResolver) UTarget() (*UserResolver, error) {
 	if r.v.Target.Type == "users" {
 		return GenerateUserResolver(r.ctx, r.v.UTarget, r.v.Target.ID, r.fields["u_target"].Children)
 	}
 	return nil, nil
 }
 
-func (r *reportResolver) ETarget() (*EmoteResolver, error) {
+func (r *ReportResolver) ETarget() (*EmoteResolver, error) {
 	if r.v.Target.Type == "emotes" {
 		return GenerateEmoteResolver(r.ctx, r.v.ETarget, r.v.Target.ID, r.fields["e_target"].Children)
 	}
 	return nil, nil
 }
 
-func (r *reportResolver) Reporter() (*UserResolver, error) {
+func (r *ReportResolver) Reporter() (*UserResolver, error) {
 	if r.v.ReporterID != nil {
 		return GenerateUserResolver(r.ctx, r.v.Reporter, r.v.ReporterID, r.fields["reporter"].Children)
 	}
 	return nil, nil
 }
 
-func (r *reportResolver) AuditEntries() ([]string, error) {
+func (r *ReportResolver) AuditEntries() ([]string, error) {
 	if r.v.AuditEntries == nil {
 		return nil, nil
 	}
@@ -85,3 +131,120 @@ func (r *reportResolver) AuditEntries() ([]string, error) {
 	}
 	return logs, nil
 }
+
+type reportNoteResolver struct {
+	ctx context.Context
+	v   *datastructure.ReportNote
+
+	fields map[string]*SelectedField
+}
+
+func (r *reportNoteResolver) Author() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.AuthorID, r.fields["author"].Children)
+}
+
+func (r *reportNoteResolver) Content() string {
+	return r.v.Content
+}
+
+func (r *reportNoteResolver) CreatedAt() string {
+	return r.v.CreatedAt.Format(time.RFC3339)
+}
+
+type reportResolutionResolver struct {
+	ctx context.Context
+	v   *datastructure.ReportResolution
+
+	fields map[string]*SelectedField
+}
+
+func (r *reportResolutionResolver) Action() string {
+	return r.v.Action
+}
+
+func (r *reportResolutionResolver) ResolvedBy() (*UserResolver, error) {
+	return GenerateUserResolver(r.ctx, nil, &r.v.ResolvedByID, r.fields["resolved_by"].Children)
+}
+
+func (r *reportResolutionResolver) ResolvedAt() string {
+	return r.v.ResolvedAt.Format(time.RFC3339)
+}
+
+func (r *reportResolutionResolver) Reason() string {
+	return r.v.Reason
+}
+
+func (*QueryResolver) Reports(ctx context.Context, args struct {
+	Status     *int32
+	TargetType *string
+	Page       *int32
+	Limit      *int32
+}) ([]*ReportResolver, error) {
+	usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !usr.HasPermission(datastructure.RolePermissionManageReports) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	page := int32(1)
+	if args.Page != nil && *args.Page > 1 {
+		page = *args.Page
+	}
+	limit := int32(20)
+	if args.Limit != nil {
+		limit = *args.Limit
+		if limit < 1 || limit > 250 {
+			limit = 250
+		}
+	}
+
+	query := bson.M{}
+	if args.Status != nil {
+		// Reports cleared before statuses existed have no status
+		switch *args.Status {
+		case datastructure.ReportStatusOpen:
+			query["cleared"] = false
+			query["status"] = bson.M{"$ne": datastructure.ReportStatusAssigned}
+		case datastructure.ReportStatusAssigned:
+			query["cleared"] = false
+			query["status"] = datastructure.ReportStatusAssigned
+		case datastructure.ReportStatusResolved:
+			query["cleared"] = true
+			query["status"] = bson.M{"$ne": datastructure.ReportStatusRejected}
+		case datastructure.ReportStatusRejected:
+			query["status"] = datastructure.ReportStatusRejected
+		default:
+			return nil, resolvers.ErrInvalidUpdate
+		}
+	}
+	if args.TargetType != nil {
+		query["target.type"] = *args.TargetType
+	}
+
+	reports := []*datastructure.Report{}
+	cur, err := mongo.Collection(mongo.CollectionNameReports).Find(ctx, query, options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)),
+	)
+	if err == nil {
+		err = cur.All(ctx, &reports)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*ReportResolver, len(reports))
+	for i, r := range reports {
+		if result[i], err = GenerateReportResolver(ctx, r, field.Children); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -59,3 +59,14 @@ func (r *RoleResolver) Allowed() string {
 func (r *RoleResolver) Denied() string {
 	return fmt.Sprint(r.v.Denied)
 }
+
+func (r *RoleResolver) EmoteReviewThreshold() *int32 {
+	return r.v.EmoteReviewThreshold
+}
+
+func (r *RoleResolver) EmoteSlots() *int32 {
+	if r.v.EmoteSlots == 0 {
+		return nil
+	}
+	return &r.v.EmoteSlots
+}
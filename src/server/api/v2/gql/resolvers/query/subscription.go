@@ -0,0 +1,306 @@
+package query_resolvers
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/gql/resolvers"
+	"github.com/SevenTV/ServerGo/src/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SubscriptionPlanResolver struct {
+	ctx context.Context
+	v   *datastructure.SubscriptionPlan
+
+	fields map[string]*SelectedField
+}
+
+func GenerateSubscriptionPlanResolver(ctx context.Context, plan *datastructure.SubscriptionPlan, fields map[string]*SelectedField) (*SubscriptionPlanResolver, error) {
+	return &SubscriptionPlanResolver{
+		ctx:    ctx,
+		v:      plan,
+		fields: fields,
+	}, nil
+}
+
+func (r *SubscriptionPlanResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *SubscriptionPlanResolver) Name() string {
+	return r.v.Name
+}
+
+func (r *SubscriptionPlanResolver) Interval() string {
+	return string(r.v.Interval)
+}
+
+func (r *SubscriptionPlanResolver) Price() int32 {
+	return r.v.Price
+}
+
+func (r *SubscriptionPlanResolver) Currency() string {
+	return r.v.Currency
+}
+
+func (r *SubscriptionPlanResolver) Role() (*RoleResolver, error) {
+	if r.v.Perks.RoleID == nil {
+		return nil, nil
+	}
+
+	return GenerateRoleResolver(r.ctx, nil, r.v.Perks.RoleID, r.fields["role"].Children)
+}
+
+func (r *SubscriptionPlanResolver) Badge() (*BadgeResolver, error) {
+	if r.v.Perks.BadgeID == nil {
+		return nil, nil
+	}
+
+	badge := &datastructure.Badge{}
+	if err := mongo.Collection(mongo.CollectionNameBadges).FindOne(r.ctx, bson.M{"_id": r.v.Perks.BadgeID}).Decode(badge); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return GenerateBadgeResolver(r.ctx, badge, r.fields["badge"].Children)
+}
+
+func (r *SubscriptionPlanResolver) EmoteSlots() int32 {
+	return r.v.Perks.EmoteSlots
+}
+
+func (r *SubscriptionPlanResolver) ProviderPlans() []*providerPlanResolver {
+	result := []*providerPlanResolver{}
+	for provider, id := range r.v.ProviderPlans {
+		result = append(result, &providerPlanResolver{provider, id})
+	}
+	return result
+}
+
+func (r *SubscriptionPlanResolver) Disabled() bool {
+	return r.v.Disabled
+}
+
+type providerPlanResolver struct {
+	provider string
+	id       string
+}
+
+func (r *providerPlanResolver) Provider() string {
+	return r.provider
+}
+
+func (r *providerPlanResolver) ID() string {
+	return r.id
+}
+
+type SubscriptionPlanInput struct {
+	Name          string
+	Interval      datastructure.BillingInterval
+	Price         int32
+	Currency      string
+	RoleID        *string
+	BadgeID       *string
+	EmoteSlots    *int32
+	ProviderPlans []struct {
+		Provider string
+		ID       string
+	}
+	Disabled *bool
+}
+
+var currencyRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Apply the input onto a plan, checking that it is valid
+func (in *SubscriptionPlanInput) Apply(ctx context.Context, plan *datastructure.SubscriptionPlan) error {
+	if in.Name == "" {
+		return resolvers.ErrInvalidName
+	}
+	if in.Price < 0 || !currencyRegex.MatchString(in.Currency) {
+		return resolvers.ErrInvalidPlan
+	}
+	plan.Name = in.Name
+	plan.Interval = in.Interval
+	plan.Price = in.Price
+	plan.Currency = in.Currency
+
+	plan.Perks = datastructure.SubscriptionPerks{}
+	if in.RoleID != nil {
+		id, err := primitive.ObjectIDFromHex(*in.RoleID)
+		if err != nil || datastructure.GetRole(&id).ID != id {
+			return resolvers.ErrUnknownRole
+		}
+		plan.Perks.RoleID = &id
+	}
+	if in.BadgeID != nil {
+		id, err := primitive.ObjectIDFromHex(*in.BadgeID)
+		if err != nil {
+			return resolvers.ErrUnknownBadge
+		}
+		if n, err := mongo.Collection(mongo.CollectionNameBadges).CountDocuments(ctx, bson.M{"_id": id}); err != nil {
+			log.WithError(err).Error("mongo")
+			return resolvers.ErrInternalServer
+		} else if n == 0 {
+			return resolvers.ErrUnknownBadge
+		}
+		plan.Perks.BadgeID = &id
+	}
+	if in.EmoteSlots != nil {
+		if *in.EmoteSlots < 0 {
+			return resolvers.ErrInvalidPlan
+		}
+		plan.Perks.EmoteSlots = *in.EmoteSlots
+	}
+
+	plan.ProviderPlans = make(map[string]string, len(in.ProviderPlans))
+	for _, p := range in.ProviderPlans {
+		if _, ok := plan.ProviderPlans[p.Provider]; ok || p.Provider == "" || p.ID == "" {
+			return resolvers.ErrInvalidPlan
+		}
+		plan.ProviderPlans[p.Provider] = p.ID
+	}
+
+	if in.Disabled != nil {
+		plan.Disabled = *in.Disabled
+	}
+	return nil
+}
+
+func (*QueryResolver) SubscriptionPlans(ctx context.Context, args struct {
+	IncludeDisabled *bool
+}) ([]*SubscriptionPlanResolver, error) {
+	filter := bson.M{"disabled": bson.M{"$ne": true}}
+	if args.IncludeDisabled != nil && *args.IncludeDisabled {
+		usr, ok := ctx.Value(utils.UserKey).(*datastructure.User)
+		if !ok {
+			return nil, resolvers.ErrLoginRequired
+		}
+		if !usr.HasPermission(datastructure.RolePermissionManageEntitlements) {
+			return nil, resolvers.ErrAccessDenied
+		}
+		filter = bson.M{}
+	}
+
+	plans := []*datastructure.SubscriptionPlan{}
+	cur, err := mongo.Collection(mongo.CollectionNamePlans).Find(ctx, filter, options.Find().SetSort(bson.M{"price": 1}))
+	if err == nil {
+		err = cur.All(ctx, &plans)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	field, failed := GenerateSelectedFieldMap(ctx, resolvers.MaxDepth)
+	if failed {
+		return nil, resolvers.ErrDepth
+	}
+
+	result := make([]*SubscriptionPlanResolver, len(plans))
+	for i, p := range plans {
+		if result[i], err = GenerateSubscriptionPlanResolver(ctx, p, field.Children); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+type userSubscriptionResolver struct {
+	ctx context.Context
+	v   *datastructure.Subscription
+
+	fields map[string]*SelectedField
+}
+
+func (r *userSubscriptionResolver) ID() string {
+	return r.v.ID.Hex()
+}
+
+func (r *userSubscriptionResolver) Plan() (*SubscriptionPlanResolver, error) {
+	plan := &datastructure.SubscriptionPlan{}
+	if err := mongo.Collection(mongo.CollectionNamePlans).FindOne(r.ctx, bson.M{"_id": r.v.PlanID}).Decode(plan); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return GenerateSubscriptionPlanResolver(r.ctx, plan, r.fields["plan"].Children)
+}
+
+func (r *userSubscriptionResolver) Provider() string {
+	return r.v.Provider
+}
+
+func (r *userSubscriptionResolver) Status() string {
+	return string(r.v.Status)
+}
+
+func (r *userSubscriptionResolver) StartedAt() string {
+	return r.v.StartedAt.Format(time.RFC3339)
+}
+
+func (r *userSubscriptionResolver) PeriodStart() string {
+	return r.v.PeriodStart.Format(time.RFC3339)
+}
+
+func (r *userSubscriptionResolver) PeriodEnd() string {
+	return r.v.PeriodEnd.Format(time.RFC3339)
+}
+
+func (r *userSubscriptionResolver) GraceEndsAt() *string {
+	return formatOptionalTime(r.v.GraceEndsAt)
+}
+
+func (r *userSubscriptionResolver) CanceledAt() *string {
+	return formatOptionalTime(r.v.CanceledAt)
+}
+
+func (r *userSubscriptionResolver) EndedAt() *string {
+	return formatOptionalTime(r.v.EndedAt)
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// The user's most recent subscription which has not ended
+func (r *UserResolver) Subscription() (*userSubscriptionResolver, error) {
+	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || (u.ID != r.v.ID && !u.HasPermission(datastructure.RolePermissionManageUsers)) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	sub := &datastructure.Subscription{}
+	if err := mongo.Collection(mongo.CollectionNameSubscriptions).FindOne(r.ctx, bson.M{
+		"user_id": r.v.ID,
+		"status":  bson.M{"$ne": datastructure.SubscriptionStatusEnded},
+	}, options.FindOne().SetSort(bson.M{"started_at": -1})).Decode(sub); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	return &userSubscriptionResolver{
+		ctx:    r.ctx,
+		v:      sub,
+		fields: r.fields["subscription"].Children,
+	}, nil
+}
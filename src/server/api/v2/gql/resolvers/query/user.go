@@ -66,9 +66,14 @@ func GenerateUserResolver(ctx context.Context, user *datastructure.User, userID
 
 	if v, ok := fields["owned_emotes"]; ok && user.OwnedEmotes == nil {
 		user.OwnedEmotes = &[]*datastructure.Emote{}
+		// Emotes awaiting review are only shown to those able to edit the channel
+		statuses := []int32{datastructure.EmoteStatusLive}
+		if actorCanEdit {
+			statuses = append(statuses, datastructure.EmoteStatusPending)
+		}
 		if err := cache.Find(ctx, "emotes", fmt.Sprintf("owner:%s", user.ID.Hex()), bson.M{
 			"owner":  user.ID,
-			"status": datastructure.EmoteStatusLive,
+			"status": bson.M{"$in": statuses},
 		}, user.OwnedEmotes); err != nil {
 			log.WithError(err).Error("mongo")
 			return nil, resolvers.ErrInternalServer
@@ -177,12 +182,12 @@ func GenerateUserResolver(ctx context.Context, user *datastructure.User, userID
 		}
 	}
 
-	if v, ok := fields["reports"]; ok && usrValid && (usr.Rank != datastructure.UserRankAdmin && usr.Rank != datastructure.UserRankModerator) && user.Reports == nil {
+	if v, ok := fields["reports"]; ok && usrValid && usr.HasPermission(datastructure.RolePermissionManageReports) && user.Reports == nil {
 		user.Reports = &[]*datastructure.Report{}
-		if err := cache.Find(ctx, "users", fmt.Sprintf("user:%s:reports", user.ID.Hex()), bson.M{
+		if err := cache.Find(ctx, "reports", fmt.Sprintf("user:%s:reports", user.ID.Hex()), bson.M{
 			"target.id":   user.ID,
 			"target.type": "users",
-		}, user.EditorIn); err != nil {
+		}, user.Reports); err != nil {
 			log.WithError(err).Error("mongo")
 			return nil, resolvers.ErrInternalServer
 		}
@@ -397,6 +402,78 @@ func (r *UserResolver) CreatedAt() string {
 	return r.v.ID.Timestamp().Format(time.RFC3339)
 }
 
+func (r *UserResolver) EditorPermissions() []*editorPermissionsResolver {
+	result := make([]*editorPermissionsResolver, len(r.v.EditorIDs))
+	for i, id := range r.v.EditorIDs {
+		p, _ := r.v.GetEditorPermissions(id)
+		result[i] = &editorPermissionsResolver{id: id.Hex(), permissions: int32(p)}
+	}
+
+	return result
+}
+
+type editorPermissionsResolver struct {
+	id          string
+	permissions int32
+}
+
+func (r *editorPermissionsResolver) ID() string {
+	return r.id
+}
+
+func (r *editorPermissionsResolver) Permissions() int32 {
+	return r.permissions
+}
+
+func (r *UserResolver) EditorInvitations() ([]*editorInvitationResolver, error) {
+	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || !r.v.CanEditChannel(u, datastructure.UserEditorPermissionManageEditors) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	return r.findEditorInvitations(bson.M{"channel_id": r.v.ID}, r.fields["editor_invitations"])
+}
+
+func (r *UserResolver) ReceivedEditorInvitations() ([]*editorInvitationResolver, error) {
+	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || (u.ID != r.v.ID && !u.HasPermission(datastructure.RolePermissionManageUsers)) {
+		return nil, resolvers.ErrAccessDenied
+	}
+
+	return r.findEditorInvitations(bson.M{"editor_id": r.v.ID}, r.fields["received_editor_invitations"])
+}
+
+// Find pending editor invitations matching a filter
+func (r *UserResolver) findEditorInvitations(filter bson.M, field *SelectedField) ([]*editorInvitationResolver, error) {
+	filter["status"] = datastructure.EditorInvitationStatusPending
+	filter["expire_at"] = bson.M{"$gt": time.Now()}
+
+	invitations := []*datastructure.EditorInvitation{}
+	cur, err := mongo.Collection(mongo.CollectionNameEditorInvitations).Find(r.ctx, filter)
+	if err == nil {
+		err = cur.All(r.ctx, &invitations)
+	}
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+
+	children := map[string]*SelectedField{}
+	if field != nil {
+		children = field.Children
+	}
+
+	result := make([]*editorInvitationResolver, len(invitations))
+	for i, inv := range invitations {
+		result[i], err = GenerateEditorInvitationResolver(r.ctx, inv, children)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
 func (r *UserResolver) Editors() ([]*UserResolver, error) {
 	editors := *r.v.Editors
 	result := []*UserResolver{}
@@ -533,9 +610,9 @@ func (r *UserResolver) ProfileImageURL() string {
 	return r.v.ProfileImageURL
 }
 
-func (r *UserResolver) Reports() (*[]*reportResolver, error) {
+func (r *UserResolver) Reports() (*[]*ReportResolver, error) {
 	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
-	if !ok || (u.Rank != datastructure.UserRankAdmin && u.Rank != datastructure.UserRankModerator) {
+	if !ok || !u.HasPermission(datastructure.RolePermissionManageReports) {
 		return nil, resolvers.ErrAccessDenied
 	}
 
@@ -544,7 +621,7 @@ func (r *UserResolver) Reports() (*[]*reportResolver, error) {
 	}
 
 	e := *r.v.Reports
-	reports := make([]*reportResolver, len(e))
+	reports := make([]*ReportResolver, len(e))
 	var err error
 	for i, l := range e {
 		reports[i], err = GenerateReportResolver(r.ctx, l, r.fields["reports"].Children)
@@ -623,12 +700,86 @@ func (r *UserResolver) AuditEntries() (*[]*auditResolver, error) {
 	return &resolvers, nil
 }
 
-func (r *UserResolver) EmoteSlots() int32 {
+func (r *UserResolver) EmoteSlots() (int32, error) {
 	if r.ub.IsBanned() { // Omit if user is banned
-		return 0
+		return 0, nil
+	}
+
+	slots, err := actions.Users.EmoteSlots(r.ctx, r.v)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return 0, resolvers.ErrInternalServer
+	}
+	return slots.Total, nil
+}
+
+func (r *UserResolver) EmoteSlotBreakdown() (*emoteSlotBreakdownResolver, error) {
+	u, ok := r.ctx.Value(utils.UserKey).(*datastructure.User)
+	if !ok || (u.ID != r.v.ID && !u.HasPermission(datastructure.RolePermissionManageUsers)) {
+		return nil, resolvers.ErrAccessDenied
 	}
 
-	return r.v.GetEmoteSlots()
+	slots, err := actions.Users.EmoteSlots(r.ctx, r.v)
+	if err != nil {
+		log.WithError(err).Error("mongo")
+		return nil, resolvers.ErrInternalServer
+	}
+	return &emoteSlotBreakdownResolver{slots}, nil
+}
+
+type emoteSlotBreakdownResolver struct {
+	v *actions.EmoteSlotBreakdown
+}
+
+func (r *emoteSlotBreakdownResolver) Total() int32 {
+	return r.v.Total
+}
+
+func (r *emoteSlotBreakdownResolver) Used() int32 {
+	return r.v.Used
+}
+
+func (r *emoteSlotBreakdownResolver) OverLimit() bool {
+	return r.v.OverLimit()
+}
+
+func (r *emoteSlotBreakdownResolver) Sources() []*emoteSlotSourceResolver {
+	result := make([]*emoteSlotSourceResolver, len(r.v.Sources))
+	for i, s := range r.v.Sources {
+		result[i] = &emoteSlotSourceResolver{s}
+	}
+	return result
+}
+
+type emoteSlotSourceResolver struct {
+	v *actions.EmoteSlotSource
+}
+
+func (r *emoteSlotSourceResolver) Kind() string {
+	return string(r.v.Kind)
+}
+
+func (r *emoteSlotSourceResolver) Slots() int32 {
+	return r.v.Slots
+}
+
+func (r *emoteSlotSourceResolver) Applied() bool {
+	return r.v.Applied
+}
+
+func (r *emoteSlotSourceResolver) RefID() *string {
+	if r.v.RefID == nil {
+		return nil
+	}
+	s := r.v.RefID.Hex()
+	return &s
+}
+
+func (r *emoteSlotSourceResolver) Name() *string {
+	if r.v.Name == "" {
+		return nil
+	}
+	return &r.v.Name
 }
 
 // Get user's folloer count
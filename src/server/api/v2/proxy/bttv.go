@@ -11,7 +11,8 @@ import (
 	"github.com/SevenTV/ServerGo/src/utils"
 )
 
-const baseUrlBTTV = "https://api.betterttv.net/3"
+var baseUrlBTTV = "https://api.betterttv.net/3"
+var cdnUrlBTTV = "https://cdn.betterttv.net"
 
 func GetGlobalEmotesBTTV(ctx context.Context) ([]*datastructure.Emote, error) {
 	// Set Request URI
@@ -144,7 +145,7 @@ func bttvTo7TV(emotes []emoteBTTV) ([]*datastructure.Emote, error) {
 }
 
 func getCdnURL_BTTV(emoteID string, size int8) string {
-	return fmt.Sprintf("https://cdn.betterttv.net/emote/%v/%dx", emoteID, size)
+	return fmt.Sprintf("%v/emote/%v/%dx", cdnUrlBTTV, emoteID, size)
 }
 
 type emoteBTTV struct {
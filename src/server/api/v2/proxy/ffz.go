@@ -15,7 +15,8 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-const baseUrlFFZ = "https://api.frankerfacez.com/v1"
+var baseUrlFFZ = "https://api.frankerfacez.com/v1"
+var cdnUrlFFZ = "https://cdn.frankerfacez.com"
 
 // Get channel emotes from the FFZ provider
 func GetChannelEmotesFFZ(ctx context.Context, login string) ([]*datastructure.Emote, error) {
@@ -120,7 +121,7 @@ func ffzTo7TV(emotes []emoteFFZ) ([]*datastructure.Emote, error) {
 }
 
 func getCdnURL_FFZ(emoteID int32, size int8) string {
-	return fmt.Sprintf("https://cdn.frankerfacez.com/emoticon/%d/%d", emoteID, size)
+	return fmt.Sprintf("%v/emoticon/%d/%d", cdnUrlFFZ, emoteID, size)
 }
 
 type emoteFFZ struct {
@@ -0,0 +1,26 @@
+package api_proxy
+
+import (
+	"strings"
+
+	"github.com/SevenTV/ServerGo/src/configure"
+)
+
+func init() {
+	Configure()
+}
+
+// Configure: Read the providers' URLs from the config, where they may be overridden, such as to point them at a local stand-in
+func Configure() {
+	for key, url := range map[string]*string{
+		"proxy.bttv.api_url":   &baseUrlBTTV,
+		"proxy.bttv.cdn_url":   &cdnUrlBTTV,
+		"proxy.ffz.api_url":    &baseUrlFFZ,
+		"proxy.ffz.cdn_url":    &cdnUrlFFZ,
+		"proxy.twitch.api_url": &baseUrlTwitch,
+	} {
+		if v := configure.Config.GetString(key); v != "" {
+			*url = strings.TrimSuffix(v, "/")
+		}
+	}
+}
@@ -10,9 +10,11 @@ import (
 	"github.com/SevenTV/ServerGo/src/auth"
 	"github.com/SevenTV/ServerGo/src/cache"
 	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/utils"
 )
 
-const baseUrlTwitch = "https://api.twitch.tv"
+var baseUrlTwitch = "https://api.twitch.tv"
 
 func GetTwitchUser(ctx context.Context, login string) (*userTwitch, error) {
 	// Set Request URI
@@ -89,6 +91,79 @@ func GetTwitchFollowerCount(ctx context.Context, id string) (int32, error) {
 	return response.Total, nil
 }
 
+// Get the emotes a Twitch channel offers, such as to its subscribers and followers
+func GetChannelEmotesTwitch(ctx context.Context, twitchID string) ([]*datastructure.Emote, error) {
+	// Set Request URI
+	uri := fmt.Sprintf("%v/helix/chat/emotes?broadcaster_id=%v", baseUrlTwitch, twitchID)
+
+	return getEmotesTwitch(ctx, uri, time.Minute*10)
+}
+
+// Get the emotes available in every Twitch channel
+func GetGlobalEmotesTwitch(ctx context.Context) ([]*datastructure.Emote, error) {
+	// Set Request URI
+	uri := fmt.Sprintf("%v/helix/chat/emotes/global", baseUrlTwitch)
+
+	// This request is cached for 4 hours as global emotes rarely change
+	return getEmotesTwitch(ctx, uri, time.Hour*4)
+}
+
+func getEmotesTwitch(ctx context.Context, uri string, ttl time.Duration) ([]*datastructure.Emote, error) {
+	// Get auth
+	headers, err := getTwitchAuthorizeHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	resp, err := cache.CacheGetRequest(ctx, uri, ttl, time.Minute*15, headers...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode
+	var emoteResponse emotesResponseTwitch
+	if err := json.Unmarshal(resp.Body, &emoteResponse); err != nil {
+		return nil, err
+	}
+
+	// Convert these twitch emotes into a 7TV emote object
+	result := make([]*datastructure.Emote, len(emoteResponse.Data))
+	for i, e := range emoteResponse.Data {
+		result[i] = &datastructure.Emote{
+			Name:   e.Name,
+			Mime:   "image/png",
+			Status: datastructure.EmoteStatusLive,
+			URLs: [][]string{
+				{"1", e.Images.URL1x},
+				{"2", e.Images.URL2x},
+				{"4", e.Images.URL4x},
+			},
+
+			Provider:   "TWITCH",
+			ProviderID: utils.StringPointer(e.ID),
+		}
+	}
+
+	return result, nil
+}
+
+type emotesResponseTwitch struct {
+	Data []emoteTwitch `json:"data"`
+}
+
+type emoteTwitch struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Images struct {
+		URL1x string `json:"url_1x"`
+		URL2x string `json:"url_2x"`
+		URL4x string `json:"url_4x"`
+	} `json:"images"`
+	Tier      string `json:"tier"`
+	EmoteType string `json:"emote_type"`
+}
+
 type userResponseTwitch struct {
 	Data []userTwitch `json:"data"`
 }
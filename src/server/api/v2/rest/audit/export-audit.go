@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
+	"github.com/SevenTV/ServerGo/src/server/middleware"
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The most entries a single export may contain
+const MAX_EXPORT_SIZE = 100000
+
+// How long an export may keep streaming before it is cut off
+const EXPORT_TIMEOUT = 5 * time.Minute
+
+func ExportAuditRoute(router fiber.Router) {
+	router.Get(
+		"/export",
+		middleware.UserAuthMiddleware(true),
+		func(c *fiber.Ctx) error {
+			usr, ok := c.Locals("user").(*datastructure.User)
+			if !ok {
+				return restutil.ErrLoginRequired().Send(c)
+			}
+			if !usr.HasPermission(datastructure.RolePermissionManageReports) {
+				return restutil.ErrAccessDenied().Send(c)
+			}
+
+			format := c.Query("format", "ndjson")
+			if format != "ndjson" && format != "csv" {
+				return restutil.ErrBadRequest().Send(c, "format must be ndjson or csv")
+			}
+
+			q, err := parseAuditQuery(c)
+			if err != nil {
+				return restutil.ErrBadRequest().Send(c, err.Error())
+			}
+
+			if err := actions.Audit.RecordView(c.Context(), usr, q, "export"); err != nil {
+				log.WithError(err).Error("mongo")
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			filename := fmt.Sprintf("audit-%v.%v", time.Now().UTC().Format("20060102-150405"), format)
+			c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v\"", filename))
+			if format == "csv" {
+				c.Set("Content-Type", "text/csv")
+			} else {
+				c.Set("Content-Type", "application/x-ndjson")
+			}
+
+			// The body is written after the handler returns, so the request context can't be used
+			c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+				ctx, cancel := context.WithTimeout(context.Background(), EXPORT_TIMEOUT)
+				defer cancel()
+
+				var write func(l *datastructure.AuditLog) error
+				if format == "csv" {
+					cw := csv.NewWriter(w)
+					defer cw.Flush()
+
+					_ = cw.Write([]string{"id", "created_at", "type", "action_user", "target_type", "target_id", "reason", "changes"})
+					write = func(l *datastructure.AuditLog) error {
+						return cw.Write(auditLogCSVRecord(l))
+					}
+				} else {
+					enc := json.NewEncoder(w)
+					write = func(l *datastructure.AuditLog) error {
+						return enc.Encode(l)
+					}
+				}
+
+				if err := actions.Audit.Iterate(ctx, q, write); err != nil {
+					log.WithError(err).Error("audit export")
+				}
+				_ = w.Flush()
+			})
+			return nil
+		},
+	)
+}
+
+// Read the query filters from the request's query string
+func parseAuditQuery(c *fiber.Ctx) (actions.AuditQuery, error) {
+	q := actions.AuditQuery{
+		TargetType: c.Query("target_type"),
+		Reason:     c.Query("reason"),
+		Limit:      MAX_EXPORT_SIZE,
+	}
+
+	for _, id := range []struct {
+		name string
+		dst  **primitive.ObjectID
+	}{{"actor_id", &q.ActorID}, {"target_id", &q.TargetID}, {"cursor", &q.Cursor}} {
+		if s := c.Query(id.name); s != "" {
+			v, err := primitive.ObjectIDFromHex(s)
+			if err != nil {
+				return q, fmt.Errorf("invalid %v", id.name)
+			}
+			*id.dst = &v
+		}
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  **time.Time
+	}{{"after", &q.After}, {"before", &q.Before}} {
+		if s := c.Query(t.name); s != "" {
+			v, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return q, fmt.Errorf("invalid %v, expected RFC3339", t.name)
+			}
+			*t.dst = &v
+		}
+	}
+
+	if s := c.Query("types"); s != "" {
+		for _, v := range strings.Split(s, ",") {
+			i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 32)
+			if err != nil {
+				return q, fmt.Errorf("invalid types")
+			}
+			q.Types = append(q.Types, int32(i))
+		}
+	}
+
+	for _, r := range []struct {
+		name string
+		dst  **int32
+	}{{"type_min", &q.TypeMin}, {"type_max", &q.TypeMax}} {
+		if s := c.Query(r.name); s != "" {
+			i, err := strconv.ParseInt(s, 10, 32)
+			if err != nil {
+				return q, fmt.Errorf("invalid %v", r.name)
+			}
+			v := int32(i)
+			*r.dst = &v
+		}
+	}
+
+	if s := c.Query("limit"); s != "" {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || i < 1 {
+			return q, fmt.Errorf("invalid limit")
+		}
+		if i < MAX_EXPORT_SIZE {
+			q.Limit = i
+		}
+	}
+
+	return q, nil
+}
+
+func auditLogCSVRecord(l *datastructure.AuditLog) []string {
+	targetType, targetID, reason := "", "", ""
+	if l.Target != nil {
+		targetType = l.Target.Type
+		if l.Target.ID != nil {
+			targetID = l.Target.ID.Hex()
+		}
+	}
+	if l.Reason != nil {
+		reason = *l.Reason
+	}
+	changes, _ := json.Marshal(l.Changes)
+
+	return []string{
+		l.ID.Hex(),
+		l.ID.Timestamp().UTC().Format(time.RFC3339),
+		strconv.Itoa(int(l.Type)),
+		l.CreatedBy.Hex(),
+		targetType,
+		targetID,
+		reason,
+		string(changes),
+	}
+}
@@ -0,0 +1,209 @@
+package cosmetics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// A bulk endpoint serving the holders of a kind of cosmetic to chat clients, from its cosmetic map
+type MapRoute struct {
+	Map     *actions.CosmeticMap
+	ListKey string // The key of the list of items in responses, such as "badges"
+	ItemKey string // The key of a single item in responses, such as "badge"
+	// Create the response for an item, listing its holders, or without them if userIDs is nil
+	Render func(item json.RawMessage, userIDs *[]string) (interface{}, error)
+
+	// The rendered payloads of the latest map, by user identifier type
+	mx       sync.Mutex
+	version  int64
+	payloads map[string][]byte
+}
+
+/*
+* Query Params:
+* user_identifier: "object_id", "twitch_id", "login"
+* since: a version of the map, to only get the changes made after it
+ */
+func (r *MapRoute) Register(router fiber.Router) {
+	router.Get("/", func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		idType := c.Query("user_identifier")
+
+		if !utils.Contains(actions.CosmeticMapIdentifierTypes, idType) {
+			return restutil.ErrMissingQueryParams().Send(c, `user_identifier: must be 'object_id', 'twitch_id' or 'login'`)
+		}
+		c.Set("Cache-Control", "max-age=30")
+
+		// Send only the changes made since a version the client already has
+		if s := c.Query("since"); s != "" {
+			since, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return restutil.ErrBadRequest().Send(c, "since: must be a map version")
+			}
+
+			diff, err := r.Map.Diff(ctx, since)
+			if err == actions.ErrCosmeticMapDiffUnavailable {
+				return restutil.ErrGone().Send(c, fmt.Sprintf("the changes since this version are no longer kept, download the full list of %s", r.ListKey))
+			} else if err != nil {
+				log.WithError(err).Error("redis")
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			etag := fmt.Sprintf(`"%d"`, diff.Version)
+			c.Set(fiber.HeaderETag, etag)
+			if c.Get(fiber.HeaderIfNoneMatch) == etag {
+				return c.SendStatus(304)
+			}
+
+			result, err := r.renderDiff(diff, idType)
+			if err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+			b, err := json.Marshal(result)
+			if err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+			return c.Status(200).Send(b)
+		}
+
+		// The map was never built until the task first runs, so it may have to be built now
+		version, err := r.Map.EnsureBuilt(ctx)
+		if err != nil {
+			log.WithError(err).Error("redis")
+			return restutil.ErrInternalServer().Send(c, err.Error())
+		}
+
+		etag := fmt.Sprintf(`"%d"`, version)
+		c.Set(fiber.HeaderETag, etag)
+		if c.Get(fiber.HeaderIfNoneMatch) == etag {
+			return c.SendStatus(304)
+		}
+
+		b, err := r.render(ctx, version, idType)
+		if err != nil {
+			log.WithError(err).Error("redis")
+			return restutil.ErrInternalServer().Send(c, err.Error())
+		}
+		return c.Status(200).Send(b)
+	})
+
+	router.Get("/users/:user", func(c *fiber.Ctx) error {
+		idType := c.Query("user_identifier")
+
+		if !utils.Contains(actions.CosmeticMapIdentifierTypes, idType) {
+			return restutil.ErrMissingQueryParams().Send(c, `user_identifier: must be 'object_id', 'twitch_id' or 'login'`)
+		}
+		c.Set("Cache-Control", "max-age=30")
+
+		identifier := c.Params("user")
+		if idType == "login" {
+			identifier = strings.ToLower(identifier)
+		}
+
+		item, err := r.Map.UserItem(c.Context(), idType, identifier)
+		if err != nil {
+			log.WithError(err).Error("redis")
+			return restutil.ErrInternalServer().Send(c, err.Error())
+		}
+
+		result := map[string]interface{}{r.ItemKey: nil}
+		if item != nil {
+			if result[r.ItemKey], err = r.Render(item, nil); err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+		}
+
+		b, err := json.Marshal(result)
+		if err != nil {
+			return restutil.ErrInternalServer().Send(c, err.Error())
+		}
+		return c.Status(200).Send(b)
+	})
+}
+
+// Get the payload of the map for an identifier type, rendering every type once per version
+func (r *MapRoute) render(ctx context.Context, version int64, idType string) ([]byte, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.version >= version && r.payloads != nil {
+		return r.payloads[idType], nil
+	}
+
+	data, err := r.Map.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = &actions.CosmeticMapData{Version: version}
+	}
+
+	payloads := make(map[string][]byte, len(actions.CosmeticMapIdentifierTypes))
+	for _, t := range actions.CosmeticMapIdentifierTypes {
+		items := make([]interface{}, len(data.Items))
+		for i, entry := range data.Items {
+			userIDs := make([]string, len(entry.Holders))
+			for j, h := range entry.Holders {
+				userIDs[j] = h.Identifier(t)
+			}
+			if items[i], err = r.Render(entry.Item, &userIDs); err != nil {
+				return nil, err
+			}
+		}
+
+		if payloads[t], err = json.Marshal(map[string]interface{}{
+			"version": data.Version,
+			r.ListKey: items,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	r.version = data.Version
+	r.payloads = payloads
+	return payloads[idType], nil
+}
+
+// Create the response for a diff. Removed holders are to be applied before the added ones
+func (r *MapRoute) renderDiff(diff *actions.CosmeticMapDiff, idType string) (map[string]interface{}, error) {
+	items := make([]interface{}, len(diff.Items))
+	for i, item := range diff.Items {
+		var err error
+		if items[i], err = r.Render(item, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	added := map[string][]string{}
+	removed := map[string][]string{}
+	for _, t := range []struct {
+		from map[string][]*actions.CosmeticHolder
+		to   map[string][]string
+	}{{diff.Added, added}, {diff.Removed, removed}} {
+		for itemID, holders := range t.from {
+			userIDs := make([]string, len(holders))
+			for i, h := range holders {
+				userIDs[i] = h.Identifier(idType)
+			}
+			t.to[itemID] = userIDs
+		}
+	}
+
+	return map[string]interface{}{
+		"version": diff.Version,
+		"since":   diff.Since,
+		r.ListKey: items, // Items which were created or edited, without their holders
+		"added":   added,
+		"removed": removed,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package emotes
 
 import (
+	"context"
 	"fmt"
 	"image/gif"
 	"image/jpeg"
@@ -19,6 +20,7 @@ import (
 	"github.com/SevenTV/ServerGo/src/discord"
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
 	"github.com/SevenTV/ServerGo/src/server/middleware"
 	"github.com/SevenTV/ServerGo/src/utils"
@@ -52,6 +54,12 @@ func CreateEmoteRoute(router fiber.Router) {
 			if !usr.HasPermission(datastructure.RolePermissionEmoteCreate) {
 				return restutil.ErrAccessDenied().Send(c)
 			}
+			if restricted, err := actions.Bans.IsRestricted(c.Context(), usr.ID, datastructure.BanScopeUpload); err != nil {
+				log.WithError(err).Error("redis")
+				return restutil.ErrInternalServer().Send(c)
+			} else if restricted {
+				return restutil.ErrUserBanned().Send(c)
+			}
 
 			req := c.Request()
 			fctx := c.Context()
@@ -189,16 +197,36 @@ func CreateEmoteRoute(router fiber.Router) {
 
 			if !usr.HasPermission(datastructure.RolePermissionManageUsers) {
 				if channelID.Hex() != usr.ID.Hex() {
+					channel := &datastructure.User{}
 					if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(c.Context(), bson.M{
 						"_id":     channelID,
 						"editors": usr.ID,
-					}).Err(); err != nil {
+					}).Decode(channel); err != nil {
 						if err == mongo.ErrNoDocuments {
 							return restutil.ErrAccessDenied().Send(c)
 						}
 						log.WithError(err).Error("mongo")
 						return restutil.ErrInternalServer().Send(c)
 					}
+					if p, _ := channel.GetEditorPermissions(usr.ID); !utils.BitField.HasBits(p, datastructure.UserEditorPermissionUploadEmotes) {
+						return restutil.ErrAccessDenied().Send(c)
+					}
+				}
+			}
+
+			// Run the name and tags through the content filter
+			filtered := false
+			if !usr.HasPermission(datastructure.RolePermissionEmoteEditAll) {
+				match, err := actions.Filter.Check(c.Context(), append([]string{emoteName}, emoteTags...)...)
+				if err != nil {
+					log.WithError(err).Error("mongo")
+					return restutil.ErrInternalServer().Send(c)
+				}
+				if match != nil {
+					if match.Rule.Action == datastructure.FilterActionReject {
+						return restutil.ErrBadRequest().Send(c, fmt.Sprintf("'%s' was rejected by the content filter", match.Value))
+					}
+					filtered = true
 				}
 			}
 
@@ -393,35 +421,55 @@ func CreateEmoteRoute(router fiber.Router) {
 				return restutil.ErrInternalServer().Send(c)
 			}
 
-			_, err = mongo.Collection(mongo.CollectionNameEmotes).UpdateOne(c.Context(), bson.M{
-				"_id": _id,
-			}, bson.M{
-				"$set": bson.M{
-					"status": datastructure.EmoteStatusLive,
-				},
-			})
+			// Uploads from untrusted users are held until a moderator approves them
+			pending, err := actions.Emotes.RequiresReview(c.Context(), usr)
 			if err != nil {
+				log.WithError(err).Error("mongo")
+				pending = true
+			}
+			pending = pending || filtered
+			emote.Status = utils.Ternary(pending, datastructure.EmoteStatusPending, datastructure.EmoteStatusLive).(int32)
+
+			if _, err := actions.Audit.Mutate(c.Context(), actions.AuditedMutation{
+				Actor:  usr,
+				Type:   datastructure.AuditLogTypeEmoteCreate,
+				Target: &datastructure.Target{ID: &_id, Type: "emotes"},
+				After:  emote,
+				Ignore: []string{"edited_at"},
+				Apply: func(ctx context.Context) error {
+					_, err := mongo.Collection(mongo.CollectionNameEmotes).UpdateOne(ctx, bson.M{
+						"_id": _id,
+					}, bson.M{
+						"$set": bson.M{
+							"status": emote.Status,
+						},
+					})
+					return err
+				},
+				Discord: func(changes []*datastructure.AuditLogChange) {
+					// Held uploads are announced once approved
+					if !pending {
+						discord.SendEmoteCreate(*emote, *usr)
+					}
+				},
+			}); err != nil {
 				log.WithError(err).WithField("id", id).Error("mongo")
 			}
 
-			_, err = mongo.Collection(mongo.CollectionNameAudit).InsertOne(c.Context(), &datastructure.AuditLog{
-				Type: datastructure.AuditLogTypeEmoteCreate,
-				Changes: []*datastructure.AuditLogChange{
-					{Key: "name", OldValue: nil, NewValue: emoteName},
-					{Key: "tags", OldValue: nil, NewValue: []string{}},
-					{Key: "owner", OldValue: nil, NewValue: usr.ID},
-					{Key: "visibility", OldValue: nil, NewValue: datastructure.EmoteVisibilityPrivate},
-					{Key: "mime", OldValue: nil, NewValue: mime},
-					{Key: "status", OldValue: nil, NewValue: datastructure.EmoteStatusProcessing},
-				},
-				Target:    &datastructure.Target{ID: &_id, Type: "emotes"},
-				CreatedBy: usr.ID,
-			})
-			if err != nil {
-				log.WithError(err).Error("mongo")
+			if pending {
+				go func() {
+					if err := actions.Notifications.Create().
+						SetTitle("Emote Awaiting Review").
+						AddTargetUsers(emote.OwnerID).
+						AddTextMessagePart("Your emote ").
+						AddEmoteMentionPart(emote.ID).
+						AddTextMessagePart(" was received and will be available once approved by a moderator.").
+						Write(context.Background()); err != nil {
+						log.WithError(err).Error("failed to create notification")
+					}
+				}()
 			}
 
-			go discord.SendEmoteCreate(*emote, *usr)
 			return c.SendString(fmt.Sprintf(`{"id":"%v"}`, emote.ID.Hex()))
 		})
 }
@@ -0,0 +1,140 @@
+package emotes
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/cache"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
+	"github.com/SevenTV/ServerGo/src/server/middleware"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const MAX_PERSONAL_EMOTE_USERS = 100
+
+/*
+* Query Params:
+* users: a comma-separated list of up to 100 users
+* user_identifier: "object_id", "twitch_id", "login"
+ */
+func GetPersonalEmotes(router fiber.Router) {
+	router.Get("/personal", middleware.RateLimitMiddleware("get-personal-emotes", 60, 10*time.Second),
+		func(c *fiber.Ctx) error {
+			ctx := c.Context()
+			idType := c.Query("user_identifier")
+			if idType == "" {
+				idType = "object_id"
+			}
+			if !utils.Contains(actions.CosmeticMapIdentifierTypes, idType) {
+				return restutil.ErrMissingQueryParams().Send(c, `user_identifier: must be 'object_id', 'twitch_id' or 'login'`)
+			}
+
+			identifiers := []string{}
+			for _, s := range strings.Split(c.Query("users"), ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					identifiers = append(identifiers, s)
+				}
+			}
+			if len(identifiers) == 0 {
+				return restutil.ErrMissingQueryParams().Send(c, "users")
+			}
+			if len(identifiers) > MAX_PERSONAL_EMOTE_USERS {
+				return restutil.ErrBadRequest().Send(c, "too many users")
+			}
+
+			// Build the user query for the identifier type
+			var filter bson.M
+			switch idType {
+			case "object_id":
+				ids := make([]primitive.ObjectID, len(identifiers))
+				for i, s := range identifiers {
+					id, err := primitive.ObjectIDFromHex(s)
+					if err != nil {
+						return restutil.MalformedObjectId().Send(c)
+					}
+					ids[i] = id
+				}
+				filter = bson.M{"_id": bson.M{"$in": ids}}
+			case "twitch_id":
+				filter = bson.M{"id": bson.M{"$in": identifiers}}
+			case "login":
+				for i, s := range identifiers {
+					identifiers[i] = strings.ToLower(s)
+				}
+				filter = bson.M{"login": bson.M{"$in": identifiers}}
+			}
+
+			var users []*datastructure.User
+			if err := cache.Find(ctx, "users", "", filter, &users); err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			sets, err := actions.Emotes.PersonalSets(ctx, users)
+			if err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			// Find the owners of the emotes
+			ownerIDs := []primitive.ObjectID{}
+			for _, userSets := range sets {
+				for _, set := range userSets {
+					for _, emote := range set.Emotes {
+						ownerIDs = append(ownerIDs, emote.OwnerID)
+					}
+				}
+			}
+			var owners []*datastructure.User
+			if len(ownerIDs) > 0 {
+				if err := cache.Find(ctx, "users", "", bson.M{
+					"_id": bson.M{"$in": ownerIDs},
+				}, &owners); err != nil {
+					return restutil.ErrInternalServer().Send(c, err.Error())
+				}
+			}
+			ownerMap := make(map[primitive.ObjectID]*datastructure.User, len(owners))
+			for _, o := range owners {
+				if !redis.Client.HExists(ctx, "user:bans", o.ID.Hex()).Val() {
+					ownerMap[o.ID] = o
+				} else {
+					ownerMap[o.ID] = datastructure.DeletedUser
+				}
+			}
+
+			// Create final response, keyed by the requested identifiers
+			response := map[string][]*restutil.PersonalEmoteSetResponse{}
+			for _, u := range users {
+				userSets, ok := sets[u.ID]
+				if !ok {
+					continue
+				}
+
+				identifier := u.ID.Hex()
+				switch idType {
+				case "twitch_id":
+					identifier = u.TwitchID
+				case "login":
+					identifier = u.Login
+				}
+
+				result := make([]*restutil.PersonalEmoteSetResponse, len(userSets))
+				for i, set := range userSets {
+					result[i] = restutil.CreatePersonalEmoteSetResponse(set, ownerMap)
+				}
+				response[identifier] = result
+			}
+
+			j, err := json.Marshal(response)
+			if err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			return c.Send(j)
+		})
+}
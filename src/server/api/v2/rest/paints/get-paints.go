@@ -0,0 +1,33 @@
+package paints
+
+import (
+	"encoding/json"
+
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/cosmetics"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
+	"github.com/gofiber/fiber/v2"
+)
+
+var paintMapRoute = &cosmetics.MapRoute{
+	Map:     actions.PaintMap,
+	ListKey: "paints",
+	ItemKey: "paint",
+	Render: func(item json.RawMessage, userIDs *[]string) (interface{}, error) {
+		paint := &datastructure.Paint{}
+		if err := json.Unmarshal(item, paint); err != nil {
+			return nil, err
+		}
+		return restutil.CreatePaintResponse(paint, userIDs), nil
+	},
+}
+
+/*
+* Query Params:
+* user_identifier: "object_id", "twitch_id", "login"
+* since: a version of the paint map, to only get the changes made after it
+ */
+func GetPaints(router fiber.Router) {
+	paintMapRoute.Register(router)
+}
@@ -6,8 +6,11 @@ import (
 
 	"github.com/SevenTV/ServerGo/src/configure"
 	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/audit"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/badges"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/emotes"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/paints"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/subscriptions"
 	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/users"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -28,15 +31,28 @@ func RestV2(app fiber.Router) fiber.Router {
 	emoteGroup := restGroup.Group("/emotes")
 	emotes.CreateEmoteRoute(emoteGroup)
 	emotes.GetGlobalEmotes(emoteGroup)
+	emotes.GetPersonalEmotes(emoteGroup)
 	emotes.GetEmoteRoute(emoteGroup)
 
 	userGroup := restGroup.Group("/users")
 	users.GetUser(userGroup)
+	users.GetEffectiveEmotesRoute(userGroup)
 	users.GetChannelEmotesRoute(userGroup)
+	users.ExportChannelSnapshotRoute(userGroup)
+	users.ImportChannelSnapshotRoute(userGroup)
 
 	badgeGroup := restGroup.Group("/badges")
 	badges.GetBadges(badgeGroup)
 
+	paintGroup := restGroup.Group("/paints")
+	paints.GetPaints(paintGroup)
+
+	subscriptionGroup := restGroup.Group("/subscriptions")
+	subscriptions.Webhook(subscriptionGroup)
+
+	auditGroup := restGroup.Group("/audit")
+	audit.ExportAuditRoute(auditGroup)
+
 	restGroup.Get("/webext", func(c *fiber.Ctx) error {
 		// result := &WebExtResult{}
 
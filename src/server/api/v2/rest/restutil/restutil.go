@@ -6,8 +6,10 @@ import (
 	"strings"
 
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/utils"
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ErrorResponse struct {
@@ -38,7 +40,10 @@ var (
 	ErrBadRequest         = func() *ErrorResponse { return createErrorResponse(400, "Bad Request (%s)") }
 	ErrLoginRequired      = func() *ErrorResponse { return createErrorResponse(403, "Authentication Required") }
 	ErrAccessDenied       = func() *ErrorResponse { return createErrorResponse(403, "Insufficient Privilege") }
+	ErrUserBanned         = func() *ErrorResponse { return createErrorResponse(403, "User Is Banned") }
 	ErrMissingQueryParams = func() *ErrorResponse { return createErrorResponse(400, "Missing Query Params (%s)") }
+	ErrGone               = func() *ErrorResponse { return createErrorResponse(410, "Gone (%s)") }
+	ErrUnavailable        = func() *ErrorResponse { return createErrorResponse(503, "Service Unavailable (%s)") }
 )
 
 func CreateEmoteResponse(emote *datastructure.Emote, owner *datastructure.User) EmoteResponse {
@@ -68,6 +73,7 @@ func CreateEmoteResponse(emote *datastructure.Emote, owner *datastructure.User)
 		Width:            emote.Width,
 		Height:           emote.Height,
 		URLs:             urls,
+		Attribution:      emote.Attribution,
 	}
 	if owner != nil {
 		response.Owner = CreateUserResponse(owner)
@@ -77,17 +83,18 @@ func CreateEmoteResponse(emote *datastructure.Emote, owner *datastructure.User)
 }
 
 type EmoteResponse struct {
-	ID               string        `json:"id"`
-	Name             string        `json:"name"`
-	Owner            *UserResponse `json:"owner"`
-	Visibility       int32         `json:"visibility"`
-	VisibilitySimple *[]string     `json:"visibility_simple"`
-	Mime             string        `json:"mime"`
-	Status           int32         `json:"status"`
-	Tags             []string      `json:"tags"`
-	Width            [4]int16      `json:"width"`
-	Height           [4]int16      `json:"height"`
-	URLs             [][]string    `json:"urls"`
+	ID               string                          `json:"id"`
+	Name             string                          `json:"name"`
+	Owner            *UserResponse                   `json:"owner"`
+	Visibility       int32                           `json:"visibility"`
+	VisibilitySimple *[]string                       `json:"visibility_simple"`
+	Mime             string                          `json:"mime"`
+	Status           int32                           `json:"status"`
+	Tags             []string                        `json:"tags"`
+	Width            [4]int16                        `json:"width"`
+	Height           [4]int16                        `json:"height"`
+	URLs             [][]string                      `json:"urls"`
+	Attribution      *datastructure.EmoteAttribution `json:"attribution,omitempty"` // The origin of an emote re-hosted from another provider
 }
 
 func CreateUserResponse(user *datastructure.User, opt ...UserResponseOptions) *UserResponse {
@@ -120,20 +127,7 @@ type UserResponse struct {
 	EmoteAliases map[string]string  `json:"emote_aliases,omitempty"`
 }
 
-func CreateBadgeResponse(badge *datastructure.Badge, users []*datastructure.User, idType string) *BadgeResponse {
-	// Select user ID type
-	userIDs := make([]string, len(users))
-	for i, u := range users {
-		switch idType {
-		case "object_id":
-			userIDs[i] = u.ID.Hex()
-		case "twitch_id":
-			userIDs[i] = u.TwitchID
-		case "login":
-			userIDs[i] = u.Login
-		}
-	}
-
+func CreateBadgeResponse(badge *datastructure.Badge, userIDs *[]string) *BadgeResponse {
 	// Generate URLs
 	urls := make([][]string, 3)
 	for i := 1; i <= 3; i++ {
@@ -161,6 +155,155 @@ type BadgeResponse struct {
 	Name    string     `json:"name"`
 	Tooltip string     `json:"tooltip"`
 	URLs    [][]string `json:"urls"`
-	Users   []string   `json:"users"`
+	Users   *[]string  `json:"users,omitempty"` // Omitted where the badge is listed without its holders
 	Misc    bool       `json:"misc,omitempty"`
 }
+
+func CreatePaintResponse(paint *datastructure.Paint, userIDs *[]string) *PaintResponse {
+	return &PaintResponse{
+		Paint: paint,
+		Users: userIDs,
+	}
+}
+
+type PaintResponse struct {
+	*datastructure.Paint
+	Users *[]string `json:"users,omitempty"` // Omitted where the paint is listed without its holders
+}
+
+func CreatePersonalEmoteSetResponse(set *actions.PersonalEmoteSet, owners map[primitive.ObjectID]*datastructure.User) *PersonalEmoteSetResponse {
+	emotes := make([]EmoteResponse, len(set.Emotes))
+	for i, emote := range set.Emotes {
+		emotes[i] = CreateEmoteResponse(emote, owners[emote.OwnerID])
+	}
+
+	return &PersonalEmoteSetResponse{
+		ID:         set.ID.Hex(),
+		UnicodeTag: set.UnicodeTag,
+		Emotes:     emotes,
+	}
+}
+
+type PersonalEmoteSetResponse struct {
+	ID         string          `json:"id"`
+	UnicodeTag string          `json:"unicode_tag"`
+	Emotes     []EmoteResponse `json:"emotes"`
+}
+
+func CreateSnapshotImportResponse(result *actions.SnapshotImport, preview bool) *SnapshotImportResponse {
+	conflicts := make([]SnapshotConflictResponse, len(result.Conflicts))
+	for i, c := range result.Conflicts {
+		conflicts[i] = SnapshotConflictResponse{
+			Kind: string(c.Kind),
+			ID:   c.ID.Hex(),
+			Name: c.Name,
+		}
+	}
+
+	return &SnapshotImportResponse{
+		Preview:        preview,
+		Added:          hexIDs(result.Added),
+		Removed:        hexIDs(result.Removed),
+		Renamed:        hexIDs(result.Renamed),
+		EditorsUpdated: hexIDs(result.EditorsUpdated),
+		EditorsRemoved: hexIDs(result.EditorsRemoved),
+		Conflicts:      conflicts,
+	}
+}
+
+type SnapshotImportResponse struct {
+	Preview        bool                       `json:"preview"` // Whether the changes were only computed
+	Added          []string                   `json:"added"`
+	Removed        []string                   `json:"removed"`
+	Renamed        []string                   `json:"renamed"`
+	EditorsUpdated []string                   `json:"editors_updated"`
+	EditorsRemoved []string                   `json:"editors_removed"`
+	Conflicts      []SnapshotConflictResponse `json:"conflicts"`
+}
+
+type SnapshotConflictResponse struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+func hexIDs(ids []primitive.ObjectID) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = id.Hex()
+	}
+	return result
+}
+
+func CreateEffectiveEmotesResponse(result *actions.ResolvedChannelEmotes) *EffectiveEmotesResponse {
+	emotes := make(map[string]EffectiveEmoteResponse, len(result.Emotes))
+	for _, e := range result.Emotes {
+		shadowed := make([]ShadowedEmoteResponse, len(e.Shadowed))
+		for i, s := range e.Shadowed {
+			shadowed[i] = ShadowedEmoteResponse{
+				ID:         providerEmoteID(s.Emote),
+				Provider:   s.Source.Provider(),
+				Source:     string(s.Source),
+				Overridden: s.Overridden,
+			}
+		}
+
+		emotes[e.Emote.Name] = EffectiveEmoteResponse{
+			ID:         providerEmoteID(e.Emote),
+			Provider:   e.Source.Provider(),
+			Source:     string(e.Source),
+			Visibility: e.Emote.Visibility,
+			ZeroWidth:  utils.BitField.HasBits(int64(e.Emote.Visibility), int64(datastructure.EmoteVisibilityZeroWidth)),
+			Mime:       e.Emote.Mime,
+			URLs:       utils.Ternary(e.Source.Provider() == "7TV", datastructure.GetEmoteURLs(*e.Emote), e.Emote.URLs).([][]string),
+			Shadowed:   shadowed,
+		}
+	}
+
+	precedence := make([]string, len(result.Precedence))
+	for i, s := range result.Precedence {
+		precedence[i] = string(s)
+	}
+	unavailable := make([]string, len(result.Unavailable))
+	for i, s := range result.Unavailable {
+		unavailable[i] = string(s)
+	}
+
+	return &EffectiveEmotesResponse{
+		Precedence:  precedence,
+		Emotes:      emotes,
+		Unavailable: unavailable,
+	}
+}
+
+type EffectiveEmotesResponse struct {
+	Precedence  []string                          `json:"precedence"`
+	Emotes      map[string]EffectiveEmoteResponse `json:"emotes"`      // Keyed by the name the emote is typed as
+	Unavailable []string                          `json:"unavailable"` // The sources which couldn't be reached, whose emotes are left out
+}
+
+type EffectiveEmoteResponse struct {
+	ID         string                  `json:"id"`
+	Provider   string                  `json:"provider"`
+	Source     string                  `json:"source"`
+	Visibility int32                   `json:"visibility"`
+	ZeroWidth  bool                    `json:"zero_width"`
+	Mime       string                  `json:"mime"`
+	URLs       [][]string              `json:"urls"`
+	Shadowed   []ShadowedEmoteResponse `json:"shadowed"` // The emotes of the same name it wins over
+}
+
+type ShadowedEmoteResponse struct {
+	ID         string `json:"id"`
+	Provider   string `json:"provider"`
+	Source     string `json:"source"`
+	Overridden bool   `json:"overridden"` // Whether an override flag displaced the emote, rather than precedence
+}
+
+// The ID of an emote as its provider knows it
+func providerEmoteID(emote *datastructure.Emote) string {
+	if emote.ProviderID != nil {
+		return *emote.ProviderID
+	}
+	return emote.ID.Hex()
+}
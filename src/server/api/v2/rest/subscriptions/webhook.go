@@ -0,0 +1,55 @@
+package subscriptions
+
+import (
+	"github.com/SevenTV/ServerGo/src/billing"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// Take the subscription events of a payment provider
+//
+// Events about unknown users or plans are acknowledged and dropped, while other failures are answered
+// with an error so that the provider retries. This includes events about a subscription not known yet,
+// which arrived before its activation
+func Webhook(router fiber.Router) {
+	router.Post("/webhooks/:provider", func(c *fiber.Ctx) error {
+		provider := billing.GetProvider(c.Params("provider"))
+		if provider == nil {
+			return restutil.ErrBadRequest().Send(c, "unknown provider")
+		}
+
+		events, err := provider.ParseWebhook(func(key string) string { return c.Get(key) }, c.Body())
+		if err == billing.ErrInvalidSignature {
+			return restutil.ErrAccessDenied().Send(c)
+		} else if err != nil {
+			return restutil.ErrBadRequest().Send(c, err.Error())
+		}
+
+		for _, ev := range events {
+			lg := log.WithFields(log.Fields{
+				"provider":        provider.Name(),
+				"type":            ev.Type,
+				"subscription_id": ev.SubscriptionID,
+			})
+
+			_, err := actions.Subscriptions.Apply(c.Context(), provider.Name(), ev)
+			switch err {
+			case nil:
+				lg.Info("subscription updated")
+			case actions.ErrUnknownSubscription:
+				// Only activations create subscriptions, so this one is still to come
+				lg.WithError(err).Warn("subscription event deferred")
+				return restutil.ErrUnavailable().Send(c, err.Error())
+			case actions.ErrUnknownSubscriber, actions.ErrUnknownPlan, billing.ErrMalformedWebhook:
+				lg.WithError(err).Warn("subscription event dropped")
+			default:
+				lg.WithError(err).Error("subscriptions")
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
@@ -0,0 +1,307 @@
+//go:build integration
+// +build integration
+
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/billing"
+	"github.com/SevenTV/ServerGo/src/configure"
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testSecret = "test-secret"
+	testGrace  = 72 * time.Hour
+)
+
+// A user and a plan to subscribe them to, removed with everything created for them once the test ends
+type fixture struct {
+	t      *testing.T
+	app    *fiber.App
+	userID primitive.ObjectID
+	plan   *datastructure.SubscriptionPlan
+	subID  string // The ID of the subscription at the provider
+}
+
+func newFixture(t *testing.T) *fixture {
+	ctx := context.Background()
+	configure.Config.Set("billing.grace_period", testGrace)
+	billing.Register(&billing.FakeProvider{Secret: testSecret})
+
+	app := fiber.New()
+	Webhook(app)
+
+	f := &fixture{
+		t:      t,
+		app:    app,
+		userID: primitive.NewObjectID(),
+		subID:  primitive.NewObjectID().Hex(),
+	}
+	if _, err := mongo.Collection(mongo.CollectionNameUsers).InsertOne(ctx, &datastructure.User{
+		ID:       f.userID,
+		TwitchID: f.userID.Hex(),
+		Login:    "subscriber_" + f.userID.Hex(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	badgeID := primitive.NewObjectID()
+	f.plan = &datastructure.SubscriptionPlan{
+		ID:            primitive.NewObjectID(),
+		Name:          "Test",
+		Interval:      datastructure.BillingIntervalMonth,
+		Perks:         datastructure.SubscriptionPerks{BadgeID: &badgeID, EmoteSlots: 50},
+		ProviderPlans: map[string]string{"fake": "plan_" + f.subID},
+	}
+	if _, err := mongo.Collection(mongo.CollectionNamePlans).InsertOne(ctx, f.plan); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_, _ = mongo.Collection(mongo.CollectionNameUsers).DeleteOne(ctx, bson.M{"_id": f.userID})
+		_, _ = mongo.Collection(mongo.CollectionNamePlans).DeleteOne(ctx, bson.M{"_id": f.plan.ID})
+		_, _ = mongo.Collection(mongo.CollectionNameSubscriptions).DeleteMany(ctx, bson.M{"user_id": f.userID})
+		_, _ = mongo.Collection(mongo.CollectionNameEntitlements).DeleteMany(ctx, bson.M{"user_id": f.userID})
+	})
+	return f
+}
+
+// Send events to the webhook, returning the response status
+func (f *fixture) post(events ...*billing.Event) int {
+	for _, ev := range events {
+		if ev.SubscriptionID == "" {
+			ev.SubscriptionID = f.subID
+		}
+	}
+	body, err := json.Marshal(events)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhooks/fake", bytes.NewReader(body))
+	req.Header.Set("X-Fake-Signature", testSecret)
+	res, err := f.app.Test(req, -1)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func (f *fixture) activation(at time.Time, periodEnd time.Time) *billing.Event {
+	return &billing.Event{
+		Type:        billing.EventActivated,
+		UserID:      f.userID.Hex(),
+		PlanID:      f.plan.ProviderPlans["fake"],
+		PeriodStart: at,
+		PeriodEnd:   periodEnd,
+		At:          at,
+	}
+}
+
+func (f *fixture) subscription() *datastructure.Subscription {
+	sub := &datastructure.Subscription{}
+	if err := mongo.Collection(mongo.CollectionNameSubscriptions).FindOne(context.Background(), bson.M{
+		"provider":    "fake",
+		"provider_id": f.subID,
+	}).Decode(sub); err != nil {
+		f.t.Fatal(err)
+	}
+	return sub
+}
+
+// Get the user's entitlements by kind
+func (f *fixture) entitlements() map[datastructure.EntitlementKind]*datastructure.Entitlement {
+	ents := []*datastructure.Entitlement{}
+	cur, err := mongo.Collection(mongo.CollectionNameEntitlements).Find(context.Background(), bson.M{"user_id": f.userID})
+	if err == nil {
+		err = cur.All(context.Background(), &ents)
+	}
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	byKind := map[datastructure.EntitlementKind]*datastructure.Entitlement{}
+	for _, e := range ents {
+		if _, ok := byKind[e.Kind]; ok {
+			f.t.Fatalf("more than one %v entitlement", e.Kind)
+		}
+		byKind[e.Kind] = e
+	}
+	return byKind
+}
+
+func (f *fixture) expectEntitledUntil(until time.Time, kinds ...datastructure.EntitlementKind) {
+	ents := f.entitlements()
+	if len(ents) != len(kinds) {
+		f.t.Fatalf("expected %d entitlements, got %d", len(kinds), len(ents))
+	}
+	for _, kind := range kinds {
+		e, ok := ents[kind]
+		if !ok {
+			f.t.Fatalf("expected a %v entitlement", kind)
+		}
+		if e.EndsAt == nil || !e.EndsAt.Equal(until) {
+			f.t.Fatalf("expected the %v entitlement to end at %v, got %v", kind, until, e.EndsAt)
+		}
+	}
+}
+
+func TestWebhookStatusMachine(t *testing.T) {
+	f := newFixture(t)
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	end := start.Add(30 * 24 * time.Hour)
+
+	if status := f.post(f.activation(start, end)); status != fiber.StatusNoContent {
+		t.Fatalf("activation: status %d", status)
+	}
+	if sub := f.subscription(); sub.Status != datastructure.SubscriptionStatusActive || !sub.PeriodEnd.Equal(end) {
+		t.Fatalf("activation: got %v until %v", sub.Status, sub.PeriodEnd)
+	}
+	f.expectEntitledUntil(end.Add(testGrace), datastructure.EntitlementKindSubscription, datastructure.EntitlementKindBadge)
+
+	// A failed renewal leaves the perks until the grace period ends
+	f.post(&billing.Event{Type: billing.EventPaymentFailed, At: start.Add(time.Minute)})
+	sub := f.subscription()
+	if sub.Status != datastructure.SubscriptionStatusPastDue || sub.GraceEndsAt == nil || !sub.GraceEndsAt.Equal(end.Add(testGrace)) {
+		t.Fatalf("payment failed: got %v, grace ending %v", sub.Status, sub.GraceEndsAt)
+	}
+
+	// Paying extends the perks, rather than granting them again
+	renewedEnd := end.Add(30 * 24 * time.Hour)
+	f.post(&billing.Event{Type: billing.EventRenewed, PeriodStart: end, PeriodEnd: renewedEnd, At: start.Add(2 * time.Minute)})
+	if sub := f.subscription(); sub.Status != datastructure.SubscriptionStatusActive || sub.GraceEndsAt != nil {
+		t.Fatalf("renewal: got %v, grace ending %v", sub.Status, sub.GraceEndsAt)
+	}
+	f.expectEntitledUntil(renewedEnd.Add(testGrace), datastructure.EntitlementKindSubscription, datastructure.EntitlementKindBadge)
+
+	// A canceled subscription ends with its period
+	f.post(&billing.Event{Type: billing.EventCanceled, At: start.Add(3 * time.Minute)})
+	if sub := f.subscription(); sub.Status != datastructure.SubscriptionStatusCanceled || sub.CanceledAt == nil {
+		t.Fatalf("cancelation: got %v", sub.Status)
+	}
+	f.expectEntitledUntil(renewedEnd, datastructure.EntitlementKindSubscription, datastructure.EntitlementKindBadge)
+
+	f.post(&billing.Event{Type: billing.EventResumed, At: start.Add(4 * time.Minute)})
+	if sub := f.subscription(); sub.Status != datastructure.SubscriptionStatusActive || sub.CanceledAt != nil {
+		t.Fatalf("resumption: got %v", sub.Status)
+	}
+
+	// Ending it revokes the perks at once
+	f.post(&billing.Event{Type: billing.EventEnded, At: start.Add(5 * time.Minute)})
+	if sub := f.subscription(); sub.Status != datastructure.SubscriptionStatusEnded || sub.EndedAt == nil {
+		t.Fatalf("end: got %v", sub.Status)
+	}
+	f.expectEntitledUntil(time.Time{})
+}
+
+func TestWebhookEventOrder(t *testing.T) {
+	f := newFixture(t)
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	end := start.Add(30 * 24 * time.Hour)
+
+	// Events about a subscription not activated yet are redelivered by the provider
+	if status := f.post(&billing.Event{Type: billing.EventCanceled, At: start.Add(time.Minute)}); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("event before activation: status %d", status)
+	}
+
+	f.post(f.activation(start, end))
+	f.post(&billing.Event{Type: billing.EventCanceled, At: start.Add(2 * time.Minute)})
+
+	// An event emitted before the last one applied is ignored
+	if status := f.post(&billing.Event{Type: billing.EventResumed, At: start.Add(time.Minute)}); status != fiber.StatusNoContent {
+		t.Fatalf("stale event: status %d", status)
+	}
+	if sub := f.subscription(); sub.Status != datastructure.SubscriptionStatusCanceled {
+		t.Fatalf("stale event: got %v", sub.Status)
+	}
+
+	// Events about unknown users are dropped
+	stranger := f.activation(start, end)
+	stranger.SubscriptionID = primitive.NewObjectID().Hex()
+	stranger.UserID = primitive.NewObjectID().Hex()
+	if status := f.post(stranger); status != fiber.StatusNoContent {
+		t.Fatalf("unknown user: status %d", status)
+	}
+}
+
+func TestSyncEntitlements(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	end := start.Add(30 * 24 * time.Hour)
+
+	f.post(f.activation(start, end))
+	before := f.entitlements()
+
+	// The plan trades its badge for a role
+	roleID := primitive.NewObjectID()
+	if _, err := mongo.Collection(mongo.CollectionNamePlans).UpdateOne(ctx, bson.M{"_id": f.plan.ID}, bson.M{
+		"$set":   bson.M{"perks.role_id": roleID, "perks.emote_slots": 100},
+		"$unset": bson.M{"perks.badge_id": ""},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := actions.Subscriptions.SyncEntitlements(ctx, f.subscription()); err != nil {
+		t.Fatal(err)
+	}
+
+	after := f.entitlements()
+	f.expectEntitledUntil(end.Add(testGrace), datastructure.EntitlementKindSubscription, datastructure.EntitlementKindRole)
+
+	// The subscription's own entitlement is kept, with the plan's new perks
+	if after[datastructure.EntitlementKindSubscription].ID != before[datastructure.EntitlementKindSubscription].ID {
+		t.Fatal("the subscription entitlement was granted again instead of being extended")
+	}
+	data := datastructure.EntitledSubscription{}
+	if err := bson.Unmarshal(after[datastructure.EntitlementKindSubscription].Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.EmoteSlots != 100 {
+		t.Fatalf("expected 100 emote slots, got %d", data.EmoteSlots)
+	}
+}
+
+func TestEndLapsed(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	// Still within the grace period
+	start := time.Now().UTC().Truncate(time.Millisecond).Add(-31 * 24 * time.Hour)
+	f.post(f.activation(start, start.Add(30*24*time.Hour)))
+	if ended, err := actions.Subscriptions.EndLapsed(ctx, f.subscription()); err != nil || ended {
+		t.Fatalf("within grace: ended %v, %v", ended, err)
+	}
+
+	// Past it
+	lapsed := start.Add(-testGrace)
+	if _, err := mongo.Collection(mongo.CollectionNameSubscriptions).UpdateOne(ctx, bson.M{"provider_id": f.subID}, bson.M{
+		"$set": bson.M{"period_end": lapsed},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ended, err := actions.Subscriptions.EndLapsed(ctx, f.subscription())
+	if err != nil || !ended {
+		t.Fatalf("lapsed: ended %v, %v", ended, err)
+	}
+	if sub := f.subscription(); sub.Status != datastructure.SubscriptionStatusEnded || sub.EndedAt == nil || !sub.EndedAt.Equal(lapsed.Add(testGrace)) {
+		t.Fatalf("lapsed: got %v, ended at %v", sub.Status, sub.EndedAt)
+	}
+	f.expectEntitledUntil(time.Time{})
+
+	// Ending it twice does nothing
+	if ended, err := actions.Subscriptions.EndLapsed(ctx, f.subscription()); err != nil || ended {
+		t.Fatalf("already ended: ended %v, %v", ended, err)
+	}
+}
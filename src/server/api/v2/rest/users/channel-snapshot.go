@@ -0,0 +1,158 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/mongo"
+	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
+	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
+	"github.com/SevenTV/ServerGo/src/server/middleware"
+	"github.com/SevenTV/ServerGo/src/utils"
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Export a channel's emotes and editors as a snapshot document, or one of its restore points
+func ExportChannelSnapshotRoute(router fiber.Router) {
+	router.Get("/:user/snapshot",
+		middleware.UserAuthMiddleware(true),
+		middleware.RateLimitMiddleware("channel-snapshot", 10, 10*time.Second),
+		func(c *fiber.Ctx) error {
+			channel, errResponse := snapshotChannel(c)
+			if errResponse != nil {
+				return errResponse.Send(c)
+			}
+
+			var snapshot *datastructure.ChannelSnapshot
+			var err error
+			if v := c.Query("restore_point"); v != "" {
+				id, parseErr := primitive.ObjectIDFromHex(v)
+				if parseErr != nil {
+					return restutil.MalformedObjectId().Send(c)
+				}
+				if snapshot, err = actions.Users.RestorePoint(c.Context(), channel.ID, id); err == nil && snapshot == nil {
+					return restutil.ErrBadRequest().Send(c, "unknown restore point")
+				}
+			} else {
+				snapshot, err = actions.Users.Snapshot(c.Context(), channel)
+			}
+			if err != nil {
+				log.WithError(err).Error("mongo")
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			b, err := json.MarshalIndent(snapshot, "", "  ")
+			if err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			filename := fmt.Sprintf("%v-%v.json", channel.Login, snapshot.CreatedAt.UTC().Format("20060102-150405"))
+			c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v\"", filename))
+			return c.Send(b)
+		},
+	)
+}
+
+// Restore a channel to a snapshot document, or preview the changes with ?preview=true
+func ImportChannelSnapshotRoute(router fiber.Router) {
+	router.Post("/:user/snapshot",
+		middleware.UserAuthMiddleware(true),
+		middleware.RateLimitMiddleware("channel-snapshot", 10, 10*time.Second),
+		func(c *fiber.Ctx) error {
+			ctx := c.Context()
+			channel, errResponse := snapshotChannel(c)
+			if errResponse != nil {
+				return errResponse.Send(c)
+			}
+			usr := c.Locals("user").(*datastructure.User)
+
+			snapshot := &datastructure.ChannelSnapshot{}
+			if err := json.Unmarshal(c.Body(), snapshot); err != nil {
+				return restutil.ErrBadRequest().Send(c, "malformed snapshot")
+			}
+
+			if _, err := redis.Client.HGet(ctx, "user:bans", channel.ID.Hex()).Result(); err == nil {
+				return restutil.ErrUserBanned().Send(c)
+			} else if err != redis.ErrNil {
+				log.WithError(err).Error("redis")
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+			if restricted, err := actions.Bans.IsRestricted(ctx, usr.ID, datastructure.BanScopeChannelEdit); err != nil {
+				log.WithError(err).Error("redis")
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			} else if restricted {
+				return restutil.ErrUserBanned().Send(c)
+			}
+
+			var reason *string
+			if v := c.Query("reason"); v != "" {
+				reason = utils.StringPointer(v)
+			}
+			preview := c.Query("preview") == "true"
+			result, err := actions.Users.ImportSnapshot(ctx, actions.SnapshotImportOptions{
+				Actor:          usr,
+				Channel:        channel,
+				Snapshot:       snapshot,
+				IncludeEditors: c.Query("include_editors") == "true",
+				Preview:        preview,
+				Reason:         reason,
+			})
+			batchErr := &actions.ChannelEmoteBatchError{}
+			switch {
+			case err == nil:
+			case err == actions.ErrSnapshotAccessDenied:
+				return restutil.ErrAccessDenied().Send(c)
+			case err == actions.ErrSnapshotVersion, errors.Is(err, actions.ErrChannelChanged), errors.As(err, &batchErr):
+				return restutil.ErrBadRequest().Send(c, err.Error())
+			default:
+				log.WithError(err).Error("mongo")
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			b, err := json.Marshal(restutil.CreateSnapshotImportResponse(result, preview))
+			if err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+			return c.Send(b)
+		},
+	)
+}
+
+// Find the channel of a snapshot route, which the actor must own, edit or manage
+func snapshotChannel(c *fiber.Ctx) (*datastructure.User, *restutil.ErrorResponse) {
+	usr, ok := c.Locals("user").(*datastructure.User)
+	if !ok {
+		return nil, restutil.ErrLoginRequired()
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params("user"))
+	if err != nil {
+		id = primitive.NilObjectID
+	}
+	channel := &datastructure.User{}
+	if err := mongo.Collection(mongo.CollectionNameUsers).FindOne(c.Context(), bson.M{
+		"$or": bson.A{
+			bson.M{"_id": id},
+			bson.M{"login": strings.ToLower(c.Params("user"))},
+		},
+	}).Decode(channel); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, restutil.ErrUnknownUser()
+		}
+		log.WithError(err).Error("mongo")
+		return nil, restutil.ErrInternalServer()
+	}
+
+	if _, isEditor := channel.GetEditorPermissions(usr.ID); !isEditor && usr.ID != channel.ID && !usr.HasPermission(datastructure.RolePermissionManageUsers) {
+		return nil, restutil.ErrAccessDenied()
+	}
+	return channel, nil
+}
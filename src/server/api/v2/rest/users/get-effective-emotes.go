@@ -0,0 +1,61 @@
+package users
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/SevenTV/ServerGo/src/emoteresolution"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
+	"github.com/SevenTV/ServerGo/src/server/api/v2/rest/restutil"
+	"github.com/SevenTV/ServerGo/src/server/middleware"
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Get the emotes in effect in a channel across 7TV, BTTV, FFZ and Twitch, keyed by name.
+// The precedence query lists the sources to resolve, the first winning a name conflict
+func GetEffectiveEmotesRoute(router fiber.Router) {
+	router.Get("/:user/emotes/effective", middleware.RateLimitMiddleware("get-user-effective-emotes", 60, 9*time.Second),
+		func(c *fiber.Ctx) error {
+			ctx := c.Context()
+			channelIdentifier := c.Params("user")
+			c.Set("Cache-Control", "max-age=30")
+
+			precedence := actions.EmotePrecedence()
+			if q := c.Query("precedence"); q != "" {
+				p, err := emoteresolution.ParsePrecedence(strings.Split(q, ","))
+				if err != nil {
+					return restutil.ErrBadRequest().Send(c, "precedence: unknown or repeated emote source")
+				}
+				precedence = p
+			}
+
+			// Find channel user
+			ub, err := actions.Users.Get(ctx, bson.M{
+				"$or": bson.A{
+					bson.M{"id": channelIdentifier},
+					bson.M{"login": strings.ToLower(channelIdentifier)},
+				},
+			})
+			if err != nil {
+				return restutil.ErrUnknownUser().Send(c, err.Error())
+			}
+
+			result := &actions.ResolvedChannelEmotes{Precedence: precedence}
+			if !ub.IsBanned() {
+				if result, err = actions.Emotes.ResolveChannel(ctx, &ub.User, precedence); err != nil {
+					log.WithError(err).Error("mongo")
+					return restutil.ErrInternalServer().Send(c, err.Error())
+				}
+			}
+
+			j, err := json.Marshal(restutil.CreateEffectiveEmotesResponse(result))
+			if err != nil {
+				return restutil.ErrInternalServer().Send(c, err.Error())
+			}
+
+			return c.Send(j)
+		})
+}
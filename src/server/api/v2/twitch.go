@@ -13,6 +13,7 @@ import (
 	"github.com/SevenTV/ServerGo/src/mongo"
 	"github.com/SevenTV/ServerGo/src/mongo/datastructure"
 	"github.com/SevenTV/ServerGo/src/redis"
+	"github.com/SevenTV/ServerGo/src/server/api/actions"
 	"github.com/SevenTV/ServerGo/src/server/middleware"
 	"github.com/SevenTV/ServerGo/src/utils"
 	"go.mongodb.org/mongo-driver/bson"
@@ -278,14 +279,23 @@ func Twitch(app fiber.Router) fiber.Router {
 			}
 		}
 
+		// Signing in through Twitch proves ownership of a locked down account
+		if err := actions.Lockdowns.Reauthenticate(c.Context(), mongoUser); err != nil {
+			log.WithError(err).Error("lockdown")
+		}
+
 		var respError error
 		// Check ban?
 		if reason, err := redis.Client.HGet(c.Context(), "user:bans", mongoUser.ID.Hex()).Result(); err != redis.ErrNil {
 			var ban *datastructure.Ban
-			res := mongo.Collection(mongo.CollectionNameBans).FindOne(c.Context(), bson.M{"user_id": mongoUser.ID, "expire_at": bson.M{"$gt": time.Now()}})
-			err = res.Err()
+			bans, err := actions.Bans.GetActive(c.Context(), mongoUser.ID)
 			if err == nil {
-				_ = res.Decode(&ban)
+				for _, b := range bans {
+					if b.IsFull() {
+						ban = b
+						break
+					}
+				}
 				respError = fmt.Errorf(
 					"You are currently banned for '%v'%v",
 					reason,
@@ -132,6 +132,8 @@ func UserAuthMiddleware(required bool) func(c *fiber.Ctx) error {
 		}
 
 		if err == nil {
+			// Banned users are only granted access to the ban appeal endpoints
+			c.Locals("banned_user", user)
 			if !required {
 				return c.Next()
 			}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"image"
+	"math/bits"
+)
+
+// DifferenceHash: Compute a perceptual hash of an image
+//
+// The image is reduced to a 9x8 grid of brightness, and each bit tells whether a cell is brighter than the next one in its row.
+// Scaling or recompressing an image barely changes its hash, so alike images are found by comparing hashes
+func DifferenceHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return 0
+	}
+
+	// Average the brightness of the pixels in each cell
+	var grid [h][w]float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+			if x1 == x0 {
+				x1++
+			}
+			if y1 == y0 {
+				y1++
+			}
+
+			sum, n := 0.0, 0.0
+			for py := y0; py < y1 && py < b.Max.Y; py++ {
+				for px := x0; px < x1 && px < b.Max.X; px++ {
+					r, g, bl, _ := img.At(px, py).RGBA() // Premultiplied, so transparent pixels count as black
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			if n > 0 {
+				grid[y][x] = sum / n
+			}
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashSimilarity: Get how alike the images of two perceptual hashes are, from 0 to 1
+func HashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
@@ -186,5 +186,6 @@ func GetSizeRatio(og []float64, nw []float64) (int32, int32) {
 type Key string
 
 const UserKey = Key("user")
+const BannedUserKey = Key("banned_user") // The authenticated user while they are banned. Only appeal endpoints may use it
 const RequestCtxKey = Key("RequestCtx")
 const AllRolesKey = Key("AllRoles")